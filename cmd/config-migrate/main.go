@@ -0,0 +1,136 @@
+//Command config-migrate brings the config collections' indexes up to date and reports any drift it can't fix.  It
+//exits non-zero if drift remains, so it can gate a deploy
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"github.com/mcquackers/config-demo/pkg/repo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func main() {
+	mongoHosts := flag.String("mongo-hosts", "localhost:27017", "comma separated MongoDB hosts")
+	replicaSet := flag.String("replica-set", "testRepl", "MongoDB replica set name")
+	check := flag.Bool("check", false, "only report drift; create nothing")
+	timeout := flag.Duration("timeout", 5*time.Minute, "how long index builds may take")
+	database := flag.String("database", "config-demo", "MongoDB database")
+	collection := flag.String("collection", "configs", "configs collection")
+	historyCollection := flag.String("history-collection", "config_history", "config history collection")
+	tenants := flag.String("tenants", "", "multi-tenant routing: database for a config-<tenant> database per tenant, prefix for <tenant>_ prefixed collections; empty for a single tenant")
+	tenantIDs := flag.String("tenant", "", "comma separated tenants to migrate; required with -tenants")
+	hierarchyLevels := flag.String("hierarchy", "corporate,venue,vendor", "comma separated config levels, most general first; each level's ID field is <level>_id, and <level>=<type>+<type> lists the config types it allows, which levels other than corporate, venue and vendor must")
+	flag.Parse()
+
+	hierarchy, err := entities.HierarchyFromNames(strings.Split(*hierarchyLevels, ",")...)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	resolver, err := repo.TenantResolverForMode(*tenants)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	repoOpts := []repo.RepoOption{repo.WithHierarchy(hierarchy), repo.WithDatabase(*database), repo.WithCollection(*collection), repo.WithHistoryCollection(*historyCollection)}
+	if resolver != nil {
+		repoOpts = append(repoOpts, repo.WithTenantResolver(resolver))
+	}
+	if (resolver != nil) != (*tenantIDs != "") {
+		log.Fatal("-tenant and -tenants go together")
+	}
+
+	client, err := setUpClient(strings.Split(*mongoHosts, ","), *replicaSet)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer client.Disconnect(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	configRepo := repo.NewMDBRepo(client, repoOpts...)
+	//A single tenant is migrated under the plain context
+	tenantList := []string{""}
+	if resolver != nil {
+		tenantList = strings.Split(*tenantIDs, ",")
+	}
+
+	inSync := true
+	for _, tenantID := range tenantList {
+		tenantCtx := ctx
+		if tenantID != "" {
+			tenantCtx = repo.WithTenant(ctx, tenantID)
+		}
+		var report []repo.IndexDrift
+		if *check {
+			report, err = configRepo.CheckIndexes(tenantCtx)
+		} else {
+			report, err = configRepo.EnsureIndexes(tenantCtx)
+		}
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+
+		for _, drift := range report {
+			if tenantID != "" {
+				drift.Collection = fmt.Sprintf("%s (tenant %s)", drift.Collection, tenantID)
+			}
+			printDrift(drift)
+			inSync = inSync && drift.InSync()
+		}
+	}
+	if !inSync {
+		os.Exit(1)
+	}
+}
+
+func printDrift(drift repo.IndexDrift) {
+	if drift.InSync() && len(drift.Created) == 0 {
+		fmt.Printf("%s: in sync\n", drift.Collection)
+		return
+	}
+	for _, section := range []struct {
+		label string
+		names []string
+	}{
+		{"created", drift.Created},
+		{"missing", drift.Missing},
+		{"mismatched", drift.Mismatched},
+		{"unexpected", drift.Unexpected},
+	} {
+		if len(section.names) > 0 {
+			fmt.Printf("%s: %s %s\n", drift.Collection, section.label, strings.Join(section.names, ", "))
+		}
+	}
+}
+
+
+func setUpClient(hosts []string, replicaSet string) (*mongo.Client, error) {
+	mdbConnectionOpts := options.Client().
+		SetConnectTimeout(5 * time.Second).
+		SetHosts(hosts).
+		SetReplicaSet(replicaSet)
+
+	mdbClient, err := mongo.NewClient(mdbConnectionOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := mdbClient.Connect(context.Background()); err != nil {
+		return nil, err
+	}
+
+	if err := mdbClient.Ping(context.Background(), readpref.PrimaryPreferred()); err != nil {
+		return nil, err
+	}
+
+	return mdbClient, nil
+}
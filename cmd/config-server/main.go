@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"github.com/mcquackers/config-demo/pkg/grpcapi"
+	"github.com/mcquackers/config-demo/pkg/grpcapi/configpb"
+	"github.com/mcquackers/config-demo/pkg/httpapi"
+	"github.com/mcquackers/config-demo/pkg/repo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to serve HTTP/JSON on")
+	grpcAddr := flag.String("grpc-addr", "", "address to serve gRPC on; disabled if empty")
+	mongoHosts := flag.String("mongo-hosts", "localhost:27017", "comma separated MongoDB hosts")
+	replicaSet := flag.String("replica-set", "testRepl", "MongoDB replica set name")
+	ensureIndexes := flag.Bool("ensure-indexes", true, "create missing indexes on start up; see config-migrate")
+	database := flag.String("database", "config-demo", "MongoDB database")
+	collection := flag.String("collection", "configs", "configs collection")
+	historyCollection := flag.String("history-collection", "config_history", "config history collection")
+	tenants := flag.String("tenants", "", "multi-tenant routing: database for a config-<tenant> database per tenant, prefix for <tenant>_ prefixed collections; empty for a single tenant")
+	tenantIDs := flag.String("tenant", "", "comma separated tenants whose indexes to ensure on start up, with -tenants; any other tenant must be migrated with config-migrate before it writes")
+	hierarchyLevels := flag.String("hierarchy", "corporate,venue,vendor", "comma separated config levels, most general first; each level's ID field is <level>_id, and <level>=<type>+<type> lists the config types it allows, which levels other than corporate, venue and vendor must")
+	flag.Parse()
+
+	hierarchy, err := entities.HierarchyFromNames(strings.Split(*hierarchyLevels, ",")...)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	resolver, err := repo.TenantResolverForMode(*tenants)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	repoOpts := []repo.RepoOption{repo.WithHierarchy(hierarchy), repo.WithDatabase(*database), repo.WithCollection(*collection), repo.WithHistoryCollection(*historyCollection)}
+	if resolver != nil {
+		repoOpts = append(repoOpts, repo.WithTenantResolver(resolver))
+	}
+
+	client, err := setUpClient(strings.Split(*mongoHosts, ","), *replicaSet)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer client.Disconnect(context.Background())
+
+	configRepo := repo.NewMDBRepo(client, repoOpts...)
+	if *ensureIndexes {
+		//Every tenant has its own collections, and so its own indexes.  scope_unique is what makes
+		//SetConfigIfRevision(..., 0) safe, so tenants not listed here have to be migrated before they write
+		tenantList := []string{""}
+		if resolver != nil {
+			tenantList = nil
+			if *tenantIDs != "" {
+				tenantList = strings.Split(*tenantIDs, ",")
+			}
+			log.Printf("ensuring indexes for tenants %v; migrate any other tenant with config-migrate -tenants %s -tenant <id> before it writes", tenantList, *tenants)
+		}
+		for _, tenantID := range tenantList {
+			ctx := context.Background()
+			if tenantID != "" {
+				ctx = repo.WithTenant(ctx, tenantID)
+			}
+			report, err := configRepo.EnsureIndexes(ctx)
+			if err != nil {
+				log.Fatal(err.Error())
+			}
+			for _, drift := range report {
+				if !drift.InSync() {
+					log.Printf("index drift on %s: mismatched %v, unexpected %v; run config-migrate -check", tenantCollection(drift.Collection, tenantID), drift.Mismatched, drift.Unexpected)
+				}
+			}
+		}
+	}
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           httpapi.NewHandler(&configRepo),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	var grpcServer *grpc.Server
+	if *grpcAddr != "" {
+		listener, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		grpcServer = grpc.NewServer()
+		configpb.RegisterConfigServiceServer(grpcServer, grpcapi.NewServer(&configRepo))
+		go func() {
+			log.Printf("config-server serving gRPC on %s", *grpcAddr)
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Fatal(err.Error())
+			}
+		}()
+	}
+
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-shutdown
+		if grpcServer != nil {
+			grpcServer.GracefulStop()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("shutdown: %s", err.Error())
+		}
+	}()
+
+	log.Printf("config-server serving HTTP on %s", *addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err.Error())
+	}
+}
+
+
+//tenantCollection labels a collection with its tenant, if it has one
+func tenantCollection(collection, tenantID string) string {
+	if tenantID == "" {
+		return collection
+	}
+
+	return fmt.Sprintf("%s (tenant %s)", collection, tenantID)
+}
+
+func setUpClient(hosts []string, replicaSet string) (*mongo.Client, error) {
+	mdbConnectionOpts := options.Client().
+		SetConnectTimeout(5 * time.Second).
+		SetHosts(hosts).
+		SetReplicaSet(replicaSet)
+
+	mdbClient, err := mongo.NewClient(mdbConnectionOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := mdbClient.Connect(context.Background()); err != nil {
+		return nil, err
+	}
+
+	if err := mdbClient.Ping(context.Background(), readpref.PrimaryPreferred()); err != nil {
+		return nil, err
+	}
+
+	return mdbClient, nil
+}
@@ -0,0 +1,660 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"github.com/mcquackers/config-demo/pkg/repo"
+	"gopkg.in/yaml.v2"
+)
+
+func runGet(ctx context.Context, c *ctl, args []string) error {
+	flags := newFlagSet("get")
+	scopeArg := flags.String("scope", "", "IDs from the top level down, e.g. 1/2")
+	active := flags.Bool("active", false, "the config active at the scope rather than the one set on it")
+	asOf := flags.String("as-of", "", "RFC 3339 time to evaluate effective windows at; with --active")
+	args, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	configType, scope, err := c.target(args, *scopeArg)
+	if err != nil {
+		return err
+	}
+
+	var config entities.ValidatedConfig
+	if *active {
+		opts, err := activeOptions(*asOf)
+		if err != nil {
+			return err
+		}
+		config, err = c.repo.GetActiveConfig(ctx, scope, configType, opts...)
+		if err != nil {
+			return err
+		}
+	} else {
+		if *asOf != "" {
+			return fmt.Errorf("-as-of needs --active")
+		}
+		config, err = c.repo.GetSpecificConfig(ctx, scope, configType)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.printConfig(config)
+}
+
+//runSet writes a config read from -f, or the stored one, with any --field assignments applied on top.  It writes at the
+//revision it read, so a concurrent change fails the command rather than being overwritten.  With -dry-run it prints
+//PlanSetConfig's report instead
+func runSet(ctx context.Context, c *ctl, args []string) error {
+	flags := newFlagSet("set")
+	scopeArg := flags.String("scope", "", "IDs from the top level down, e.g. 1/2")
+	file := flags.String("f", "", "JSON or YAML file holding the whole config; - for stdin")
+	var fields fieldFlags
+	flags.Var(&fields, "field", "field=value to set, by JSON name, e.g. meta.enabled=true; repeatable")
+	changedBy := flags.String("changed-by", "", "who is making the change (required)")
+	dryRun := flags.Bool("dry-run", false, "list the scopes whose active config the change would alter, and write nothing")
+	args, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	configType, scope, err := c.target(args, *scopeArg)
+	if err != nil {
+		return err
+	}
+	if *changedBy == "" {
+		return fmt.Errorf("-changed-by is required")
+	}
+	if *file == "" && len(fields) == 0 {
+		return fmt.Errorf("nothing to set: give -f or --field")
+	}
+
+	stored, err := c.repo.GetSpecificConfig(ctx, scope, configType)
+	if err != nil {
+		return err
+	}
+	config := stored
+	if *file != "" {
+		if config, err = readConfigFile(configType, *file); err != nil {
+			return err
+		}
+	}
+	if config, err = applyFields(config, fields); err != nil {
+		return err
+	}
+	if *dryRun {
+		return c.plan(ctx, scope, stamp(config, *changedBy))
+	}
+
+	return c.write(ctx, scope, config, stored, *changedBy)
+}
+
+func runEnable(ctx context.Context, c *ctl, args []string) error {
+	return setEnabled(ctx, c, "enable", args, true)
+}
+
+func runDisable(ctx context.Context, c *ctl, args []string) error {
+	return setEnabled(ctx, c, "disable", args, false)
+}
+
+//setEnabled flips meta.enabled on a config that is set, leaving it alone if it is already as asked
+func setEnabled(ctx context.Context, c *ctl, name string, args []string, enabled bool) error {
+	flags := newFlagSet(name)
+	scopeArg := flags.String("scope", "", "IDs from the top level down, e.g. 1/2")
+	changedBy := flags.String("changed-by", "", "who is making the change (required)")
+	args, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	configType, scope, err := c.target(args, *scopeArg)
+	if err != nil {
+		return err
+	}
+	if *changedBy == "" {
+		return fmt.Errorf("-changed-by is required")
+	}
+
+	stored, err := c.repo.GetSpecificConfig(ctx, scope, configType)
+	if err != nil {
+		return err
+	}
+	meta, ok := stored.(entities.MetaConfig)
+	if !ok {
+		return fmt.Errorf("config type %s has no meta to %s", configType.String(), name)
+	}
+	if meta.GetMeta().Revision == 0 {
+		return repo.ErrConfigNotFound{Scope: scope, ConfigType: configType}
+	}
+	if meta.GetMeta().Enabled == enabled {
+		fmt.Fprintf(os.Stderr, "%s is already %sd at %s\n", configType.String(), name, c.formatScope(scope))
+		return c.printConfig(stored)
+	}
+
+	config := entities.CloneConfig(stored)
+	config.(entities.MetaConfig).GetMeta().Enabled = enabled
+
+	return c.write(ctx, scope, config, stored, *changedBy)
+}
+
+func runHistory(ctx context.Context, c *ctl, args []string) error {
+	flags := newFlagSet("history")
+	scopeArg := flags.String("scope", "", "IDs from the top level down, e.g. 1/2")
+	limit := flags.Int("limit", 0, "most entries to list; defaults to 50")
+	since := flags.String("since", "", "RFC 3339 time to list changes from")
+	until := flags.String("until", "", "RFC 3339 time to list changes before")
+	beforeVersion := flags.Int64("before-version", 0, "only list versions older than this, to page back")
+	args, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	configType, scope, err := c.target(args, *scopeArg)
+	if err != nil {
+		return err
+	}
+
+	query := repo.HistoryQuery{Limit: *limit, BeforeVersion: *beforeVersion}
+	if query.Since, err = parseTime("since", *since); err != nil {
+		return err
+	}
+	if query.Until, err = parseTime("until", *until); err != nil {
+		return err
+	}
+	entries, err := c.repo.GetConfigHistory(ctx, scope, configType, query)
+	if err != nil {
+		return err
+	}
+
+	if c.output == "json" {
+		return writeJSON(c.stdout, entries)
+	}
+	table := newTable(c.stdout, "VERSION", "CHANGED AT", "CHANGED BY", "CHANGE")
+	for _, entry := range entries {
+		change, err := describeChange(entry.Before, entry.After)
+		if err != nil {
+			return err
+		}
+		table.row(fmt.Sprint(entry.Version), formatTime(entry.ChangedAt), entry.ChangedBy, change)
+	}
+
+	return table.flush()
+}
+
+func runExplain(ctx context.Context, c *ctl, args []string) error {
+	flags := newFlagSet("explain")
+	scopeArg := flags.String("scope", "", "IDs from the top level down, e.g. 1/2/3")
+	asOf := flags.String("as-of", "", "RFC 3339 time to evaluate effective windows at")
+	args, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	configType, scope, err := c.target(args, *scopeArg)
+	if err != nil {
+		return err
+	}
+	opts, err := activeOptions(*asOf)
+	if err != nil {
+		return err
+	}
+	explanation, err := c.repo.ExplainActiveConfig(ctx, scope, configType, opts...)
+	if err != nil {
+		return err
+	}
+
+	if c.output == "json" {
+		return writeJSON(c.stdout, explanation)
+	}
+	fmt.Fprintf(c.stdout, "%s at %s as of %s\n\n", configType.String(), c.formatScope(scope), formatTime(explanation.AsOf))
+	table := newTable(c.stdout, "LEVEL", "SCOPE", "SET", "ENABLED", "EFFECTIVE", "SELECTED", "SKIPPED BECAUSE")
+	for _, candidate := range explanation.Candidates {
+		table.row(c.hierarchy.LevelName(candidate.ConfigLevel), c.formatIDs(candidate.ConfigLevel, candidate.IDs), yesNo(candidate.Set), yesNo(candidate.Enabled), yesNo(candidate.Effective), yesNo(candidate.Selected), string(candidate.SkipReason))
+	}
+	if err := table.flush(); err != nil {
+		return err
+	}
+
+	switch {
+	case explanation.Ambiguous:
+		fmt.Fprintln(c.stdout, "\nambiguous: more than one document for the winning scope is active")
+	case explanation.Winner < 0:
+		fmt.Fprintln(c.stdout, "\nnothing is active; the empty config applies")
+	}
+	if len(explanation.Rollouts) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(c.stdout)
+	table = newTable(c.stdout, "ROLLOUT", "PERCENT", "BUCKET", "INCLUDED")
+	for _, rollout := range explanation.Rollouts {
+		table.row(rollout.Field, fmt.Sprint(rollout.Percent), fmt.Sprint(rollout.Bucket), yesNo(rollout.Included))
+	}
+
+	return table.flush()
+}
+
+//runDiff compares a scope's config with another scope's, with what is active there, or with what was set there before
+func runDiff(ctx context.Context, c *ctl, args []string) error {
+	flags := newFlagSet("diff")
+	scopeArg := flags.String("scope", "", "IDs from the top level down, e.g. 1/2")
+	against := flags.String("against", "", "another scope to compare the active configs with, e.g. 1")
+	active := flags.Bool("active", false, "compare the config set at the scope with the one active there")
+	since := flags.String("since", "", "RFC 3339 time to compare the config set at the scope then with now")
+	asOf := flags.String("as-of", "", "RFC 3339 time to evaluate effective windows at; with -against or --active")
+	args, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	configType, scope, err := c.target(args, *scopeArg)
+	if err != nil {
+		return err
+	}
+	opts, err := activeOptions(*asOf)
+	if err != nil {
+		return err
+	}
+
+	var diff *repo.ConfigDiff
+	var labelA, labelB string
+	switch {
+	case *against != "" && !*active && *since == "":
+		other, err := c.parseScope(*against)
+		if err != nil {
+			return err
+		}
+		diff, err = repo.DiffActiveConfigs(ctx, c.repo, configType, scope, other, opts...)
+		if err != nil {
+			return err
+		}
+		labelA, labelB = c.formatScope(scope), c.formatScope(other)
+	case *active && *against == "" && *since == "":
+		diff, err = repo.DiffSpecificToActive(ctx, c.repo, scope, configType, opts...)
+		if err != nil {
+			return err
+		}
+		labelA, labelB = "SET", "ACTIVE"
+	case *since != "" && *against == "" && !*active:
+		if *asOf != "" {
+			return fmt.Errorf("-as-of needs -against or --active")
+		}
+		at, err := parseTime("since", *since)
+		if err != nil {
+			return err
+		}
+		diff, err = repo.DiffSince(ctx, c.repo, scope, configType, at)
+		if err != nil {
+			return err
+		}
+		labelA, labelB = formatTime(at), "NOW"
+	default:
+		return fmt.Errorf("give one of -against, --active or -since")
+	}
+
+	if c.output == "json" {
+		return writeJSON(c.stdout, diff)
+	}
+	if len(diff.Fields) == 0 {
+		fmt.Fprintln(c.stdout, "no differences")
+		return nil
+	}
+	table := newTable(c.stdout, "FIELD", strings.ToUpper(labelA), strings.ToUpper(labelB))
+	for _, field := range diff.Fields {
+		table.row(field.Path, formatValue(field.A), formatValue(field.B))
+	}
+
+	return table.flush()
+}
+
+func runExport(ctx context.Context, c *ctl, args []string) error {
+	flags := newFlagSet("export")
+	file := flags.String("f", "", "file to write; stdout if empty")
+	args, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("give the %s ID to export", c.hierarchy.LevelName(entities.CONFIG_LEVEL_CORPORATE))
+	}
+
+	tree, err := c.repo.Export(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	format := repo.ExportFormatYAML
+	if c.output == "json" {
+		format = repo.ExportFormatJSON
+	}
+	data, err := tree.Encode(format)
+	if err != nil {
+		return err
+	}
+	if *file == "" {
+		_, err = c.stdout.Write(data)
+		return err
+	}
+
+	return ioutil.WriteFile(*file, data, 0644)
+}
+
+func runImport(ctx context.Context, c *ctl, args []string) error {
+	flags := newFlagSet("import")
+	file := flags.String("f", "", "JSON or YAML file written by export; - for stdin (required)")
+	changedBy := flags.String("changed-by", "", "stamp the imported configs as changed by this, now; otherwise the file's changed_by and changed_at are kept")
+	args, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return fmt.Errorf("unexpected arguments %s", strings.Join(args, " "))
+	}
+	if *file == "" {
+		return fmt.Errorf("-f is required")
+	}
+
+	data, err := readFile(*file)
+	if err != nil {
+		return err
+	}
+	tree, err := repo.DecodeConfigTree(c.hierarchy, data)
+	if err != nil {
+		return err
+	}
+	result, err := c.repo.Import(ctx, tree, *changedBy)
+	if err != nil {
+		return err
+	}
+
+	if c.output == "json" {
+		return writeJSON(c.stdout, result)
+	}
+	if len(result.Applied) > 0 {
+		table := newTable(c.stdout, "LEVEL", "SCOPE", "CONFIG TYPE")
+		for _, applied := range result.Applied {
+			table.row(c.hierarchy.LevelName(applied.ConfigLevel), c.formatScope(repo.NewScope(applied.ConfigLevel, applied.Path...)), applied.ConfigType.String())
+		}
+		if err := table.flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.stdout)
+	}
+	fmt.Fprintf(c.stdout, "%d applied, %d unchanged\n", len(result.Applied), result.Unchanged)
+
+	return nil
+}
+
+//target reads the config type argument and the -scope flag most commands take
+func (c *ctl) target(args []string, scopeArg string) (entities.ConfigType, repo.Scope, error) {
+	if len(args) != 1 {
+		return entities.CONFIG_TYPE_UNSPECIFIED, repo.Scope{}, fmt.Errorf("give one config type, e.g. %s", entities.CONFIG_TYPE_DEMO_CONFIG.String())
+	}
+	configType, err := entities.ParseConfigType(args[0])
+	if err != nil {
+		return entities.CONFIG_TYPE_UNSPECIFIED, repo.Scope{}, err
+	}
+	scope, err := c.parseScope(scopeArg)
+	if err != nil {
+		return entities.CONFIG_TYPE_UNSPECIFIED, repo.Scope{}, err
+	}
+
+	return configType, scope, nil
+}
+
+//parseScope reads a -scope of slash separated IDs; the number of IDs is the level
+func (c *ctl) parseScope(arg string) (repo.Scope, error) {
+	if arg == "" {
+		return repo.Scope{}, fmt.Errorf("-scope is required")
+	}
+	ids := strings.Split(arg, "/")
+	if len(ids) > c.hierarchy.Depth() {
+		return repo.Scope{}, fmt.Errorf("-scope %q has %d IDs; the hierarchy has %d levels", arg, len(ids), c.hierarchy.Depth())
+	}
+	for _, id := range ids {
+		if id == "" {
+			return repo.Scope{}, fmt.Errorf("-scope %q has an empty ID", arg)
+		}
+	}
+
+	return repo.NewScope(entities.ConfigLevel(len(ids)), ids...), nil
+}
+
+//write stamps config as changed by changedBy now and sets it at the revision stored was read at
+func (c *ctl) write(ctx context.Context, scope repo.Scope, config, stored entities.ValidatedConfig, changedBy string) error {
+	var revision int64
+	if meta, ok := stored.(entities.MetaConfig); ok {
+		revision = meta.GetMeta().Revision
+	}
+
+	saved, err := c.repo.SetConfigIfRevision(ctx, scope, stamp(config, changedBy), revision)
+	if err != nil {
+		return err
+	}
+
+	return c.printConfig(saved)
+}
+
+//plan prints what writing config would do to the active configs at and below scope
+func (c *ctl) plan(ctx context.Context, scope repo.Scope, config entities.ValidatedConfig) error {
+	plan, err := c.repo.PlanSetConfig(ctx, scope, config)
+	if err != nil {
+		return err
+	}
+
+	if c.output == "json" {
+		return writeJSON(c.stdout, plan)
+	}
+	if len(plan.Changes)+len(plan.Shielded) > 0 {
+		table := newTable(c.stdout, "SCOPE", "EFFECT")
+		for _, change := range plan.Changes {
+			fields := make([]string, len(change.Fields))
+			for i, field := range change.Fields {
+				fields[i] = fmt.Sprintf("%s: %s -> %s", field.Path, formatValue(field.A), formatValue(field.B))
+			}
+			table.row(c.formatScope(change.Scope), strings.Join(fields, "; "))
+		}
+		for _, shielded := range plan.Shielded {
+			table.row(c.formatScope(shielded.Scope), "shielded by "+c.formatScope(shielded.ShieldedBy))
+		}
+		if err := table.flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.stdout)
+	}
+	fmt.Fprintf(c.stdout, "%d changed, %d shielded, %d unchanged; nothing written\n", len(plan.Changes), len(plan.Shielded), plan.Unchanged)
+
+	return nil
+}
+
+//stamp marks config as changed by changedBy now
+func stamp(config entities.ValidatedConfig, changedBy string) entities.ValidatedConfig {
+	if meta, ok := config.(entities.MetaConfig); ok {
+		meta.GetMeta().ChangedBy = changedBy
+		meta.GetMeta().ChangedAt = time.Now()
+	}
+
+	return config
+}
+
+//readConfigFile reads a whole config of configType from a JSON or YAML file, refusing fields the type doesn't have
+func readConfigFile(configType entities.ConfigType, path string) (entities.ValidatedConfig, error) {
+	data, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	config, err := decodeConfig(configType, jsonValue(value))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+//applyFields sets each field=value on a copy of config.  Fields are JSON names, dot separated into subdocuments, and
+//values are read as YAML scalars or flow collections, so true, 2.5, "007" and [a, b] all mean what they say
+func applyFields(config entities.ValidatedConfig, fields []string) (entities.ValidatedConfig, error) {
+	if len(fields) == 0 {
+		return config, nil
+	}
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, err
+	}
+
+	for _, field := range fields {
+		path, text, ok := cut(field, "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("--field %q: use field=value", field)
+		}
+		var value interface{}
+		if err := yaml.Unmarshal([]byte(text), &value); err != nil {
+			return nil, fmt.Errorf("--field %q: %w", field, err)
+		}
+		if err := setField(object, strings.Split(path, "."), jsonValue(value)); err != nil {
+			return nil, fmt.Errorf("--field %q: %w", field, err)
+		}
+	}
+
+	return decodeConfig(config.GetConfigType(), object)
+}
+
+func setField(object map[string]interface{}, path []string, value interface{}) error {
+	if len(path) == 1 {
+		object[path[0]] = value
+		return nil
+	}
+	child, ok := object[path[0]].(map[string]interface{})
+	if !ok {
+		if object[path[0]] != nil {
+			return fmt.Errorf("%s is not an object", path[0])
+		}
+		child = map[string]interface{}{}
+		object[path[0]] = child
+	}
+
+	return setField(child, path[1:], value)
+}
+
+//decodeConfig reads value, decoded from JSON or YAML, as a configType config through its JSON form
+func decodeConfig(configType entities.ConfigType, value interface{}) (entities.ValidatedConfig, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	config := entities.NewConfig(configType)
+	if config == nil {
+		return nil, fmt.Errorf("config type %s can't be set directly", configType.String())
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+//jsonValue turns the interface keyed maps yaml.v2 decodes into string keyed ones encoding/json can marshal
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			object[fmt.Sprint(key)] = jsonValue(item)
+		}
+		return object
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = jsonValue(item)
+		}
+		return list
+	default:
+		return v
+	}
+}
+
+func readFile(path string) ([]byte, error) {
+	if path == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+
+	return ioutil.ReadFile(path)
+}
+
+func activeOptions(asOf string) ([]repo.ActiveOption, error) {
+	t, err := parseTime("as-of", asOf)
+	if err != nil || t.IsZero() {
+		return nil, err
+	}
+
+	return []repo.ActiveOption{repo.AsOf(t)}, nil
+}
+
+func parseTime(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("-%s must be an RFC 3339 time, e.g. 2026-11-01T00:00:00Z", name)
+	}
+
+	return t, nil
+}
+
+//fieldFlags collects repeated --field flags
+type fieldFlags []string
+
+func (f *fieldFlags) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *fieldFlags) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet("configctl "+name, flag.ContinueOnError)
+}
+
+//parseArgs parses flags wherever they appear among the arguments, so `get cloud_cart --active` works as well as
+//`get --active cloud_cart`, and returns the arguments that aren't flags
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+//cut splits s around the first sep
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+
+	return s, "", false
+}
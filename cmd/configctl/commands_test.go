@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"github.com/mcquackers/config-demo/pkg/repo"
+)
+
+//newTestCtl runs commands against an empty MemoryRepo, writing to the returned buffer
+func newTestCtl(output string) (*ctl, *bytes.Buffer) {
+	var stdout bytes.Buffer
+	return &ctl{
+		repo:      repo.NewMemoryRepo(),
+		hierarchy: entities.DefaultHierarchy(),
+		output:    output,
+		stdout:    &stdout,
+	}, &stdout
+}
+
+func TestParseArgs(t *testing.T) {
+	for _, test := range []struct {
+		args       []string
+		positional []string
+		scope      string
+		active     bool
+	}{
+		{nil, nil, "", false},
+		{[]string{"cloud_cart"}, []string{"cloud_cart"}, "", false},
+		{[]string{"-scope", "1/2", "--active", "cloud_cart"}, []string{"cloud_cart"}, "1/2", true},
+		{[]string{"cloud_cart", "-scope", "1/2", "--active"}, []string{"cloud_cart"}, "1/2", true},
+		{[]string{"cloud_cart", "-scope=1", "other_example", "--active=false"}, []string{"cloud_cart", "other_example"}, "1", false},
+	} {
+		flags := newFlagSet("test")
+		scope := flags.String("scope", "", "")
+		active := flags.Bool("active", false, "")
+		positional, err := parseArgs(flags, test.args)
+		if err != nil {
+			t.Errorf("%q: %v", test.args, err)
+			continue
+		}
+		if fmt.Sprint(positional) != fmt.Sprint(test.positional) || *scope != test.scope || *active != test.active {
+			t.Errorf("%q: got %q, -scope %q and --active %t, want %q, %q and %t", test.args, positional, *scope, *active, test.positional, test.scope, test.active)
+		}
+	}
+
+	for _, args := range [][]string{{"cloud_cart", "--no-such-flag"}, {"cloud_cart", "-scope"}} {
+		flags := newFlagSet("test")
+		flags.SetOutput(ioutil.Discard)
+		flags.String("scope", "", "")
+		if positional, err := parseArgs(flags, args); err == nil {
+			t.Errorf("%q parsed as %q", args, positional)
+		}
+	}
+}
+
+func TestParseScope(t *testing.T) {
+	c, _ := newTestCtl("table")
+	for _, test := range []struct {
+		arg  string
+		want repo.Scope
+	}{
+		{"1", repo.CorporateScope("1")},
+		{"1/2", repo.VenueScope("1", "2")},
+		{"1/2/3", repo.VendorScope("1", "2", "3")},
+	} {
+		got, err := c.parseScope(test.arg)
+		if err != nil {
+			t.Errorf("%q: %v", test.arg, err)
+		} else if got != test.want {
+			t.Errorf("%q: got %s, want %s", test.arg, got.String(), test.want.String())
+		}
+	}
+	for _, arg := range []string{"", "1/2/3/4", "1//3", "1/", "/2"} {
+		if got, err := c.parseScope(arg); err == nil {
+			t.Errorf("%q parsed as %s", arg, got.String())
+		}
+	}
+
+	//The number of levels comes from the hierarchy
+	deep, err := entities.HierarchyFromNames("corporate", "region=cloud_cart", "venue", "vendor", "terminal=other_example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.hierarchy = deep
+	if got, err := c.parseScope("1/2/3/4/5"); err != nil || got != repo.NewScope(5, "1", "2", "3", "4", "5") {
+		t.Errorf("a terminal scope parsed as %s, %v", got.String(), err)
+	}
+
+	//target takes the config type with it
+	configType, scope, err := c.target([]string{"other_example"}, "1/2/3/4")
+	if err != nil || configType != entities.CONFIG_TYPE_OTHER_EXAMPLE || scope != repo.NewScope(4, "1", "2", "3", "4") {
+		t.Errorf("got %s at %s, %v", configType.String(), scope.String(), err)
+	}
+	for _, args := range [][]string{nil, {"cloud_cart", "other_example"}, {"no_such_config"}} {
+		if _, _, err := c.target(args, "1"); err == nil {
+			t.Errorf("%q made a target", args)
+		}
+	}
+}
+
+func TestApplyFields(t *testing.T) {
+	original := &entities.CloudCartConfig{ConfigMeta: entities.ConfigMeta{ChangedBy: "alice", Revision: 3}}
+	config, err := applyFields(original, []string{
+		"meta.enabled=true",
+		"enable_validate_prices=yes",
+		"meta.overrides=[enable_validate_prices, enable_validate_cart_sums]",
+		"meta.rollouts=[{field: enable_validate_prices, percent: 25}]",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cloudCart := config.(*entities.CloudCartConfig)
+	if !cloudCart.Enabled || !cloudCart.EnableValidatePrices || cloudCart.ChangedBy != "alice" || cloudCart.Revision != 3 {
+		t.Errorf("got %+v", cloudCart)
+	}
+	if fmt.Sprint(cloudCart.Overrides) != "[enable_validate_prices enable_validate_cart_sums]" {
+		t.Errorf("got overrides %q", cloudCart.Overrides)
+	}
+	if len(cloudCart.Rollouts) != 1 || cloudCart.Rollouts[0] != (entities.FieldRollout{Field: "enable_validate_prices", Percent: 25}) {
+		t.Errorf("got rollouts %+v", cloudCart.Rollouts)
+	}
+	if original.Enabled || original.EnableValidatePrices {
+		t.Errorf("the original was changed to %+v", original)
+	}
+
+	other, err := applyFields(&entities.OtherConfig{}, []string{`a_different_value="007"`, "a_float=2.5", "meta.changed_by=ops=team"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := other.(*entities.OtherConfig); got.ADifferentValue != "007" || got.AFloat != 2.5 || got.ChangedBy != "ops=team" {
+		t.Errorf("got %+v", got)
+	}
+
+	for _, field := range []string{
+		"enabled",
+		"=true",
+		"no_such_field=1",
+		"meta.enabled.deeper=true",
+		"enable_validate_prices=[",
+		"enable_validate_prices=2.5",
+		"meta.changed_by=[a, b]",
+	} {
+		if got, err := applyFields(original, []string{field}); err == nil {
+			t.Errorf("--field %q applied as %+v", field, got)
+		}
+	}
+	if got, err := applyFields(original, nil); err != nil || got != original {
+		t.Errorf("no fields gave %+v, %v; want the config itself", got, err)
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	for _, path := range []string{
+		write("config.json", `{"meta": {"enabled": true, "overrides": ["a_float"]}, "a_different_value": "x", "a_float": 7.5}`),
+		write("config.yaml", "meta:\n  enabled: true\n  overrides: [a_float]\na_different_value: x\na_float: 7.5\n"),
+	} {
+		config, err := readConfigFile(entities.CONFIG_TYPE_OTHER_EXAMPLE, path)
+		if err != nil {
+			t.Fatalf("%s: %v", filepath.Base(path), err)
+		}
+		got := config.(*entities.OtherConfig)
+		if !got.Enabled || fmt.Sprint(got.Overrides) != "[a_float]" || got.ADifferentValue != "x" || got.AFloat != 7.5 {
+			t.Errorf("%s: got %+v", filepath.Base(path), got)
+		}
+	}
+
+	for _, test := range []struct {
+		configType entities.ConfigType
+		path       string
+	}{
+		{entities.CONFIG_TYPE_OTHER_EXAMPLE, write("unknown.yaml", "a_float: 1\nenable_validate_prices: true\n")},
+		{entities.CONFIG_TYPE_OTHER_EXAMPLE, write("mistyped.json", `{"a_float": "high"}`)},
+		{entities.CONFIG_TYPE_OTHER_EXAMPLE, write("malformed.yaml", "a_float: [1\n")},
+		{entities.CONFIG_TYPE_FULL, write("full.yaml", "{}\n")},
+		{entities.CONFIG_TYPE_OTHER_EXAMPLE, filepath.Join(dir, "missing.yaml")},
+	} {
+		if got, err := readConfigFile(test.configType, test.path); err == nil {
+			t.Errorf("%s read as %+v", filepath.Base(test.path), got)
+		}
+	}
+}
+
+func TestSetThenGet(t *testing.T) {
+	ctx := context.Background()
+	c, stdout := newTestCtl("json")
+
+	err := runSet(ctx, c, []string{"cloud_cart", "-scope", "1", "--field", "meta.enabled=true", "--field", "enable_validate_prices=true", "-changed-by", "ops"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var set entities.CloudCartConfig
+	if err := json.Unmarshal(stdout.Bytes(), &set); err != nil {
+		t.Fatalf("decoding %s: %v", stdout.String(), err)
+	}
+	if set.Revision != 1 || set.ChangedBy != "ops" || set.ChangedAt.IsZero() {
+		t.Errorf("set printed %+v", set)
+	}
+
+	//The vendor gets the corporate's config, printed as a table
+	c.output = "table"
+	stdout.Reset()
+	if err := runGet(ctx, c, []string{"cloud_cart", "-scope", "1/2/3", "--active"}); err != nil {
+		t.Fatal(err)
+	}
+	rows := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		cells := strings.Fields(line)
+		if len(cells) != 2 {
+			t.Fatalf("row %q has %d cells", line, len(cells))
+		}
+		rows[cells[0]] = cells[1]
+	}
+	for field, want := range map[string]string{
+		"FIELD":                     "VALUE",
+		"meta.enabled":              "true",
+		"meta.changed_by":           "ops",
+		"meta.revision":             "1",
+		"enable_validate_prices":    "true",
+		"enable_validate_cart_sums": "false",
+	} {
+		if rows[field] != want {
+			t.Errorf("%s is %q, want %q in\n%s", field, rows[field], want, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,161 @@
+//Command configctl reads and writes configs in MongoDB through the repository, for operators.  Scopes are given as
+//their IDs from the top level down, e.g. -scope 1/2 for venue 2 of corporate 1 in the default hierarchy.
+//
+//	configctl get cloud_cart -scope 1/2 [--active] [-as-of 2026-11-01T00:00:00Z]
+//	configctl set cloud_cart -scope 1/2 [-f config.yaml] [--field enable_validate_prices=true ...] -changed-by ops [-dry-run]
+//	configctl enable|disable cloud_cart -scope 1/2 -changed-by ops
+//	configctl history cloud_cart -scope 1/2 [-limit 20]
+//	configctl explain cloud_cart -scope 1/2/3
+//	configctl diff cloud_cart -scope 1/2 (-against 1 | --active | -since 2026-10-01T00:00:00Z)
+//	configctl export 1 [-f corporate-1.yaml]
+//	configctl import -f corporate-1.yaml [-changed-by ops]
+//
+//Output is a table, or JSON with -o json; export writes YAML unless -o json
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"github.com/mcquackers/config-demo/pkg/repo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+//ctl is what every command runs with
+type ctl struct {
+	repo      repo.ConfigRepository
+	hierarchy *entities.Hierarchy
+	//output is "table" or "json"
+	output string
+	stdout io.Writer
+}
+
+//command runs one subcommand with the arguments after its name
+type command func(ctx context.Context, c *ctl, args []string) error
+
+var commands = map[string]command{
+	"get":     runGet,
+	"set":     runSet,
+	"enable":  runEnable,
+	"disable": runDisable,
+	"history": runHistory,
+	"explain": runExplain,
+	"diff":    runDiff,
+	"export":  runExport,
+	"import":  runImport,
+}
+
+func main() {
+	flag.Usage = usage
+	mongoHosts := flag.String("mongo-hosts", "localhost:27017", "comma separated MongoDB hosts")
+	replicaSet := flag.String("replica-set", "testRepl", "MongoDB replica set name")
+	database := flag.String("database", "config-demo", "MongoDB database")
+	collection := flag.String("collection", "configs", "configs collection")
+	historyCollection := flag.String("history-collection", "config_history", "config history collection")
+	tenants := flag.String("tenants", "", "multi-tenant routing: database for a config-<tenant> database per tenant, prefix for <tenant>_ prefixed collections; empty for a single tenant")
+	tenantID := flag.String("tenant", "", "tenant to act for; required with -tenants")
+	hierarchyLevels := flag.String("hierarchy", "corporate,venue,vendor", "comma separated config levels, most general first; each level's ID field is <level>_id, and <level>=<type>+<type> lists the config types it allows, which levels other than corporate, venue and vendor must")
+	output := flag.String("o", "table", "output format: table or json")
+	timeout := flag.Duration("timeout", 30*time.Second, "how long the command may take")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	run, ok := commands[flag.Arg(0)]
+	if !ok {
+		fatalf("unknown command %q", flag.Arg(0))
+	}
+	if *output != "table" && *output != "json" {
+		fatalf("unknown output format %q: use table or json", *output)
+	}
+
+	hierarchy, err := entities.HierarchyFromNames(strings.Split(*hierarchyLevels, ",")...)
+	if err != nil {
+		fatalf("%s", err.Error())
+	}
+	resolver, err := repo.TenantResolverForMode(*tenants)
+	if err != nil {
+		fatalf("%s", err.Error())
+	}
+	if (resolver != nil) != (*tenantID != "") {
+		fatalf("-tenant and -tenants go together")
+	}
+	repoOpts := []repo.RepoOption{repo.WithHierarchy(hierarchy), repo.WithDatabase(*database), repo.WithCollection(*collection), repo.WithHistoryCollection(*historyCollection)}
+	if resolver != nil {
+		repoOpts = append(repoOpts, repo.WithTenantResolver(resolver))
+	}
+
+	client, err := setUpClient(strings.Split(*mongoHosts, ","), *replicaSet)
+	if err != nil {
+		fatalf("%s", err.Error())
+	}
+	defer client.Disconnect(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if *tenantID != "" {
+		ctx = repo.WithTenant(ctx, *tenantID)
+	}
+
+	configRepo := repo.NewMDBRepo(client, repoOpts...)
+	c := &ctl{
+		repo:      &configRepo,
+		hierarchy: hierarchy,
+		output:    *output,
+		stdout:    os.Stdout,
+	}
+	if err := run(ctx, c, flag.Args()[1:]); err != nil {
+		client.Disconnect(context.Background())
+		fatalf("%s: %s", flag.Arg(0), err.Error())
+	}
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(flag.CommandLine.Output(), "usage: configctl [flags] <command> [command flags]\n\ncommands: %s\n\nflags:\n", strings.Join(names, ", "))
+	flag.PrintDefaults()
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "configctl: "+format+"\n", args...)
+	os.Exit(1)
+}
+
+
+func setUpClient(hosts []string, replicaSet string) (*mongo.Client, error) {
+	mdbConnectionOpts := options.Client().
+		SetConnectTimeout(5 * time.Second).
+		SetHosts(hosts).
+		SetReplicaSet(replicaSet)
+
+	mdbClient, err := mongo.NewClient(mdbConnectionOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := mdbClient.Connect(context.Background()); err != nil {
+		return nil, err
+	}
+
+	if err := mdbClient.Ping(context.Background(), readpref.PrimaryPreferred()); err != nil {
+		return nil, err
+	}
+
+	return mdbClient, nil
+}
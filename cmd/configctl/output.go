@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"github.com/mcquackers/config-demo/pkg/repo"
+)
+
+//table writes aligned columns under a header
+type table struct {
+	w *tabwriter.Writer
+}
+
+func newTable(w io.Writer, header ...string) *table {
+	t := &table{w: tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)}
+	t.row(header...)
+
+	return t
+}
+
+func (t *table) row(cells ...string) {
+	for i, cell := range cells {
+		if cell == "" {
+			cells[i] = "-"
+		}
+	}
+	fmt.Fprintln(t.w, strings.Join(cells, "\t"))
+}
+
+func (t *table) flush() error {
+	return t.w.Flush()
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
+
+//printConfig writes a config as JSON or as one row per field
+func (c *ctl) printConfig(config entities.ValidatedConfig) error {
+	if c.output == "json" {
+		return writeJSON(c.stdout, config)
+	}
+	fields, err := flattenConfig(config)
+	if err != nil {
+		return err
+	}
+	table := newTable(c.stdout, "FIELD", "VALUE")
+	for _, field := range fields {
+		table.row(field.path, field.value)
+	}
+
+	return table.flush()
+}
+
+//configField is a config field's dot separated JSON path and its value as text
+type configField struct {
+	path  string
+	value string
+}
+
+//flattenConfig lists config's fields in the order its JSON form has them, subdocuments flattened into dotted paths and
+//lists written as JSON
+func flattenConfig(config entities.ValidatedConfig) ([]configField, error) {
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var fields []configField
+	var flatten func(path string) error
+	flatten = func(path string) error {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'):
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				if err := flatten(joinField(path, key.(string))); err != nil {
+					return err
+				}
+			}
+			_, err = decoder.Token()
+			return err
+		case json.Delim('['):
+			var list []json.RawMessage
+			for decoder.More() {
+				var item json.RawMessage
+				if err := decoder.Decode(&item); err != nil {
+					return err
+				}
+				list = append(list, item)
+			}
+			if _, err := decoder.Token(); err != nil {
+				return err
+			}
+			text, err := json.Marshal(list)
+			if err != nil {
+				return err
+			}
+			fields = append(fields, configField{path: path, value: string(text)})
+			return nil
+		case nil:
+			fields = append(fields, configField{path: path, value: "null"})
+			return nil
+		default:
+			fields = append(fields, configField{path: path, value: fmt.Sprint(token)})
+			return nil
+		}
+	}
+	if err := flatten(""); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+func joinField(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+//describeChange summarises a history entry: created, deleted, or the fields that changed, leaving out the meta the
+//repository maintains on every write
+func describeChange(before, after entities.ValidatedConfig) (string, error) {
+	switch {
+	case before == nil && after == nil:
+		return "", nil
+	case before == nil:
+		return "created", nil
+	case after == nil:
+		return "deleted", nil
+	}
+
+	diffs, err := entities.Diff(before, after, entities.IgnoreWriteMeta())
+	if err != nil {
+		return "", err
+	}
+	if len(diffs) == 0 {
+		return "no change", nil
+	}
+	changes := make([]string, len(diffs))
+	for i, diff := range diffs {
+		changes[i] = fmt.Sprintf("%s: %s -> %s", diff.Path, formatValue(diff.A), formatValue(diff.B))
+	}
+
+	return strings.Join(changes, "; "), nil
+}
+
+//formatValue writes a FieldDiff value: unset for a missing field, times in RFC 3339 and lists as JSON
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "unset"
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []interface{}:
+		text, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(text)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+//formatScope writes a scope with its level names, e.g. corporate 1 / venue 2
+func (c *ctl) formatScope(scope repo.Scope) string {
+	parts := make([]string, 0, scope.ConfigLevel)
+	for level := entities.ConfigLevel(entities.CONFIG_LEVEL_CORPORATE); level <= scope.ConfigLevel; level++ {
+		parts = append(parts, fmt.Sprintf("%s %s", c.hierarchy.LevelName(level), scope.ID(level)))
+	}
+
+	return strings.Join(parts, " / ")
+}
+
+//formatIDs is formatScope for IDs keyed by the hierarchy's ID fields
+func (c *ctl) formatIDs(configLevel entities.ConfigLevel, ids map[string]string) string {
+	path := make([]string, 0, configLevel)
+	for level := entities.ConfigLevel(entities.CONFIG_LEVEL_CORPORATE); level <= configLevel; level++ {
+		path = append(path, ids[c.hierarchy.IDField(level)])
+	}
+
+	return c.formatScope(repo.NewScope(configLevel, path...))
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
@@ -2,4 +2,9 @@ module github.com/mcquackers/config-demo
 
 go 1.15
 
-require go.mongodb.org/mongo-driver v1.4.3
+require (
+	go.mongodb.org/mongo-driver v1.4.3
+	google.golang.org/grpc v1.33.2
+	google.golang.org/protobuf v1.26.0
+	gopkg.in/yaml.v2 v2.3.0
+)
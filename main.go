@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -15,14 +16,17 @@ import (
 )
 
 func main() {
+	demoCorpID := "1"
+	demoVenueID := "2"
+	demoVendorID := "3"
+	corporateScope := repo.CorporateScope(demoCorpID)
+	venueScope := repo.VenueScope(demoCorpID, demoVenueID)
+	vendorScope := repo.VendorScope(demoCorpID, demoVenueID, demoVendorID)
+	otherVendorScope := repo.VendorScope("5", "6", "7")
 
 	client := mustSetUpClient()
 	repo := repo.NewMDBRepo(client)
 	fmt.Println("client set up")
-
-	demoCorpID := "1"
-	demoVenueID := "2"
-	demoVendorID := "3"
 	demoConfigVendor := &entities.CloudCartConfig{
 		ConfigMeta: entities.ConfigMeta{
 			Enabled:   false,
@@ -57,7 +61,7 @@ func main() {
 	}
 
 	fmt.Println("Retrieve unset configuration")
-	returnConf, err := repo.GetSpecificConfig(context.Background(), entities.CONFIG_LEVEL_CORPORATE, demoCorpID, demoVenueID, demoVendorID, entities.CONFIG_TYPE_DEMO_CONFIG)
+	returnConf, err := repo.GetSpecificConfig(context.Background(), corporateScope, entities.CONFIG_TYPE_DEMO_CONFIG)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
@@ -66,17 +70,18 @@ func main() {
 	fmt.Println("=================================")
 
 
-	fmt.Println("Upsert new config vendor level - inactive")
-	returnConf, err = repo.SetConfig(context.Background(), entities.CONFIG_LEVEL_VENDOR, demoCorpID, demoVenueID, demoVendorID, demoConfigVendor)
-	if err != nil {
-		log.Fatal(err.Error())
+	fmt.Println("Upsert new config vendor level - rejected, cloud cart is not associated with the vendor level")
+	_, err = repo.SetConfig(context.Background(), vendorScope, demoConfigVendor)
+	var notAllowed entities.ErrConfigTypeNotAllowedAtLevel
+	if !errors.As(err, &notAllowed) {
+		log.Fatalf("expected ErrConfigTypeNotAllowedAtLevel, got %v", err)
 	}
 	fmt.Println("=================================")
-	fmt.Printf("%+v\n", returnConf)
+	fmt.Println(err.Error())
 	fmt.Println("=================================")
 
 	fmt.Println("Retrieve MAIN config")
-	fullConf, err := repo.GetSpecificConfig(context.Background(), entities.CONFIG_LEVEL_VENDOR, demoCorpID, demoVenueID, demoVendorID, entities.CONFIG_TYPE_FULL)
+	fullConf, err := repo.GetSpecificConfig(context.Background(), vendorScope, entities.CONFIG_TYPE_FULL)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
@@ -96,7 +101,7 @@ func main() {
 	}
 
 	fmt.Println("Set new config value on existing main config")
-	returnConf, err = repo.SetConfig(context.Background(), entities.CONFIG_LEVEL_VENDOR, demoCorpID, demoVenueID, demoVendorID, demoConfig2)
+	returnConf, err = repo.SetConfig(context.Background(), vendorScope, demoConfig2)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
@@ -106,7 +111,7 @@ func main() {
 	fmt.Println("=================================")
 
 	fmt.Println("Retrieve MAIN config")
-	fullConf, err = repo.GetSpecificConfig(context.Background(), entities.CONFIG_LEVEL_VENDOR, demoCorpID, demoVenueID, demoVendorID, entities.CONFIG_TYPE_FULL)
+	fullConf, err = repo.GetSpecificConfig(context.Background(), vendorScope, entities.CONFIG_TYPE_FULL)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
@@ -116,13 +121,13 @@ func main() {
 	fmt.Println("=================================")
 
 	fmt.Println("Retrieve OtherConfig")
-	otherConf, err := repo.GetSpecificConfig(context.Background(), entities.CONFIG_LEVEL_VENDOR, demoCorpID, demoVenueID, demoVendorID, entities.CONFIG_TYPE_OTHER_EXAMPLE)
+	otherConf, err := repo.GetSpecificConfig(context.Background(), vendorScope, entities.CONFIG_TYPE_OTHER_EXAMPLE)
 	fmt.Println("=================================")
 	fmt.Printf("%+v\n", otherConf)
 	fmt.Println("=================================")
 
 	fmt.Println("retrieve active configuration starting with vendor - no active expected")
-	ccConf, err := repo.GetActiveConfig(context.Background(), entities.CONFIG_LEVEL_VENUE, demoCorpID, demoVenueID, demoVendorID, entities.CONFIG_TYPE_DEMO_CONFIG)
+	ccConf, err := repo.GetActiveConfig(context.Background(), venueScope, entities.CONFIG_TYPE_DEMO_CONFIG)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
@@ -131,13 +136,13 @@ func main() {
 	fmt.Println("=================================")
 
 	fmt.Println("Set Active Venue level config")
-	_,err = repo.SetConfig(context.Background(), entities.CONFIG_LEVEL_VENUE, demoCorpID, demoVenueID, demoVendorID, demoConfigVenue)
+	_,err = repo.SetConfig(context.Background(), venueScope, demoConfigVenue)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
 
 	fmt.Println("Attempt to retrieve active vendor level demo config; expect venue level config")
-	ccConf, err = repo.GetActiveConfig(context.Background(), entities.CONFIG_LEVEL_VENDOR, demoCorpID, demoVenueID, demoVendorID, entities.CONFIG_TYPE_DEMO_CONFIG)
+	ccConf, err = repo.GetActiveConfig(context.Background(), vendorScope, entities.CONFIG_TYPE_DEMO_CONFIG)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
@@ -146,11 +151,11 @@ func main() {
 	fmt.Println("=================================")
 
 	fmt.Println("Set corporate level demo config - active")
-	_,_ = repo.SetConfig(context.Background(), entities.CONFIG_LEVEL_CORPORATE, demoCorpID, demoVenueID, demoVendorID, demoConfigCorporate)
+	_,_ = repo.SetConfig(context.Background(), corporateScope, demoConfigCorporate)
 
 
 	fmt.Println("Attempt to retrieve active vendor level demo config; expect venue level config")
-	dc, err := repo.GetActiveConfig(context.Background(), entities.CONFIG_LEVEL_VENDOR, demoCorpID, demoVenueID, demoVendorID, entities.CONFIG_TYPE_DEMO_CONFIG)
+	dc, err := repo.GetActiveConfig(context.Background(), vendorScope, entities.CONFIG_TYPE_DEMO_CONFIG)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
@@ -160,21 +165,21 @@ func main() {
 
 	fmt.Println("Disable venue level demo config")
 	demoConfigVenue.ConfigMeta.Enabled = false
-	ccConf, _ = repo.SetConfig(context.Background(), entities.CONFIG_LEVEL_VENUE, demoCorpID, demoVenueID, demoVendorID, demoConfigVenue)
+	ccConf, _ = repo.SetConfig(context.Background(), venueScope, demoConfigVenue)
 	fmt.Println("Venue level demo config")
 	fmt.Println("=================================")
 	fmt.Printf("%+v\n", ccConf)
 	fmt.Println("=================================")
 
 	fmt.Println("Attempt to retrieve active vendor level demo config; expect corporate level config")
-	dc, err = repo.GetActiveConfig(context.Background(), entities.CONFIG_LEVEL_VENDOR, demoCorpID, demoVenueID, demoVendorID, entities.CONFIG_TYPE_DEMO_CONFIG)
+	dc, err = repo.GetActiveConfig(context.Background(), vendorScope, entities.CONFIG_TYPE_DEMO_CONFIG)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
 	fmt.Println("=================================")
 	fmt.Printf("%+v\n", dc)
 	fmt.Println("=================================")
-	uc, err := repo.GetActiveConfig(context.Background(), entities.CONFIG_LEVEL_VENDOR, "5", "6", "7", entities.CONFIG_TYPE_OTHER_EXAMPLE)
+	uc, err := repo.GetActiveConfig(context.Background(), otherVendorScope, entities.CONFIG_TYPE_OTHER_EXAMPLE)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,43 @@
+package entities
+
+const CONFIG_TYPE_DEMO_CONFIG ConfigType = 2
+
+func init() {
+	Register(ConfigTypeDescriptor{
+		ID:  CONFIG_TYPE_DEMO_CONFIG,
+		Key: "cloud_cart",
+		New: func() ValidatedConfig {
+			return &CloudCartConfig{}
+		},
+		AllowedLevels: []ConfigLevel{CONFIG_LEVEL_CORPORATE, CONFIG_LEVEL_VENUE},
+	})
+}
+
+type CloudCartConfig struct {
+	ConfigMeta                        `bson:"meta" json:"meta"`
+	EnableCalculateReductionsAndTaxes bool `bson:"enable_calculate_reductions_and_taxes" json:"enable_calculate_reductions_and_taxes"`
+	EnableValidatePrices              bool `bson:"enable_validate_prices" json:"enable_validate_prices"`
+	EnableValidateCartSums            bool `bson:"enable_validate_cart_sums" json:"enable_validate_cart_sums"`
+}
+
+//Cart sums can only be validated once reductions and taxes have been calculated
+func (c *CloudCartConfig) Validate() error {
+	if c.EnableValidateCartSums && !c.EnableCalculateReductionsAndTaxes {
+		return &ValidationError{Errors: []FieldError{{
+			Path:    "cloud_cart.enable_validate_cart_sums",
+			Message: "requires enable_calculate_reductions_and_taxes",
+		}}}
+	}
+
+	return nil
+}
+
+func (c *CloudCartConfig) GetConfigType() ConfigType {
+	return CONFIG_TYPE_DEMO_CONFIG
+}
+
+//CloudCart is the set's cloud_cart config, or nil if it has none
+func (s ConfigSet) CloudCart() *CloudCartConfig {
+	config, _ := s.Get(CONFIG_TYPE_DEMO_CONFIG).(*CloudCartConfig)
+	return config
+}
@@ -0,0 +1,174 @@
+package entities
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//FieldDiff is one field that differs between two configs.  Path is its bson name, dotted into subdocuments, e.g.
+//meta.enabled.  A and B are the values as stored, with datetimes as time.Time; nil means that side doesn't have the
+//field at all, e.g. an omitted meta.effective_from
+type FieldDiff struct {
+	Path string      `json:"path"`
+	A    interface{} `json:"a"`
+	B    interface{} `json:"b"`
+}
+
+func (d FieldDiff) String() string {
+	return fmt.Sprintf("%s: %v -> %v", d.Path, d.A, d.B)
+}
+
+type diffOptions struct {
+	ignored []string
+}
+
+//DiffOption tunes Diff
+type DiffOption func(*diffOptions)
+
+//IgnoreFields leaves the given paths, and everything under them, out of a Diff; IgnoreFields("meta") compares the
+//payloads alone
+func IgnoreFields(paths ...string) DiffOption {
+	return func(o *diffOptions) {
+		o.ignored = append(o.ignored, paths...)
+	}
+}
+
+//IgnoreWriteMeta leaves out the meta fields every write changes, revision, changed_by and changed_at, so a Diff shows
+//what a config does rather than who wrote it
+func IgnoreWriteMeta() DiffOption {
+	return IgnoreFields("meta.revision", "meta.changed_by", "meta.changed_at")
+}
+
+func (o diffOptions) ignores(path string) bool {
+	for _, ignored := range o.ignored {
+		if path == ignored || strings.HasPrefix(path, ignored+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+//Diff lists the fields that differ between a and b, in the order the config declares them.  Either may be nil, which
+//diffs against an empty config of the other's type; both must otherwise be of the same type
+func Diff(a, b ValidatedConfig, opts ...DiffOption) ([]FieldDiff, error) {
+	var diffOpts diffOptions
+	for _, opt := range opts {
+		opt(&diffOpts)
+	}
+
+	switch {
+	case a == nil && b == nil:
+		return nil, nil
+	case a == nil:
+		a = NewConfig(b.GetConfigType())
+	case b == nil:
+		b = NewConfig(a.GetConfigType())
+	}
+	if a == nil || b == nil {
+		return nil, fmt.Errorf("unsupported config type")
+	}
+	if a.GetConfigType() != b.GetConfigType() {
+		return nil, fmt.Errorf("can't diff config type %s against %s", a.GetConfigType().String(), b.GetConfigType().String())
+	}
+
+	docA, err := toDocument(a)
+	if err != nil {
+		return nil, err
+	}
+	docB, err := toDocument(b)
+	if err != nil {
+		return nil, err
+	}
+	fieldsA := flattenDocument("", docA, nil)
+	fieldsB := flattenDocument("", docB, nil)
+
+	valuesA := make(map[string]interface{}, len(fieldsA))
+	for _, field := range fieldsA {
+		valuesA[field.Key] = field.Value
+	}
+	valuesB := make(map[string]interface{}, len(fieldsB))
+	for _, field := range fieldsB {
+		valuesB[field.Key] = field.Value
+	}
+
+	var diffs []FieldDiff
+	for _, path := range mergeFieldOrder(fieldsA, fieldsB) {
+		valueA, okA := valuesA[path]
+		valueB, okB := valuesB[path]
+		if diffOpts.ignores(path) || okA && okB && reflect.DeepEqual(valueA, valueB) {
+			continue
+		}
+		diffs = append(diffs, FieldDiff{Path: path, A: diffValue(valueA), B: diffValue(valueB)})
+	}
+
+	return diffs, nil
+}
+
+//flattenDocument appends doc's fields to fields with dotted keys, descending into subdocuments but not arrays
+func flattenDocument(prefix string, doc bson.D, fields bson.D) bson.D {
+	for _, elem := range doc {
+		key := joinPath(prefix, elem.Key)
+		if sub, ok := elem.Value.(bson.D); ok {
+			fields = flattenDocument(key, sub, fields)
+			continue
+		}
+		fields = append(fields, bson.E{Key: key, Value: elem.Value})
+	}
+
+	return fields
+}
+
+//mergeFieldOrder lists the paths of a and b once each, a's in order with each path only b has placed after the one
+//before it in b, so optional fields keep their declared place
+func mergeFieldOrder(a, b bson.D) []string {
+	order := make([]string, 0, len(a)+len(b))
+	position := make(map[string]int, len(a)+len(b))
+	for _, field := range a {
+		position[field.Key] = len(order)
+		order = append(order, field.Key)
+	}
+
+	insertAt := 0
+	for _, field := range b {
+		if i, ok := position[field.Key]; ok {
+			insertAt = i + 1
+			continue
+		}
+		order = append(order, "")
+		copy(order[insertAt+1:], order[insertAt:])
+		order[insertAt] = field.Key
+		for i := insertAt; i < len(order); i++ {
+			position[order[i]] = i
+		}
+		insertAt++
+	}
+
+	return order
+}
+
+//diffValue converts the bson types a decoded document holds into ones that print and marshal plainly
+func diffValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case primitive.DateTime:
+		return v.Time().UTC()
+	case bson.A:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = diffValue(item)
+		}
+		return list
+	case bson.D:
+		object := make(map[string]interface{}, len(v))
+		for _, elem := range v {
+			object[elem.Key] = diffValue(elem.Value)
+		}
+		return object
+	default:
+		return v
+	}
+}
@@ -0,0 +1,132 @@
+package entities
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDiff(t *testing.T) {
+	changedAt := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	effectiveFrom := changedAt.Add(time.Hour)
+	cloudCart := func(changedBy string, validatePrices bool) *CloudCartConfig {
+		return &CloudCartConfig{ConfigMeta: ConfigMeta{Enabled: true, ChangedBy: changedBy, ChangedAt: changedAt}, EnableValidatePrices: validatePrices}
+	}
+	scheduled := cloudCart("CORP", false)
+	scheduled.EffectiveFrom = &effectiveFrom
+
+	for _, test := range []struct {
+		name string
+		a, b ValidatedConfig
+		opts []DiffOption
+		want []FieldDiff
+	}{
+		{
+			name: "equal configs",
+			a:    cloudCart("CORP", true),
+			b:    cloudCart("CORP", true),
+		},
+		{
+			name: "fields in declaration order",
+			a:    cloudCart("CORP", true),
+			b:    cloudCart("VENUE", false),
+			want: []FieldDiff{{"meta.changed_by", "CORP", "VENUE"}, {"enable_validate_prices", true, false}},
+		},
+		{
+			name: "a field only one side has",
+			a:    cloudCart("CORP", true),
+			b:    scheduled,
+			want: []FieldDiff{{"meta.effective_from", nil, effectiveFrom}, {"enable_validate_prices", true, false}},
+		},
+		{
+			name: "against nil",
+			a:    nil,
+			b:    cloudCart("CORP", true),
+			want: []FieldDiff{
+				{"meta.enabled", false, true},
+				{"meta.changed_by", "", "CORP"},
+				{"meta.changed_at", time.Time{}, changedAt},
+				{"enable_validate_prices", false, true},
+			},
+		},
+		{
+			name: "both nil",
+		},
+		{
+			name: "ignoring write meta",
+			a:    cloudCart("CORP", true),
+			b:    &CloudCartConfig{ConfigMeta: ConfigMeta{Enabled: true, ChangedBy: "VENUE", ChangedAt: changedAt.Add(time.Hour), Revision: 4}, EnableValidatePrices: true},
+			opts: []DiffOption{IgnoreWriteMeta()},
+		},
+		{
+			name: "ignoring meta",
+			a:    cloudCart("CORP", true),
+			b:    scheduled,
+			opts: []DiffOption{IgnoreFields("meta")},
+			want: []FieldDiff{{"enable_validate_prices", true, false}},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Diff(test.a, test.b, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDiffTimesAreUTC(t *testing.T) {
+	local := time.FixedZone("UTC+2", 2*60*60)
+	a := &CloudCartConfig{ConfigMeta: ConfigMeta{ChangedAt: time.Date(2026, 3, 1, 9, 30, 0, 0, local)}}
+	diffs, err := Diff(a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || diffs[0].Path != "meta.changed_at" {
+		t.Fatalf("got %v, want meta.changed_at alone", diffs)
+	}
+	if got, ok := diffs[0].A.(time.Time); !ok || got.Location() != time.UTC || !got.Equal(a.ChangedAt) {
+		t.Errorf("changed_at diffs as %#v, want %s in UTC", diffs[0].A, a.ChangedAt)
+	}
+}
+
+func TestDiffRefusesDifferentTypes(t *testing.T) {
+	if _, err := Diff(&CloudCartConfig{}, &OtherConfig{}); err == nil {
+		t.Error("diffing cloud_cart against other_example succeeded")
+	}
+}
+
+func TestMergeFieldOrder(t *testing.T) {
+	fields := func(keys ...string) bson.D {
+		doc := make(bson.D, 0, len(keys))
+		for _, key := range keys {
+			doc = append(doc, bson.E{Key: key})
+		}
+		return doc
+	}
+
+	for _, test := range []struct {
+		name string
+		a, b bson.D
+		want []string
+	}{
+		{"same fields", fields("x", "y"), fields("x", "y"), []string{"x", "y"}},
+		{"only in b, between", fields("x", "y", "z"), fields("x", "n", "y"), []string{"x", "n", "y", "z"}},
+		{"only in b, first", fields("y", "z"), fields("n", "y"), []string{"n", "y", "z"}},
+		{"only in b, last", fields("x", "y"), fields("x", "y", "n"), []string{"x", "y", "n"}},
+		{"only in b, a run", fields("x", "z"), fields("x", "n", "m", "z"), []string{"x", "n", "m", "z"}},
+		{"only in a", fields("x", "n", "y"), fields("x", "y"), []string{"x", "n", "y"}},
+		{"both sides have their own", fields("x", "a", "y"), fields("x", "y", "b"), []string{"x", "a", "y", "b"}},
+		{"a empty", nil, fields("x", "y"), []string{"x", "y"}},
+		{"b empty", fields("x", "y"), nil, []string{"x", "y"}},
+	} {
+		if got := mergeFieldOrder(test.a, test.b); fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
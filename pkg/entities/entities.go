@@ -1,18 +1,22 @@
 package entities
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 type ConfigType int
 type ConfigLevel int
 
-//Must be updated when adding new config, part of full ENUM pattern
+//Reserved config types.  Concrete types declare their own ID alongside their struct and Register it; see registry.go
 const (
-	CONFIG_TYPE_UNSPECIFIED = iota
+	CONFIG_TYPE_UNSPECIFIED ConfigType = iota
 	CONFIG_TYPE_FULL
-	CONFIG_TYPE_DEMO_CONFIG
-	CONFIG_TYPE_OTHER_EXAMPLE
 )
 
 const (
@@ -22,132 +26,239 @@ const (
 	CONFIG_LEVEL_VENDOR
 )
 
-//This association isn't executed as part of the demo,  but it would live in the Service layer
-//If a request attempts to set a configuration on a level that isn't associated to that type, reject it as a bad request
+//Which config types may be set at which level is enforced on every write through Hierarchy.ValidateConfigLevel;
+//repositories reject anything else with ErrConfigTypeNotAllowedAtLevel, which callers should surface as a bad request.
 //The idea is that certain configurations _cannot_ be overridden at certain levels.  Some configs will be corporate only,
 //venue only, vendor only, etc.
 //This shouldn't affect the configuration retrieval logic/code at all.  If a request for a venue-associated configuration is made
 //with CONFIG_LEVEL_VENDOR, it should seek the first active configuration above it.
+//The associations live in one place: each level's HierarchyLevel.ConfigTypes, or, for levels that don't list their
+//own, the AllowedLevels each type registers for the default level of the same name
+
+//ErrConfigTypeNotAllowedAtLevel is returned when a config type is written at a level the hierarchy doesn't allow it at
+type ErrConfigTypeNotAllowedAtLevel struct {
+	ConfigLevel ConfigLevel
+	ConfigType  ConfigType
+}
+
+func (e ErrConfigTypeNotAllowedAtLevel) Error() string {
+	return fmt.Sprintf("config type %q (%d) cannot be set at config level %d", e.ConfigType.String(), e.ConfigType, e.ConfigLevel)
+}
+
+//IsConfigTypeAllowedAtLevel reports whether configType may be set at configLevel of the default hierarchy; see
+//Hierarchy.IsConfigTypeAllowedAtLevel
+func IsConfigTypeAllowedAtLevel(configLevel ConfigLevel, configType ConfigType) bool {
+	return defaultHierarchy.IsConfigTypeAllowedAtLevel(configLevel, configType)
+}
 
-//Make no mistake, this _is_ an array, not a map.  Was very confused that this compiled, but I'm grateful for the explicitness
-var CONFIG_LEVEL_TO_TYPE_ASSOCIATIONS = [4][]ConfigType{
-	CONFIG_LEVEL_UNSPECIFIED: {},
-	CONFIG_LEVEL_CORPORATE: {
-		CONFIG_TYPE_DEMO_CONFIG,
-		CONFIG_TYPE_OTHER_EXAMPLE, //New Configuration types should always be appended; this means the []ConfigType array
-		//will always be sorted, meaning we can execute a sort.Search (https://golang.org/pkg/sort/#Search)
-		//to get the expected index of the config type.  We then directly access it and compare it to the
-		//Requested configuration type.  If equal, accept, if not equal, reject as bad request
-
-		//  configLevel == CONFIG_LEVEL_CORPORATE ; configType == CONFIG_TYPE_OTHER_EXAMPLE
-		//ex:  if index := sort.Search(len(CONFIG_LEVEL_TO_TYPE_ASSOCIATIONS[configLevel]), func(i int) bool {
-		  			//return i >= configType
-		//}); len(CONFIG_LEVEL_TO_TYPE_ASSOCIATIONS[configLevel]) > index && CONFIG_LEVEL_TO_TYPE_ASSOCIATIONS[configLevel][index] == configType
-		//A bit verbose, easy to refactor
-		//This is much more performant than a map
-	},
-	CONFIG_LEVEL_VENUE: {
-		CONFIG_TYPE_DEMO_CONFIG,
-	},
-	CONFIG_LEVEL_VENDOR: {
-		CONFIG_TYPE_OTHER_EXAMPLE,
-	},
+//ValidateConfigLevel is Hierarchy.ValidateConfigLevel in the default hierarchy
+func ValidateConfigLevel(configLevel ConfigLevel, configType ConfigType) error {
+	return defaultHierarchy.ValidateConfigLevel(configLevel, configType)
 }
 
+//Validate holds rules that can't be expressed as validate tags; callers should use ValidateConfig, which runs both
 type ValidatedConfig interface {
 	Validate() error
 	GetConfigType() ConfigType
 	//UnmarshalBSONValue(bsontype.Type, []byte) error
 }
 
-//Must be updated when adding new config; irrelevant once full ENUM pattern is in place
+//Registered types return their Key; FULL and unregistered types have no subdocument and return ""
 func (ct ConfigType) String() string {
-	switch ct {
-	case CONFIG_TYPE_DEMO_CONFIG:
-		return "cloud_cart"
-	case CONFIG_TYPE_OTHER_EXAMPLE:
-		return "other_example"
-	default:
-		return ""
+	if descriptor, ok := LookupConfigType(ct); ok {
+		return descriptor.Key
 	}
+
+	return ""
 }
 
-type CorporateConfig struct {
-	CorporateID string          `bson:"corporate_id"`
-	CloudCart   CloudCartConfig `bson:"cloud_cart"`
-	OtherConfig OtherConfig     `bson:"other_example"`
+//ConfigLevel names are the default hierarchy's, used when a bare level is read or written as text.  Anything read
+//through another hierarchy names its levels with Hierarchy.LevelName instead
+var configLevelNames = [4]string{
+	CONFIG_LEVEL_UNSPECIFIED: "unspecified",
+	CONFIG_LEVEL_CORPORATE:   "corporate",
+	CONFIG_LEVEL_VENUE:       "venue",
+	CONFIG_LEVEL_VENDOR:      "vendor",
 }
-type VenueConfig struct {
-	CorporateID string          `bson:"corporate_id"`
-	VenueID     string          `bson:"venue_id,omitempty"`
-	CloudCart   CloudCartConfig `bson:"cloud_cart"`
-	OtherConfig OtherConfig     `bson:"other_example"`
+
+func (cl ConfigLevel) String() string {
+	if cl < 0 || int(cl) >= len(configLevelNames) {
+		return fmt.Sprintf("ConfigLevel(%d)", int(cl))
+	}
+
+	return configLevelNames[cl]
 }
-type VendorConfig struct {
-	CorporateID string          `bson:"corporate_id"`
-	VenueID     string          `bson:"venue_id,omitempty"`
-	VendorID    string          `bson:"vendor_id,omitempty"`
-	CloudCart   CloudCartConfig `bson:"cloud_cart"`
-	OtherConfig OtherConfig     `bson:"other_example"`
+
+//ParseConfigLevel is the inverse of ConfigLevel.String
+func ParseConfigLevel(name string) (ConfigLevel, error) {
+	for level, levelName := range configLevelNames {
+		if levelName == name && level != CONFIG_LEVEL_UNSPECIFIED {
+			return ConfigLevel(level), nil
+		}
+	}
+
+	return CONFIG_LEVEL_UNSPECIFIED, fmt.Errorf("unknown config level: %q", name)
+}
+
+func (cl ConfigLevel) MarshalText() ([]byte, error) {
+	return []byte(cl.String()), nil
 }
 
-func (c *CorporateConfig) Validate() error {
+func (cl *ConfigLevel) UnmarshalText(text []byte) error {
+	level, err := ParseConfigLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*cl = level
+
 	return nil
 }
 
-func (c *CorporateConfig) GetConfigType() ConfigType {
-	return CONFIG_TYPE_FULL
+//LevelConfig is a whole config document, the aggregate CONFIG_TYPE_FULL reads as.  Its shape comes from the hierarchy
+//that made it (see Hierarchy.NewAggregate): IDs holds the ID field of each level down to ConfigLevel, and Configs every
+//registered type, decoded by UnmarshalBSON, so neither adding a level nor a type touches this struct
+type LevelConfig struct {
+	ConfigLevel ConfigLevel
+	//IDs is keyed by ID field, e.g. {"corporate_id": "1", "venue_id": "2"}
+	IDs     map[string]string
+	Configs ConfigSet
+
+	hierarchy *Hierarchy
 }
-func (c *VenueConfig) Validate() error {
-	return nil
+
+//UnmarshalBSON reads the scope's ID fields and every registered type.  The document's own config_level wins over the
+//one the aggregate was made for
+func (c *LevelConfig) UnmarshalBSON(raw []byte) error {
+	doc := bson.Raw(raw)
+	if value, err := doc.LookupErr("config_level"); err == nil {
+		if level, ok := value.Int64OK(); ok {
+			c.ConfigLevel = ConfigLevel(level)
+		} else if level, ok := value.Int32OK(); ok {
+			c.ConfigLevel = ConfigLevel(level)
+		}
+	}
+
+	c.IDs = map[string]string{}
+	for level := ConfigLevel(CONFIG_LEVEL_CORPORATE); level <= c.ConfigLevel && c.hierarchy.ValidLevel(level); level++ {
+		field := c.hierarchy.IDField(level)
+		if value, err := doc.LookupErr(field); err == nil {
+			c.IDs[field], _ = value.StringValueOK()
+		}
+	}
+
+	var err error
+	c.Configs, err = decodeConfigSet(doc)
+	return err
 }
 
-func (c *VenueConfig) GetConfigType() ConfigType {
-	return CONFIG_TYPE_FULL
+//MarshalJSON flattens the ID fields into the object, in hierarchy order, next to configs:
+//{"corporate_id": "1", "venue_id": "2", "configs": {...}}
+func (c *LevelConfig) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for level := ConfigLevel(CONFIG_LEVEL_CORPORATE); level <= c.ConfigLevel && c.hierarchy.ValidLevel(level); level++ {
+		field := c.hierarchy.IDField(level)
+		id, ok := c.IDs[field]
+		if !ok {
+			continue
+		}
+		key, _ := json.Marshal(field)
+		value, _ := json.Marshal(id)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+		buf.WriteByte(',')
+	}
+	configs, err := json.Marshal(c.Configs)
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteString(`"configs":`)
+	buf.Write(configs)
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+func (c *LevelConfig) String() string {
+	parts := []string{}
+	for level := ConfigLevel(CONFIG_LEVEL_CORPORATE); level <= c.ConfigLevel && c.hierarchy.ValidLevel(level); level++ {
+		field := c.hierarchy.IDField(level)
+		parts = append(parts, fmt.Sprintf("%s:%s", field, c.IDs[field]))
+	}
+	parts = append(parts, fmt.Sprintf("configs:%s", c.Configs.String()))
+
+	return fmt.Sprintf("{%s}", strings.Join(parts, " "))
 }
-func (c *VendorConfig) Validate() error {
+
+//Hierarchy is the hierarchy the aggregate was made for
+func (c *LevelConfig) Hierarchy() *Hierarchy {
+	return c.hierarchy
+}
+
+func (c *LevelConfig) Validate() error {
 	return nil
 }
 
-func (c *VendorConfig) GetConfigType() ConfigType {
+func (c *LevelConfig) GetConfigType() ConfigType {
 	return CONFIG_TYPE_FULL
 }
 
 type ConfigMeta struct {
-	Enabled   bool      `bson:"enabled"`
-	ChangedBy string    `bson:"changed_by"`
-	ChangedAt time.Time `bson:"changed_at"`
+	Enabled   bool      `bson:"enabled" json:"enabled"`
+	ChangedBy string    `bson:"changed_by" json:"changed_by" validate:"required,max=128"`
+	ChangedAt time.Time `bson:"changed_at" json:"changed_at" validate:"required"`
+	//Overrides lists the bson field names this level supplies when configs are resolved field by field; every other
+	//field is inherited from the level above.  Empty means the whole config overrides, as it does for GetActiveConfig
+	Overrides []string `bson:"overrides,omitempty" json:"overrides,omitempty"`
+	//Revision is maintained by the repositories: it goes up by one on every write of the config, whatever the caller
+	//sets it to
+	Revision int64 `bson:"revision" json:"revision"`
+	//EffectiveFrom and EffectiveUntil optionally bound when an enabled config takes part in active resolution; from is
+	//inclusive, until exclusive.  Outside the window the config is treated as disabled
+	EffectiveFrom  *time.Time `bson:"effective_from,omitempty" json:"effective_from,omitempty"`
+	EffectiveUntil *time.Time `bson:"effective_until,omitempty" json:"effective_until,omitempty"`
+	//Rollouts turn boolean fields on at a percentage of the scopes the config is active for; see FieldRollout.
+	//Active resolution applies them for the scope asked about, GetSpecificConfig returns the config as stored
+	Rollouts []FieldRollout `bson:"rollouts,omitempty" json:"rollouts,omitempty"`
 }
 
-type CloudCartConfig struct {
-	ConfigMeta                        `bson:"meta"`
-	EnableCalculateReductionsAndTaxes bool `bson:"enable_calculate_reductions_and_taxes"`
-	EnableValidatePrices              bool `bson:"enable_validate_prices"`
-	EnableValidateCartSums            bool `bson:"enable_validate_cart_sums"`
+//MetaConfig is implemented by every concrete config type through its embedded ConfigMeta
+type MetaConfig interface {
+	GetMeta() *ConfigMeta
 }
 
-func (c *CloudCartConfig) Validate() error {
-	return nil
+func (m *ConfigMeta) GetMeta() *ConfigMeta {
+	return m
 }
 
-func (c *CloudCartConfig) GetConfigType() ConfigType {
-	return CONFIG_TYPE_DEMO_CONFIG
-}
+//EffectiveAt reports whether t falls in the config's effective window; a missing bound is open
+func (m *ConfigMeta) EffectiveAt(t time.Time) bool {
+	if m.EffectiveFrom != nil && t.Before(*m.EffectiveFrom) {
+		return false
+	}
+	if m.EffectiveUntil != nil && !t.Before(*m.EffectiveUntil) {
+		return false
+	}
 
-type OtherConfig struct {
-	ConfigMeta      `bson:"meta"`
-	ADifferentValue string  `bson:"a_different_value"`
-	AFloat          float32 `bson:"a_float"`
+	return true
 }
 
-func (c *OtherConfig) Validate() error {
-	return nil
+//ActiveAt reports whether the config takes part in active resolution at t
+func (m *ConfigMeta) ActiveAt(t time.Time) bool {
+	return m.Enabled && m.EffectiveAt(t)
 }
 
-func (c *OtherConfig) GetConfigType() ConfigType {
-	return CONFIG_TYPE_OTHER_EXAMPLE
-}
+//OverridesField reports whether this level supplies field during field-by-field resolution
+func (m *ConfigMeta) OverridesField(field string) bool {
+	if len(m.Overrides) == 0 {
+		return true
+	}
+	for _, override := range m.Overrides {
+		if override == field {
+			return true
+		}
+	}
 
-//func (c *CloudCartConfig) UnmarshalBSONValue(_ bsontype.Type, raw []byte) error {
-//return bson.Unmarshal(raw, c) //Caused recursive loop; under the hood, Unmarshal looks for this interface method
-//}
+	return false
+}
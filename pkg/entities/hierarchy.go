@@ -0,0 +1,240 @@
+package entities
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//MaxHierarchyDepth bounds how many levels a Hierarchy may have, so a scope's IDs fit a fixed size, comparable array
+const MaxHierarchyDepth = 8
+
+//HierarchyLevel is one named level of a Hierarchy
+type HierarchyLevel struct {
+	//Name is the level's text form, e.g. "region".  URL paths use it pluralised with an "s", e.g. /regions/{id}
+	Name string
+	//IDField is the document field holding the level's ID, e.g. "region_id"
+	IDField string
+	//ConfigTypes are the types that may be set at this level.  Nil means the types registered for the default level
+	//of the same name, through ConfigTypeDescriptor.AllowedLevels, so corporate, venue and vendor need not list theirs
+	ConfigTypes []ConfigType
+}
+
+//Hierarchy is the ordered list of levels config documents are kept at, most general first.  A level's ConfigLevel is
+//its 1-based position, so in the default hierarchy CONFIG_LEVEL_CORPORATE, CONFIG_LEVEL_VENUE and CONFIG_LEVEL_VENDOR
+//are its levels; in another hierarchy those constants mean nothing and levels are looked up by name.  ConfigLevel's own
+//String and text forms always use the default names; use LevelName for everything else.  Results that carry a level,
+//e.g. ResolvedConfig, keep the hierarchy they were made in and name their levels through it in JSON
+type Hierarchy struct {
+	levels []HierarchyLevel
+}
+
+//reservedFields are document fields a level's IDField can't take.  deleted_revisions is where the repositories keep
+//deleted configs' revisions
+var reservedFields = map[string]bool{"_id": true, "config_level": true, "deleted_revisions": true}
+
+//NewHierarchy checks levels and returns the hierarchy they define.  Names and ID fields must be unique, and an ID field
+//can't be config_level, _id, deleted_revisions or a registered config key
+func NewHierarchy(levels ...HierarchyLevel) (*Hierarchy, error) {
+	if len(levels) == 0 || len(levels) > MaxHierarchyDepth {
+		return nil, fmt.Errorf("a hierarchy needs between 1 and %d levels, not %d", MaxHierarchyDepth, len(levels))
+	}
+
+	names := map[string]bool{}
+	fields := map[string]bool{}
+	h := &Hierarchy{levels: make([]HierarchyLevel, 0, len(levels))}
+	for _, level := range levels {
+		switch {
+		case level.Name == "" || level.IDField == "":
+			return nil, fmt.Errorf("hierarchy level %d needs a name and an ID field", len(h.levels)+1)
+		case level.Name == configLevelNames[CONFIG_LEVEL_UNSPECIFIED]:
+			return nil, fmt.Errorf("hierarchy level name %q is reserved", level.Name)
+		case names[level.Name]:
+			return nil, fmt.Errorf("hierarchy level %q defined twice", level.Name)
+		case fields[level.IDField] || reservedFields[level.IDField]:
+			return nil, fmt.Errorf("hierarchy ID field %q is reserved or used twice", level.IDField)
+		}
+		if _, ok := LookupConfigKey(level.IDField); ok {
+			return nil, fmt.Errorf("hierarchy ID field %q is a config key", level.IDField)
+		}
+		names[level.Name] = true
+		fields[level.IDField] = true
+
+		if level.ConfigTypes != nil {
+			configTypes := append([]ConfigType{}, level.ConfigTypes...)
+			sort.Slice(configTypes, func(i, j int) bool {
+				return configTypes[i] < configTypes[j]
+			})
+			level.ConfigTypes = configTypes
+		}
+		h.levels = append(h.levels, level)
+	}
+
+	return h, nil
+}
+
+//HierarchyFromNames is NewHierarchy with each level's ID field named after it, e.g. region -> region_id.  A name may
+//list the config types its level allows after an "=", joined by "+", e.g. region=cloud_cart+other_example; without one
+//the level gets the default config types for its name, so a level that isn't corporate, venue or vendor has to list
+//its types to hold any config.  "region=" allows none
+func HierarchyFromNames(names ...string) (*Hierarchy, error) {
+	levels := make([]HierarchyLevel, 0, len(names))
+	for _, name := range names {
+		name, configKeys, listsTypes := cutString(strings.TrimSpace(name), "=")
+		level := HierarchyLevel{Name: name, IDField: name + "_id"}
+		if listsTypes {
+			level.ConfigTypes = []ConfigType{}
+			for _, key := range strings.Split(configKeys, "+") {
+				key = strings.TrimSpace(key)
+				if key == "" {
+					continue
+				}
+				descriptor, ok := LookupConfigKey(key)
+				if !ok {
+					return nil, fmt.Errorf("hierarchy level %q allows unknown config type %q", name, key)
+				}
+				level.ConfigTypes = append(level.ConfigTypes, descriptor.ID)
+			}
+		}
+		levels = append(levels, level)
+	}
+
+	return NewHierarchy(levels...)
+}
+
+//cutString is strings.Cut, which this module's Go version doesn't have
+func cutString(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+
+	return s, "", false
+}
+
+//defaultHierarchy is corporate -> venue -> vendor, with the ID fields the configs collection has always used
+var defaultHierarchy = &Hierarchy{levels: []HierarchyLevel{
+	{Name: configLevelNames[CONFIG_LEVEL_CORPORATE], IDField: "corporate_id"},
+	{Name: configLevelNames[CONFIG_LEVEL_VENUE], IDField: "venue_id"},
+	{Name: configLevelNames[CONFIG_LEVEL_VENDOR], IDField: "vendor_id"},
+}}
+
+//DefaultHierarchy is corporate -> venue -> vendor, which the CONFIG_LEVEL_* constants number
+func DefaultHierarchy() *Hierarchy {
+	return defaultHierarchy
+}
+
+//Depth is the number of levels, i.e. the ConfigLevel of the most specific one
+func (h *Hierarchy) Depth() int {
+	return len(h.levels)
+}
+
+//Levels returns the levels, most general first
+func (h *Hierarchy) Levels() []HierarchyLevel {
+	return append([]HierarchyLevel(nil), h.levels...)
+}
+
+//ValidLevel reports whether configLevel is one of the hierarchy's levels
+func (h *Hierarchy) ValidLevel(configLevel ConfigLevel) bool {
+	return configLevel > CONFIG_LEVEL_UNSPECIFIED && int(configLevel) <= len(h.levels)
+}
+
+//Level returns the definition of configLevel
+func (h *Hierarchy) Level(configLevel ConfigLevel) (HierarchyLevel, bool) {
+	if !h.ValidLevel(configLevel) {
+		return HierarchyLevel{}, false
+	}
+
+	return h.levels[configLevel-1], true
+}
+
+//LevelName is configLevel's name in this hierarchy.  A nil hierarchy is the default one, so results made without a
+//hierarchy name their levels as they always have
+func (h *Hierarchy) LevelName(configLevel ConfigLevel) string {
+	if h == nil {
+		h = defaultHierarchy
+	}
+	if level, ok := h.Level(configLevel); ok {
+		return level.Name
+	}
+
+	return fmt.Sprintf("ConfigLevel(%d)", int(configLevel))
+}
+
+//IDField is the document field holding configLevel's ID, or "" if there is no such level
+func (h *Hierarchy) IDField(configLevel ConfigLevel) string {
+	level, _ := h.Level(configLevel)
+	return level.IDField
+}
+
+//SameLevels reports whether other has the same level names and ID fields in the same order, so a scope means the same
+//document in both
+func (h *Hierarchy) SameLevels(other *Hierarchy) bool {
+	if h == other {
+		return true
+	}
+	if h == nil || other == nil || len(h.levels) != len(other.levels) {
+		return false
+	}
+	for i, level := range h.levels {
+		if level.Name != other.levels[i].Name || level.IDField != other.levels[i].IDField {
+			return false
+		}
+	}
+
+	return true
+}
+
+//ParseLevel maps a level name to its ConfigLevel
+func (h *Hierarchy) ParseLevel(name string) (ConfigLevel, error) {
+	for i, level := range h.levels {
+		if level.Name == name {
+			return ConfigLevel(i + 1), nil
+		}
+	}
+
+	return CONFIG_LEVEL_UNSPECIFIED, fmt.Errorf("unknown config level: %q", name)
+}
+
+//IsConfigTypeAllowedAtLevel reports whether configType may be set at configLevel.  A level without its own ConfigTypes
+//defers to the AllowedLevels configType registered for the default level of the same name; a level with no such
+//namesake allows nothing.  Each level's own list is kept sorted, so this is a binary search rather than a map lookup
+func (h *Hierarchy) IsConfigTypeAllowedAtLevel(configLevel ConfigLevel, configType ConfigType) bool {
+	level, ok := h.Level(configLevel)
+	if !ok {
+		return false
+	}
+	if level.ConfigTypes == nil {
+		defaultLevel, err := ParseConfigLevel(level.Name)
+		return err == nil && registeredAtLevel(defaultLevel, configType)
+	}
+
+	index := sort.Search(len(level.ConfigTypes), func(i int) bool {
+		return level.ConfigTypes[i] >= configType
+	})
+
+	return index < len(level.ConfigTypes) && level.ConfigTypes[index] == configType
+}
+
+//ValidateConfigLevel returns ErrConfigTypeNotAllowedAtLevel if configType cannot be set at configLevel
+func (h *Hierarchy) ValidateConfigLevel(configLevel ConfigLevel, configType ConfigType) error {
+	if !h.IsConfigTypeAllowedAtLevel(configLevel, configType) {
+		return ErrConfigTypeNotAllowedAtLevel{ConfigLevel: configLevel, ConfigType: configType}
+	}
+
+	return nil
+}
+
+//NewAggregate returns the empty full-document config for configLevel, with every registered type defaulted, or nil if
+//the hierarchy has no such level
+func (h *Hierarchy) NewAggregate(configLevel ConfigLevel) ValidatedConfig {
+	if !h.ValidLevel(configLevel) {
+		return nil
+	}
+
+	return &LevelConfig{
+		ConfigLevel: configLevel,
+		IDs:         map[string]string{},
+		Configs:     newConfigSet(),
+		hierarchy:   h,
+	}
+}
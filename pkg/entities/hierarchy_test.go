@@ -0,0 +1,57 @@
+package entities
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHierarchyFromNames(t *testing.T) {
+	h, err := HierarchyFromNames("corporate", " region=cloud_cart + other_example", "venue", "vendor", "terminal=other_example", "kiosk", "till=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		level   ConfigLevel
+		name    string
+		idField string
+		allowed []ConfigType
+	}{
+		{1, "corporate", "corporate_id", []ConfigType{CONFIG_TYPE_DEMO_CONFIG, CONFIG_TYPE_OTHER_EXAMPLE}},
+		{2, "region", "region_id", []ConfigType{CONFIG_TYPE_DEMO_CONFIG, CONFIG_TYPE_OTHER_EXAMPLE}},
+		{3, "venue", "venue_id", []ConfigType{CONFIG_TYPE_DEMO_CONFIG}},
+		{4, "vendor", "vendor_id", []ConfigType{CONFIG_TYPE_OTHER_EXAMPLE}},
+		{5, "terminal", "terminal_id", []ConfigType{CONFIG_TYPE_OTHER_EXAMPLE}},
+		{6, "kiosk", "kiosk_id", nil},
+		{7, "till", "till_id", nil},
+	} {
+		if got := h.LevelName(test.level); got != test.name {
+			t.Errorf("level %d is %q, want %q", test.level, got, test.name)
+		}
+		if got := h.IDField(test.level); got != test.idField {
+			t.Errorf("%s's ID field is %q, want %q", test.name, got, test.idField)
+		}
+		var allowed []ConfigType
+		for _, configType := range RegisteredConfigTypes() {
+			if h.IsConfigTypeAllowedAtLevel(test.level, configType) {
+				allowed = append(allowed, configType)
+			}
+		}
+		if fmt.Sprint(allowed) != fmt.Sprint(test.allowed) {
+			t.Errorf("%s allows %v, want %v", test.name, allowed, test.allowed)
+		}
+	}
+}
+
+func TestHierarchyFromNamesRefusesInvalidLevels(t *testing.T) {
+	for _, names := range [][]string{
+		{"corporate", "region=no_such_config"},
+		{"corporate", "corporate"},
+		{"corporate", "=cloud_cart"},
+		{"corporate", "unspecified"},
+	} {
+		if _, err := HierarchyFromNames(names...); err == nil {
+			t.Errorf("%q made a hierarchy", names)
+		}
+	}
+}
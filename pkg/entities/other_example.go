@@ -0,0 +1,34 @@
+package entities
+
+const CONFIG_TYPE_OTHER_EXAMPLE ConfigType = 3
+
+func init() {
+	Register(ConfigTypeDescriptor{
+		ID:  CONFIG_TYPE_OTHER_EXAMPLE,
+		Key: "other_example",
+		New: func() ValidatedConfig {
+			return &OtherConfig{}
+		},
+		AllowedLevels: []ConfigLevel{CONFIG_LEVEL_CORPORATE, CONFIG_LEVEL_VENDOR},
+	})
+}
+
+type OtherConfig struct {
+	ConfigMeta      `bson:"meta" json:"meta"`
+	ADifferentValue string  `bson:"a_different_value" json:"a_different_value" validate:"max=256"`
+	AFloat          float32 `bson:"a_float" json:"a_float" validate:"min=0,max=1000"`
+}
+
+func (c *OtherConfig) Validate() error {
+	return nil
+}
+
+func (c *OtherConfig) GetConfigType() ConfigType {
+	return CONFIG_TYPE_OTHER_EXAMPLE
+}
+
+//OtherExample is the set's other_example config, or nil if it has none
+func (s ConfigSet) OtherExample() *OtherConfig {
+	config, _ := s.Get(CONFIG_TYPE_OTHER_EXAMPLE).(*OtherConfig)
+	return config
+}
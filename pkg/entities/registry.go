@@ -0,0 +1,278 @@
+package entities
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+//ConfigTypeDescriptor is everything the repositories need to know about a config type.  Each type registers one from
+//an init func in its own file; String(), decoding, empty defaults, the aggregates and level associations all read it
+type ConfigTypeDescriptor struct {
+	ID ConfigType
+	//Key is the subdocument name the config is stored under, and what ConfigType.String() returns
+	Key string
+	//New returns a pointer to an empty config of this type, used for decoding and as the default when nothing is set
+	New func() ValidatedConfig
+	//AllowedLevels are the default hierarchy's levels this type may be set at; see Hierarchy.IsConfigTypeAllowedAtLevel
+	AllowedLevels []ConfigLevel
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[ConfigType]ConfigTypeDescriptor{}
+)
+
+//Register makes a config type available.  Like database/sql.Register, it panics on an invalid or duplicate
+//registration, since both are programming errors that should fail at startup
+func Register(descriptor ConfigTypeDescriptor) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if descriptor.ID == CONFIG_TYPE_UNSPECIFIED || descriptor.ID == CONFIG_TYPE_FULL {
+		panic(fmt.Sprintf("entities: cannot register reserved config type %d", descriptor.ID))
+	}
+	if descriptor.Key == "" || descriptor.New == nil {
+		panic(fmt.Sprintf("entities: config type %d registered without a Key or New func", descriptor.ID))
+	}
+	if _, dup := registry[descriptor.ID]; dup {
+		panic(fmt.Sprintf("entities: config type %d registered twice", descriptor.ID))
+	}
+	for _, existing := range registry {
+		if existing.Key == descriptor.Key {
+			panic(fmt.Sprintf("entities: config key %q registered twice", descriptor.Key))
+		}
+	}
+	if got := descriptor.New().GetConfigType(); got != descriptor.ID {
+		panic(fmt.Sprintf("entities: config type %d New func returns a config of type %d", descriptor.ID, got))
+	}
+
+	for _, level := range descriptor.AllowedLevels {
+		if !defaultHierarchy.ValidLevel(level) {
+			panic(fmt.Sprintf("entities: config type %d allowed at invalid level %d", descriptor.ID, level))
+		}
+	}
+
+	registry[descriptor.ID] = descriptor
+}
+
+//registeredAtLevel reports whether configType registered the default hierarchy's configLevel among its AllowedLevels
+func registeredAtLevel(configLevel ConfigLevel, configType ConfigType) bool {
+	descriptor, ok := LookupConfigType(configType)
+	if !ok {
+		return false
+	}
+	for _, level := range descriptor.AllowedLevels {
+		if level == configLevel {
+			return true
+		}
+	}
+
+	return false
+}
+
+//LookupConfigType returns the descriptor registered for configType
+func LookupConfigType(configType ConfigType) (ConfigTypeDescriptor, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	descriptor, ok := registry[configType]
+	return descriptor, ok
+}
+
+//LookupConfigKey returns the descriptor registered under key, e.g. "cloud_cart"
+func LookupConfigKey(key string) (ConfigTypeDescriptor, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	for _, descriptor := range registry {
+		if descriptor.Key == key {
+			return descriptor, true
+		}
+	}
+
+	return ConfigTypeDescriptor{}, false
+}
+
+//fullConfigKey is the text form of CONFIG_TYPE_FULL, which has no registered key of its own
+const fullConfigKey = "full"
+
+//ParseConfigType maps a config key, or "full", to its type
+func ParseConfigType(key string) (ConfigType, error) {
+	if key == fullConfigKey {
+		return CONFIG_TYPE_FULL, nil
+	}
+	if descriptor, ok := LookupConfigKey(key); ok {
+		return descriptor.ID, nil
+	}
+
+	return CONFIG_TYPE_UNSPECIFIED, fmt.Errorf("unknown config type: %q", key)
+}
+
+func (ct ConfigType) MarshalText() ([]byte, error) {
+	if ct == CONFIG_TYPE_FULL {
+		return []byte(fullConfigKey), nil
+	}
+	if key := ct.String(); key != "" {
+		return []byte(key), nil
+	}
+
+	return nil, fmt.Errorf("config type %d has no key", int(ct))
+}
+
+func (ct *ConfigType) UnmarshalText(text []byte) error {
+	configType, err := ParseConfigType(string(text))
+	if err != nil {
+		return err
+	}
+	*ct = configType
+
+	return nil
+}
+
+//RegisteredConfigTypes returns every registered type in ID order
+func RegisteredConfigTypes() []ConfigType {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]ConfigType, 0, len(registry))
+	for id := range registry {
+		types = append(types, id)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i] < types[j]
+	})
+
+	return types
+}
+
+//NewConfig returns an empty config of a registered type, or nil if configType isn't registered
+func NewConfig(configType ConfigType) ValidatedConfig {
+	descriptor, ok := LookupConfigType(configType)
+	if !ok {
+		return nil
+	}
+
+	return descriptor.New()
+}
+
+//CloneConfig returns a copy of config that shares nothing mutable with it
+func CloneConfig(config ValidatedConfig) ValidatedConfig {
+	v := reflect.ValueOf(config)
+	if config == nil || v.Kind() != reflect.Ptr || v.IsNil() {
+		return config
+	}
+
+	cloneValue := reflect.New(v.Elem().Type())
+	cloneValue.Elem().Set(v.Elem())
+	clone := cloneValue.Interface().(ValidatedConfig)
+
+	if metaConfig, ok := clone.(MetaConfig); ok {
+		meta := metaConfig.GetMeta()
+		if meta.Overrides != nil {
+			meta.Overrides = append([]string(nil), meta.Overrides...)
+		}
+		if meta.Rollouts != nil {
+			meta.Rollouts = append([]FieldRollout(nil), meta.Rollouts...)
+		}
+		if meta.EffectiveFrom != nil {
+			from := *meta.EffectiveFrom
+			meta.EffectiveFrom = &from
+		}
+		if meta.EffectiveUntil != nil {
+			until := *meta.EffectiveUntil
+			meta.EffectiveUntil = &until
+		}
+	}
+	if aggregate, ok := clone.(*LevelConfig); ok {
+		ids := make(map[string]string, len(aggregate.IDs))
+		for field, id := range aggregate.IDs {
+			ids[field] = id
+		}
+		aggregate.IDs = ids
+		aggregate.Configs = aggregate.Configs.clone()
+	}
+
+	return clone
+}
+
+//ConfigSet holds one config per registered type, keyed by type.  It is what LevelConfig carries instead of a field per
+//type.  Each type's file adds a typed accessor, e.g. CloudCart
+type ConfigSet map[ConfigType]ValidatedConfig
+
+//Get returns the config of configType, or nil if the set doesn't have one
+func (s ConfigSet) Get(configType ConfigType) ValidatedConfig {
+	return s[configType]
+}
+
+func (s ConfigSet) String() string {
+	types := make([]ConfigType, 0, len(s))
+	for configType := range s {
+		types = append(types, configType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i] < types[j]
+	})
+
+	parts := make([]string, 0, len(types))
+	for _, configType := range types {
+		parts = append(parts, fmt.Sprintf("%s:%+v", configType.String(), s[configType]))
+	}
+
+	return fmt.Sprintf("map[%s]", strings.Join(parts, " "))
+}
+
+func (s ConfigSet) clone() ConfigSet {
+	if s == nil {
+		return nil
+	}
+
+	clone := make(ConfigSet, len(s))
+	for configType, config := range s {
+		clone[configType] = CloneConfig(config)
+	}
+
+	return clone
+}
+
+//MarshalJSON keys the set by config key, e.g. {"cloud_cart": {...}}, rather than by numeric type
+func (s ConfigSet) MarshalJSON() ([]byte, error) {
+	byKey := make(map[string]ValidatedConfig, len(s))
+	for configType, config := range s {
+		byKey[configType.String()] = config
+	}
+
+	return json.Marshal(byKey)
+}
+
+//newConfigSet returns a set with every registered type at its empty default
+func newConfigSet() ConfigSet {
+	set := ConfigSet{}
+	for _, configType := range RegisteredConfigTypes() {
+		set[configType] = NewConfig(configType)
+	}
+
+	return set
+}
+
+//decodeConfigSet decodes every registered type's subdocument from a full config document.  Types missing from the
+//document keep their empty default, as the per-type aggregate fields used to
+func decodeConfigSet(raw bson.Raw) (ConfigSet, error) {
+	set := newConfigSet()
+	for configType, config := range set {
+		value, err := raw.LookupErr(configType.String())
+		if err != nil {
+			continue
+		}
+		if err := value.Unmarshal(config); err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", configType.String(), err)
+		}
+	}
+
+	return set, nil
+}
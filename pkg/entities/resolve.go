@@ -0,0 +1,143 @@
+package entities
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const metaField = "meta"
+
+//ConfigLayer is one level's specific config, as fed into ResolveConfig
+type ConfigLayer struct {
+	ConfigLevel ConfigLevel
+	Config      ValidatedConfig
+}
+
+//ResolvedConfig is a config merged field by field down the hierarchy.  Sources maps each bson field name to the level
+//that supplied it; fields no enabled level supplied keep their empty default and have no entry
+type ResolvedConfig struct {
+	Config  ValidatedConfig        `json:"config"`
+	Sources map[string]ConfigLevel `json:"sources"`
+
+	hierarchy *Hierarchy
+}
+
+//Hierarchy is the hierarchy the config was resolved in
+func (r ResolvedConfig) Hierarchy() *Hierarchy {
+	return r.hierarchy
+}
+
+//Clone returns a copy of the resolved config that shares nothing mutable with it
+func (r *ResolvedConfig) Clone() *ResolvedConfig {
+	if r == nil {
+		return nil
+	}
+
+	sources := make(map[string]ConfigLevel, len(r.Sources))
+	for field, level := range r.Sources {
+		sources[field] = level
+	}
+
+	return &ResolvedConfig{Config: CloneConfig(r.Config), Sources: sources, hierarchy: r.hierarchy}
+}
+
+//MarshalJSON names the Sources levels through the hierarchy, e.g. {"enable_validate_prices": "region"}
+func (r ResolvedConfig) MarshalJSON() ([]byte, error) {
+	sources := make(map[string]string, len(r.Sources))
+	for field, level := range r.Sources {
+		sources[field] = r.hierarchy.LevelName(level)
+	}
+
+	return json.Marshal(struct {
+		Config  ValidatedConfig   `json:"config"`
+		Sources map[string]string `json:"sources"`
+	}{Config: r.Config, Sources: sources})
+}
+
+//ResolveConfig merges layers of hierarchy h, ordered most general first, into a single config of configType.  Each enabled layer
+//supplies the fields its meta.overrides names (all of them if it names none); disabled layers are skipped entirely.
+//The resolved meta is enabled if any layer contributed, and carries the changed_by/changed_at of the last one that did
+func ResolveConfig(h *Hierarchy, configType ConfigType, layers []ConfigLayer) (*ResolvedConfig, error) {
+	resolved := NewConfig(configType)
+	if resolved == nil {
+		return nil, fmt.Errorf("unsupported config type")
+	}
+
+	merged, err := toDocument(resolved)
+	if err != nil {
+		return nil, err
+	}
+	sources := map[string]ConfigLevel{}
+	var meta *ConfigMeta
+
+	for _, layer := range layers {
+		if layer.Config == nil || layer.Config.GetConfigType() != configType {
+			continue
+		}
+		metaConfig, ok := layer.Config.(MetaConfig)
+		if !ok {
+			return nil, fmt.Errorf("config type %s has no meta", configType.String())
+		}
+		layerMeta := metaConfig.GetMeta()
+		if !layerMeta.Enabled {
+			continue
+		}
+
+		doc, err := toDocument(layer.Config)
+		if err != nil {
+			return nil, err
+		}
+		for _, elem := range doc {
+			if elem.Key == metaField || !layerMeta.OverridesField(elem.Key) {
+				continue
+			}
+			merged = setElement(merged, elem)
+			sources[elem.Key] = layer.ConfigLevel
+		}
+		meta = layerMeta
+	}
+
+	raw, err := bson.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	if err := bson.Unmarshal(raw, resolved); err != nil {
+		return nil, err
+	}
+	if meta != nil {
+		*resolved.(MetaConfig).GetMeta() = ConfigMeta{
+			Enabled:   true,
+			ChangedBy: meta.ChangedBy,
+			ChangedAt: meta.ChangedAt,
+		}
+	}
+
+	return &ResolvedConfig{Config: resolved, Sources: sources, hierarchy: h}, nil
+}
+
+func toDocument(config ValidatedConfig) (bson.D, error) {
+	raw, err := bson.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+func setElement(doc bson.D, elem bson.E) bson.D {
+	for i := range doc {
+		if doc[i].Key == elem.Key {
+			doc[i] = elem
+			return doc
+		}
+	}
+
+	return append(doc, elem)
+}
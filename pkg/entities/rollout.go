@@ -0,0 +1,115 @@
+package entities
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"strings"
+)
+
+//rolloutBuckets is how many buckets scopes are hashed into; a Percent selects that many of every hundred
+const rolloutBuckets = 100
+
+//FieldRollout turns a boolean field on at Percent of the scopes the config is active for and off at the rest, whatever
+//the field is set to.  Scopes are bucketed by a stable hash of their IDs, so raising Percent only adds scopes and a
+//scope stays in or out for as long as the rollout is unchanged
+type FieldRollout struct {
+	//Field is the bson name of a top level bool field of the config
+	Field string `bson:"field" json:"field"`
+	//Percent is the share of scopes, 0 to 100, the field is true for
+	Percent int `bson:"percent" json:"percent"`
+	//Seed reshuffles the buckets.  Without one, scopes are bucketed by config type and field, so two fields rolled out
+	//to the same percentage don't reach the same scopes
+	Seed string `bson:"seed,omitempty" json:"seed,omitempty"`
+}
+
+//RolloutAssignment is how a FieldRollout applied to one scope: the scope's bucket and whether it fell inside Percent,
+//which is the value the field took
+type RolloutAssignment struct {
+	Field    string `json:"field"`
+	Percent  int    `json:"percent"`
+	Bucket   int    `json:"bucket"`
+	Included bool   `json:"included"`
+}
+
+//RolloutBucket is the bucket, 0 to 99, the scope with the given IDs, top level first, falls in for a rollout of a
+//configType field
+func RolloutBucket(configType ConfigType, rollout FieldRollout, path []string) int {
+	hash := fnv.New64a()
+	for _, part := range append([]string{configType.String(), rollout.Field, rollout.Seed}, path...) {
+		//Length prefixed, so IDs can't run into one another
+		fmt.Fprintf(hash, "%d:%s;", len(part), part)
+	}
+
+	return int(hash.Sum64() % rolloutBuckets)
+}
+
+//ApplyRollouts returns config as it applies to the scope with the given IDs, top level first: a copy with every
+//rollout in its meta applied, and how each one was assigned.  Configs without rollouts are returned as they are
+func ApplyRollouts(config ValidatedConfig, path []string) (ValidatedConfig, []RolloutAssignment) {
+	metaConfig, ok := config.(MetaConfig)
+	if !ok || len(metaConfig.GetMeta().Rollouts) == 0 {
+		return config, nil
+	}
+
+	applied := CloneConfig(config)
+	assignments := make([]RolloutAssignment, 0, len(metaConfig.GetMeta().Rollouts))
+	for _, rollout := range metaConfig.GetMeta().Rollouts {
+		field, ok := rolloutField(applied, rollout.Field)
+		if !ok {
+			continue
+		}
+		bucket := RolloutBucket(config.GetConfigType(), rollout, path)
+		included := bucket < rollout.Percent
+		field.SetBool(included)
+		assignments = append(assignments, RolloutAssignment{
+			Field:    rollout.Field,
+			Percent:  rollout.Percent,
+			Bucket:   bucket,
+			Included: included,
+		})
+	}
+
+	return applied, assignments
+}
+
+//validateRollouts checks that each rollout names a distinct top level bool field and a percentage
+func validateRollouts(config ValidatedConfig, rollouts []FieldRollout, root string, validationErr *ValidationError) {
+	seen := map[string]bool{}
+	for i, rollout := range rollouts {
+		path := fmt.Sprintf("%s.meta.rollouts.%d", root, i)
+		if _, ok := rolloutField(config, rollout.Field); !ok {
+			validationErr.add(path+".field", fmt.Sprintf("%q is not a bool field", rollout.Field))
+		} else if seen[rollout.Field] {
+			validationErr.add(path+".field", fmt.Sprintf("%q is rolled out twice", rollout.Field))
+		}
+		seen[rollout.Field] = true
+		if rollout.Percent < 0 || rollout.Percent > 100 {
+			validationErr.add(path+".percent", "must be between 0 and 100")
+		}
+	}
+}
+
+//rolloutField is the settable top level bool field of config named by its bson name
+func rolloutField(config ValidatedConfig, name string) (reflect.Value, bool) {
+	v := reflect.ValueOf(config)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct || name == "" || strings.Contains(name, ".") || name == metaField {
+		return reflect.Value{}, false
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if fieldName, stored := bsonFieldName(field); stored && field.PkgPath == "" && fieldName == name && field.Type.Kind() == reflect.Bool {
+			return v.Field(i), v.Field(i).CanSet()
+		}
+	}
+
+	return reflect.Value{}, false
+}
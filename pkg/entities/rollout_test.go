@@ -0,0 +1,140 @@
+package entities
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+//rolloutPaths is n vendor paths under one venue
+func rolloutPaths(n int) [][]string {
+	paths := make([][]string, n)
+	for i := range paths {
+		paths[i] = []string{"1", "2", fmt.Sprint(i)}
+	}
+	return paths
+}
+
+func TestRolloutBucket(t *testing.T) {
+	rollout := FieldRollout{Field: "enable_validate_prices"}
+	paths := rolloutPaths(1000)
+
+	counts := make([]int, rolloutBuckets)
+	for _, path := range paths {
+		bucket := RolloutBucket(CONFIG_TYPE_DEMO_CONFIG, rollout, path)
+		if bucket < 0 || bucket >= rolloutBuckets {
+			t.Fatalf("%v is in bucket %d", path, bucket)
+		}
+		if again := RolloutBucket(CONFIG_TYPE_DEMO_CONFIG, rollout, path); again != bucket {
+			t.Fatalf("%v moved from bucket %d to %d", path, bucket, again)
+		}
+		counts[bucket]++
+	}
+	under50 := 0
+	for _, count := range counts[:50] {
+		under50 += count
+	}
+	if under50 < 400 || under50 > 600 {
+		t.Errorf("%d of %d paths are in the first 50 buckets, want about half", under50, len(paths))
+	}
+
+	//Each input reshuffles the buckets
+	for _, test := range []struct {
+		name       string
+		configType ConfigType
+		rollout    FieldRollout
+		path       func([]string) []string
+	}{
+		{"seed", CONFIG_TYPE_DEMO_CONFIG, FieldRollout{Field: rollout.Field, Seed: "again"}, nil},
+		{"field", CONFIG_TYPE_DEMO_CONFIG, FieldRollout{Field: "enable_validate_cart_sums"}, nil},
+		{"config type", CONFIG_TYPE_OTHER_EXAMPLE, rollout, nil},
+		{"IDs split differently", CONFIG_TYPE_DEMO_CONFIG, rollout, func(path []string) []string {
+			return []string{path[0] + path[1], path[2]}
+		}},
+	} {
+		moved := 0
+		for _, path := range paths {
+			other := path
+			if test.path != nil {
+				other = test.path(path)
+			}
+			if RolloutBucket(test.configType, test.rollout, other) != RolloutBucket(CONFIG_TYPE_DEMO_CONFIG, rollout, path) {
+				moved++
+			}
+		}
+		if moved < len(paths)/2 {
+			t.Errorf("changing the %s moved %d of %d paths, want most", test.name, moved, len(paths))
+		}
+	}
+}
+
+func TestApplyRollouts(t *testing.T) {
+	cloudCart := func(validatePrices bool, rollouts ...FieldRollout) *CloudCartConfig {
+		return &CloudCartConfig{
+			ConfigMeta:           ConfigMeta{Enabled: true, ChangedBy: "CORP", ChangedAt: time.Now(), Rollouts: rollouts},
+			EnableValidatePrices: validatePrices,
+		}
+	}
+	paths := rolloutPaths(200)
+
+	for _, test := range []struct {
+		name    string
+		config  *CloudCartConfig
+		percent int
+	}{
+		{"0% turns a true field off everywhere", cloudCart(true, FieldRollout{Field: "enable_validate_prices", Percent: 0}), 0},
+		{"100% turns a false field on everywhere", cloudCart(false, FieldRollout{Field: "enable_validate_prices", Percent: 100}), 100},
+		{"50% of a true field turns it off outside the rollout", cloudCart(true, FieldRollout{Field: "enable_validate_prices", Percent: 50}), 50},
+		{"50% of a false field turns it on inside the rollout", cloudCart(false, FieldRollout{Field: "enable_validate_prices", Percent: 50}), 50},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			stored := test.config.EnableValidatePrices
+			included := 0
+			for _, path := range paths {
+				applied, assignments := ApplyRollouts(test.config, path)
+				bucket := RolloutBucket(CONFIG_TYPE_DEMO_CONFIG, test.config.Rollouts[0], path)
+				want := RolloutAssignment{Field: "enable_validate_prices", Percent: test.percent, Bucket: bucket, Included: bucket < test.percent}
+				if len(assignments) != 1 || assignments[0] != want {
+					t.Fatalf("%v assigned %+v, want %+v", path, assignments, want)
+				}
+				if got := applied.(*CloudCartConfig).EnableValidatePrices; got != want.Included {
+					t.Fatalf("%v in bucket %d got enable_validate_prices %t", path, bucket, got)
+				}
+				if want.Included {
+					included++
+				}
+			}
+			if test.config.EnableValidatePrices != stored {
+				t.Error("ApplyRollouts changed the config it was given")
+			}
+			if test.percent == 50 && (included == 0 || included == len(paths)) {
+				t.Errorf("%d of %d paths included at 50%%", included, len(paths))
+			}
+		})
+	}
+}
+
+func TestApplyRolloutsWithoutRollouts(t *testing.T) {
+	config := &CloudCartConfig{EnableValidatePrices: true}
+	applied, assignments := ApplyRollouts(config, []string{"1"})
+	if applied != ValidatedConfig(config) || assignments != nil {
+		t.Errorf("got %p and %v, want the config itself and no assignments", applied, assignments)
+	}
+}
+
+func TestApplyRolloutsSkipsFieldsItCantSet(t *testing.T) {
+	config := &CloudCartConfig{
+		ConfigMeta: ConfigMeta{Rollouts: []FieldRollout{
+			{Field: "meta", Percent: 100},
+			{Field: "no_such_field", Percent: 100},
+			{Field: "enable_validate_cart_sums", Percent: 100},
+		}},
+	}
+	applied, assignments := ApplyRollouts(config, []string{"1"})
+	if len(assignments) != 1 || assignments[0].Field != "enable_validate_cart_sums" {
+		t.Errorf("assigned %+v, want enable_validate_cart_sums alone", assignments)
+	}
+	if !applied.(*CloudCartConfig).EnableValidateCartSums {
+		t.Error("enable_validate_cart_sums wasn't rolled out")
+	}
+}
@@ -0,0 +1,224 @@
+package entities
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+//Config types declare their field constraints with a validate struct tag, e.g. `validate:"required"` or
+//`validate:"min=0,max=1000"`.  min/max compare numbers by value and strings/slices/maps by length.
+//Anything that can't be expressed as a tag goes in the type's own Validate method; ValidateConfig runs both.
+const validateTag = "validate"
+
+//FieldError is a single failed constraint.  Path is the dotted bson path of the field, rooted at the config type's key
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+//ValidationError is returned when a config fails validation; it lists every failed field, not just the first
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		msgs = append(msgs, fieldErr.Error())
+	}
+
+	return fmt.Sprintf("invalid config: %s", strings.Join(msgs, "; "))
+}
+
+func (e *ValidationError) add(path, message string) {
+	e.Errors = append(e.Errors, FieldError{Path: path, Message: message})
+}
+
+//ValidateConfig checks the validate tags on config, then calls its Validate method.  Failures from either are
+//returned together as a *ValidationError
+func ValidateConfig(config ValidatedConfig) error {
+	root := config.GetConfigType().String()
+	validationErr := &ValidationError{}
+	if err := validateStruct(reflect.ValueOf(config), root, validationErr); err != nil {
+		return err
+	}
+
+	if metaConfig, ok := config.(MetaConfig); ok {
+		meta := metaConfig.GetMeta()
+		fields := configFieldNames(config)
+		for i, override := range meta.Overrides {
+			if !fields[override] {
+				validationErr.add(fmt.Sprintf("%s.meta.overrides.%d", root, i), fmt.Sprintf("unknown field %q", override))
+			}
+		}
+		if meta.EffectiveFrom != nil && meta.EffectiveUntil != nil && !meta.EffectiveUntil.After(*meta.EffectiveFrom) {
+			validationErr.add(fmt.Sprintf("%s.meta.effective_until", root), "must be after effective_from")
+		}
+		validateRollouts(config, meta.Rollouts, root, validationErr)
+	}
+
+	if err := config.Validate(); err != nil {
+		if fieldErrs, ok := err.(*ValidationError); ok {
+			validationErr.Errors = append(validationErr.Errors, fieldErrs.Errors...)
+		} else {
+			validationErr.add(root, err.Error())
+		}
+	}
+
+	if len(validationErr.Errors) > 0 {
+		return validationErr
+	}
+
+	return nil
+}
+
+//configFieldNames returns the top level bson field names of config, excluding meta, i.e. what meta.overrides may name
+func configFieldNames(config ValidatedConfig) map[string]bool {
+	t := reflect.TypeOf(config)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	fields := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if name, stored := bsonFieldName(field); stored && field.PkgPath == "" && name != "" && name != metaField {
+			fields[name] = true
+		}
+	}
+
+	return fields
+}
+
+func validateStruct(v reflect.Value, path string, validationErr *ValidationError) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, stored := bsonFieldName(field)
+		if field.PkgPath != "" || !stored {
+			continue
+		}
+
+		fieldPath := joinPath(path, name)
+		if tag, ok := field.Tag.Lookup(validateTag); ok {
+			if err := applyRules(v.Field(i), fieldPath, tag, validationErr); err != nil {
+				return err
+			}
+		}
+
+		//time.Time and friends have no exported fields, so only structs with something to check are walked
+		if field.Type.Kind() == reflect.Struct || (field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct) {
+			if err := validateStruct(v.Field(i), fieldPath, validationErr); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func applyRules(v reflect.Value, path, tag string, validationErr *ValidationError) error {
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg := rule, ""
+		if idx := strings.Index(rule, "="); idx >= 0 {
+			name, arg = rule[:idx], rule[idx+1:]
+		}
+
+		switch name {
+		case "":
+		case "required":
+			if v.IsZero() {
+				validationErr.add(path, "is required")
+			}
+		case "min", "max":
+			bound, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s rule %q on %s: %w", validateTag, rule, path, err)
+			}
+			value, isLength, ok := measure(v)
+			if !ok {
+				return fmt.Errorf("%s rule %q is not supported on %s (%s)", validateTag, rule, path, v.Kind())
+			}
+			if name == "min" && value < bound {
+				validationErr.add(path, boundMessage("at least", arg, isLength))
+			}
+			if name == "max" && value > bound {
+				validationErr.add(path, boundMessage("at most", arg, isLength))
+			}
+		default:
+			return fmt.Errorf("unknown %s rule %q on %s", validateTag, rule, path)
+		}
+	}
+
+	return nil
+}
+
+func measure(v reflect.Value) (value float64, isLength bool, ok bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false, true
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true, true
+	default:
+		return 0, false, false
+	}
+}
+
+func boundMessage(comparison, bound string, isLength bool) string {
+	if isLength {
+		return fmt.Sprintf("length must be %s %s", comparison, bound)
+	}
+
+	return fmt.Sprintf("must be %s %s", comparison, bound)
+}
+
+//Mirrors the bson codec's naming: the tag's name if present, otherwise the lowercased field name.  Inlined fields
+//add no path segment, and fields tagged "-" aren't stored at all, which the false return reports
+func bsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("bson")
+	parts := strings.Split(tag, ",")
+	if parts[0] == "-" {
+		return "", false
+	}
+	for _, opt := range parts[1:] {
+		if opt == "inline" {
+			return "", true
+		}
+	}
+	if parts[0] != "" {
+		return parts[0], true
+	}
+
+	return strings.ToLower(field.Name), true
+}
+
+func joinPath(path, name string) string {
+	switch {
+	case name == "":
+		return path
+	case path == "":
+		return name
+	default:
+		return path + "." + name
+	}
+}
@@ -0,0 +1,117 @@
+package entities
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var validationEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func validOtherConfig() *OtherConfig {
+	return &OtherConfig{
+		ConfigMeta:      ConfigMeta{Enabled: true, ChangedBy: "alice", ChangedAt: validationEpoch},
+		ADifferentValue: "different",
+		AFloat:          12.5,
+	}
+}
+
+//checkFieldErrors compares err's field errors with want, in order
+func checkFieldErrors(t *testing.T, err error, want []FieldError) {
+	t.Helper()
+	if len(want) == 0 {
+		if err != nil {
+			t.Errorf("got %v, want no error", err)
+		}
+		return
+	}
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("got %v, want a ValidationError", err)
+	}
+	if fmt.Sprint(validationErr.Errors) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", validationErr.Errors, want)
+	}
+}
+
+func TestValidateConfigTagRules(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		modify func(config *OtherConfig)
+		want   []FieldError
+	}{
+		{"valid", func(config *OtherConfig) {}, nil},
+		{"a_float at its upper bound", func(config *OtherConfig) { config.AFloat = 1000 }, nil},
+		{"a_float at its lower bound", func(config *OtherConfig) { config.AFloat = 0 }, nil},
+		{"a_float below min", func(config *OtherConfig) { config.AFloat = -0.5 }, []FieldError{
+			{Path: "other_example.a_float", Message: "must be at least 0"},
+		}},
+		{"a_float above max", func(config *OtherConfig) { config.AFloat = 1000.5 }, []FieldError{
+			{Path: "other_example.a_float", Message: "must be at most 1000"},
+		}},
+		{"a_different_value at its max length", func(config *OtherConfig) { config.ADifferentValue = strings.Repeat("a", 256) }, nil},
+		{"a_different_value too long", func(config *OtherConfig) { config.ADifferentValue = strings.Repeat("a", 257) }, []FieldError{
+			{Path: "other_example.a_different_value", Message: "length must be at most 256"},
+		}},
+		{"changed_by missing", func(config *OtherConfig) { config.ChangedBy = "" }, []FieldError{
+			{Path: "other_example.meta.changed_by", Message: "is required"},
+		}},
+		{"changed_by too long", func(config *OtherConfig) { config.ChangedBy = strings.Repeat("a", 129) }, []FieldError{
+			{Path: "other_example.meta.changed_by", Message: "length must be at most 128"},
+		}},
+		{"changed_at missing", func(config *OtherConfig) { config.ChangedAt = time.Time{} }, []FieldError{
+			{Path: "other_example.meta.changed_at", Message: "is required"},
+		}},
+		{"every failure at once, in field order", func(config *OtherConfig) {
+			config.ChangedBy = ""
+			config.ADifferentValue = strings.Repeat("a", 300)
+			config.AFloat = -1
+		}, []FieldError{
+			{Path: "other_example.meta.changed_by", Message: "is required"},
+			{Path: "other_example.a_different_value", Message: "length must be at most 256"},
+			{Path: "other_example.a_float", Message: "must be at least 0"},
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			config := validOtherConfig()
+			test.modify(config)
+			checkFieldErrors(t, ValidateConfig(config), test.want)
+		})
+	}
+}
+
+func TestValidateConfigRootsPathsAtTheConfigType(t *testing.T) {
+	config := &CloudCartConfig{EnableValidateCartSums: true}
+	checkFieldErrors(t, ValidateConfig(config), []FieldError{
+		{Path: "cloud_cart.meta.changed_by", Message: "is required"},
+		{Path: "cloud_cart.meta.changed_at", Message: "is required"},
+		{Path: "cloud_cart.enable_validate_cart_sums", Message: "requires enable_calculate_reductions_and_taxes"},
+	})
+}
+
+//unstoredFieldConfig has a field the bson codec skips, whose rules and name must not count
+type unstoredFieldConfig struct {
+	ConfigMeta `bson:"meta"`
+	Cached     string `bson:"-" validate:"required"`
+	Kept       bool   `bson:"kept"`
+}
+
+func (c *unstoredFieldConfig) Validate() error {
+	return nil
+}
+
+func (c *unstoredFieldConfig) GetConfigType() ConfigType {
+	return CONFIG_TYPE_DEMO_CONFIG
+}
+
+func TestValidateConfigSkipsUnstoredFields(t *testing.T) {
+	config := &unstoredFieldConfig{ConfigMeta: ConfigMeta{ChangedBy: "alice", ChangedAt: validationEpoch, Overrides: []string{"kept"}}}
+	checkFieldErrors(t, ValidateConfig(config), nil)
+
+	config.Overrides = []string{"kept", "cached"}
+	checkFieldErrors(t, ValidateConfig(config), []FieldError{
+		{Path: "cloud_cart.meta.overrides.1", Message: `unknown field "cached"`},
+	})
+}
@@ -0,0 +1,4 @@
+//Package configpb holds the protobuf definitions for the gRPC config API.  Regenerate after editing config.proto
+package configpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative config.proto
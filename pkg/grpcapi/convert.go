@@ -0,0 +1,214 @@
+package grpcapi
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"github.com/mcquackers/config-demo/pkg/grpcapi/configpb"
+	"github.com/mcquackers/config-demo/pkg/repo"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//The proto enums use the same numbers as the entities constants, so levels and types convert by value.  Anything the
+//other side doesn't know about is an error rather than being passed through.  Levels only arrive inside a Scope, and
+//are checked against the server's hierarchy there
+
+//init fails loudly if a registered config type can't make the round trip through the configpb.Config oneof, which
+//ConfigFromProto and ConfigToProto spell out by hand, rather than letting its calls fail one by one
+func init() {
+	for _, configType := range entities.RegisteredConfigTypes() {
+		if _, ok := configpb.ConfigType_name[int32(configType)]; !ok {
+			panic(fmt.Sprintf("grpcapi: config type %s has no ConfigType enum value", configType.String()))
+		}
+		wrapped, err := ConfigToProto(entities.NewConfig(configType))
+		if err != nil {
+			panic(fmt.Sprintf("grpcapi: config type %s has no oneof case in ConfigToProto", configType.String()))
+		}
+		unwrapped, err := ConfigFromProto(wrapped)
+		if err != nil || unwrapped.GetConfigType() != configType {
+			panic(fmt.Sprintf("grpcapi: config type %s doesn't come back from ConfigFromProto", configType.String()))
+		}
+	}
+}
+
+//ScopeFromProto reads scope against the server's hierarchy.  Levels past CONFIG_LEVEL_VENDOR aren't named in the proto
+//enum but arrive by number, and are valid if the hierarchy is that deep
+func ScopeFromProto(hierarchy *entities.Hierarchy, scope *configpb.Scope) (repo.Scope, error) {
+	configLevel := entities.ConfigLevel(scope.GetLevel())
+	if !hierarchy.ValidLevel(configLevel) {
+		return repo.Scope{}, fmt.Errorf("invalid config level: %s", scope.GetLevel().String())
+	}
+	if ids := scope.GetIds(); len(ids) > 0 {
+		return repo.NewScope(configLevel, ids...), nil
+	}
+
+	return repo.NewScope(configLevel, scope.GetCorporateId(), scope.GetVenueId(), scope.GetVendorId()), nil
+}
+
+func ConfigLevelToProto(level entities.ConfigLevel) configpb.ConfigLevel {
+	if _, ok := configpb.ConfigLevel_name[int32(level)]; !ok {
+		return configpb.ConfigLevel_CONFIG_LEVEL_UNSPECIFIED
+	}
+
+	return configpb.ConfigLevel(level)
+}
+
+func ConfigTypeFromProto(configType configpb.ConfigType) (entities.ConfigType, error) {
+	if _, ok := entities.LookupConfigType(entities.ConfigType(configType)); !ok {
+		return entities.CONFIG_TYPE_UNSPECIFIED, fmt.Errorf("unsupported config type: %s", configType.String())
+	}
+
+	return entities.ConfigType(configType), nil
+}
+
+func ConfigTypeToProto(configType entities.ConfigType) configpb.ConfigType {
+	if _, ok := configpb.ConfigType_name[int32(configType)]; !ok {
+		return configpb.ConfigType_CONFIG_TYPE_UNSPECIFIED
+	}
+
+	return configpb.ConfigType(configType)
+}
+
+func ConfigMetaFromProto(meta *configpb.ConfigMeta) entities.ConfigMeta {
+	if meta == nil {
+		return entities.ConfigMeta{}
+	}
+
+	var changedAt time.Time
+	if meta.GetChangedAt() != nil {
+		changedAt = meta.GetChangedAt().AsTime()
+	}
+
+	return entities.ConfigMeta{
+		Enabled:        meta.GetEnabled(),
+		ChangedBy:      meta.GetChangedBy(),
+		ChangedAt:      changedAt,
+		Overrides:      meta.GetOverrides(),
+		Revision:       meta.GetRevision(),
+		EffectiveFrom:  timeFromProto(meta.GetEffectiveFrom()),
+		EffectiveUntil: timeFromProto(meta.GetEffectiveUntil()),
+		Rollouts:       rolloutsFromProto(meta.GetRollouts()),
+	}
+}
+
+func ConfigMetaToProto(meta entities.ConfigMeta) *configpb.ConfigMeta {
+	var changedAt *timestamppb.Timestamp
+	if !meta.ChangedAt.IsZero() {
+		changedAt = timestamppb.New(meta.ChangedAt)
+	}
+
+	return &configpb.ConfigMeta{
+		Enabled:        meta.Enabled,
+		ChangedBy:      meta.ChangedBy,
+		ChangedAt:      changedAt,
+		Overrides:      meta.Overrides,
+		Revision:       meta.Revision,
+		EffectiveFrom:  timeToProto(meta.EffectiveFrom),
+		EffectiveUntil: timeToProto(meta.EffectiveUntil),
+		Rollouts:       rolloutsToProto(meta.Rollouts),
+	}
+}
+
+//rolloutsFromProto and rolloutsToProto convert the meta's rollouts, which are nil on both sides when there are none
+func rolloutsFromProto(rollouts []*configpb.FieldRollout) []entities.FieldRollout {
+	if len(rollouts) == 0 {
+		return nil
+	}
+	converted := make([]entities.FieldRollout, len(rollouts))
+	for i, rollout := range rollouts {
+		converted[i] = entities.FieldRollout{Field: rollout.GetField(), Percent: int(rollout.GetPercent()), Seed: rollout.GetSeed()}
+	}
+
+	return converted
+}
+
+func rolloutsToProto(rollouts []entities.FieldRollout) []*configpb.FieldRollout {
+	if len(rollouts) == 0 {
+		return nil
+	}
+	converted := make([]*configpb.FieldRollout, len(rollouts))
+	for i, rollout := range rollouts {
+		converted[i] = &configpb.FieldRollout{Field: rollout.Field, Percent: int32(rollout.Percent), Seed: rollout.Seed}
+	}
+
+	return converted
+}
+
+//timeFromProto and timeToProto convert the optional timestamps, which are nil on both sides when unset
+func timeFromProto(t *timestamppb.Timestamp) *time.Time {
+	if t == nil {
+		return nil
+	}
+	converted := t.AsTime()
+
+	return &converted
+}
+
+func timeToProto(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+
+	return timestamppb.New(*t)
+}
+
+func CloudCartConfigFromProto(config *configpb.CloudCartConfig) *entities.CloudCartConfig {
+	return &entities.CloudCartConfig{
+		ConfigMeta:                        ConfigMetaFromProto(config.GetMeta()),
+		EnableCalculateReductionsAndTaxes: config.GetEnableCalculateReductionsAndTaxes(),
+		EnableValidatePrices:              config.GetEnableValidatePrices(),
+		EnableValidateCartSums:            config.GetEnableValidateCartSums(),
+	}
+}
+
+func CloudCartConfigToProto(config *entities.CloudCartConfig) *configpb.CloudCartConfig {
+	return &configpb.CloudCartConfig{
+		Meta:                              ConfigMetaToProto(config.ConfigMeta),
+		EnableCalculateReductionsAndTaxes: config.EnableCalculateReductionsAndTaxes,
+		EnableValidatePrices:              config.EnableValidatePrices,
+		EnableValidateCartSums:            config.EnableValidateCartSums,
+	}
+}
+
+func OtherConfigFromProto(config *configpb.OtherConfig) *entities.OtherConfig {
+	return &entities.OtherConfig{
+		ConfigMeta:      ConfigMetaFromProto(config.GetMeta()),
+		ADifferentValue: config.GetADifferentValue(),
+		AFloat:          config.GetAFloat(),
+	}
+}
+
+func OtherConfigToProto(config *entities.OtherConfig) *configpb.OtherConfig {
+	return &configpb.OtherConfig{
+		Meta:            ConfigMetaToProto(config.ConfigMeta),
+		ADifferentValue: config.ADifferentValue,
+		AFloat:          config.AFloat,
+	}
+}
+
+//ConfigFromProto unwraps the oneof.  A new config type needs a case here and a field on configpb.Config; init checks it
+//has them
+func ConfigFromProto(config *configpb.Config) (entities.ValidatedConfig, error) {
+	switch c := config.GetConfig().(type) {
+	case *configpb.Config_CloudCart:
+		return CloudCartConfigFromProto(c.CloudCart), nil
+	case *configpb.Config_OtherExample:
+		return OtherConfigFromProto(c.OtherExample), nil
+	default:
+		return nil, fmt.Errorf("config is not set")
+	}
+}
+
+//ConfigToProto wraps config in the oneof.  A new config type needs a case here and a field on configpb.Config; init
+//checks it has them
+func ConfigToProto(config entities.ValidatedConfig) (*configpb.Config, error) {
+	switch c := config.(type) {
+	case *entities.CloudCartConfig:
+		return &configpb.Config{Config: &configpb.Config_CloudCart{CloudCart: CloudCartConfigToProto(c)}}, nil
+	case *entities.OtherConfig:
+		return &configpb.Config{Config: &configpb.Config_OtherExample{OtherExample: OtherConfigToProto(c)}}, nil
+	default:
+		return nil, fmt.Errorf("config type %d has no protobuf message", config.GetConfigType())
+	}
+}
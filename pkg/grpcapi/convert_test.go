@@ -0,0 +1,154 @@
+package grpcapi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"github.com/mcquackers/config-demo/pkg/grpcapi/configpb"
+	"github.com/mcquackers/config-demo/pkg/repo"
+	"google.golang.org/protobuf/proto"
+)
+
+//testMeta sets every meta field, rollouts and both ends of the effective window included
+func testMeta() entities.ConfigMeta {
+	from := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	until := from.Add(36 * time.Hour)
+
+	return entities.ConfigMeta{
+		Enabled:        true,
+		ChangedBy:      "alice",
+		ChangedAt:      from.Add(-time.Hour + 5*time.Nanosecond),
+		Overrides:      []string{"enable_validate_prices"},
+		Revision:       7,
+		EffectiveFrom:  &from,
+		EffectiveUntil: &until,
+		Rollouts: []entities.FieldRollout{
+			{Field: "enable_validate_prices", Percent: 25},
+			{Field: "enable_validate_cart_sums", Percent: 100, Seed: "second wave"},
+		},
+	}
+}
+
+//testConfigs has a config with every field set for each registered config type
+func testConfigs() map[entities.ConfigType]entities.ValidatedConfig {
+	return map[entities.ConfigType]entities.ValidatedConfig{
+		entities.CONFIG_TYPE_DEMO_CONFIG: &entities.CloudCartConfig{
+			ConfigMeta:                        testMeta(),
+			EnableCalculateReductionsAndTaxes: true,
+			EnableValidatePrices:              true,
+			EnableValidateCartSums:            true,
+		},
+		entities.CONFIG_TYPE_OTHER_EXAMPLE: &entities.OtherConfig{
+			ConfigMeta:      testMeta(),
+			ADifferentValue: "different",
+			AFloat:          12.5,
+		},
+	}
+}
+
+func TestConfigRoundTrip(t *testing.T) {
+	configs := testConfigs()
+	for _, configType := range entities.RegisteredConfigTypes() {
+		config, ok := configs[configType]
+		if !ok {
+			t.Errorf("config type %s has no test config", configType.String())
+			continue
+		}
+
+		wrapped, err := ConfigToProto(config)
+		if err != nil {
+			t.Fatalf("%s: %v", configType.String(), err)
+		}
+		unwrapped, err := ConfigFromProto(wrapped)
+		if err != nil {
+			t.Fatalf("%s: %v", configType.String(), err)
+		}
+		if !reflect.DeepEqual(unwrapped, config) {
+			t.Errorf("%s came back as %+v, want %+v", configType.String(), unwrapped, config)
+		}
+
+		//and the other way round, from the message
+		rewrapped, err := ConfigToProto(unwrapped)
+		if err != nil {
+			t.Fatalf("%s: %v", configType.String(), err)
+		}
+		if !proto.Equal(rewrapped, wrapped) {
+			t.Errorf("%s's message came back as %v, want %v", configType.String(), rewrapped, wrapped)
+		}
+	}
+}
+
+func TestConfigMetaLeavesUnsetFieldsUnset(t *testing.T) {
+	meta := ConfigMetaToProto(entities.ConfigMeta{ChangedBy: "alice"})
+	if meta.GetChangedAt() != nil || meta.GetEffectiveFrom() != nil || meta.GetEffectiveUntil() != nil || meta.GetRollouts() != nil {
+		t.Errorf("an empty meta converted to %v", meta)
+	}
+	if got := ConfigMetaFromProto(meta); !reflect.DeepEqual(got, entities.ConfigMeta{ChangedBy: "alice"}) {
+		t.Errorf("an empty meta came back as %+v", got)
+	}
+	if got := ConfigMetaFromProto(nil); !reflect.DeepEqual(got, entities.ConfigMeta{}) {
+		t.Errorf("a missing meta converted to %+v", got)
+	}
+}
+
+func TestConfigFromProtoRefusesAnUnsetOneof(t *testing.T) {
+	for _, config := range []*configpb.Config{nil, {}} {
+		if got, err := ConfigFromProto(config); err == nil {
+			t.Errorf("%v converted to %+v", config, got)
+		}
+	}
+	if got, err := ConfigToProto(&entities.LevelConfig{}); err == nil {
+		t.Errorf("the full config converted to %v", got)
+	}
+}
+
+func TestConfigTypes(t *testing.T) {
+	for _, configType := range entities.RegisteredConfigTypes() {
+		got, err := ConfigTypeFromProto(ConfigTypeToProto(configType))
+		if err != nil || got != configType {
+			t.Errorf("%s came back as %s, %v", configType.String(), got.String(), err)
+		}
+	}
+	for _, configType := range []configpb.ConfigType{configpb.ConfigType_CONFIG_TYPE_UNSPECIFIED, configpb.ConfigType(99)} {
+		if got, err := ConfigTypeFromProto(configType); err == nil {
+			t.Errorf("%s converted to %s", configType.String(), got.String())
+		}
+	}
+	if got := ConfigTypeToProto(entities.ConfigType(99)); got != configpb.ConfigType_CONFIG_TYPE_UNSPECIFIED {
+		t.Errorf("an unknown config type converted to %s", got.String())
+	}
+}
+
+func TestScopeFromProto(t *testing.T) {
+	deep, err := entities.HierarchyFromNames("corporate", "region=cloud_cart", "venue", "vendor", "terminal=other_example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name      string
+		hierarchy *entities.Hierarchy
+		scope     *configpb.Scope
+		want      repo.Scope
+	}{
+		{"named IDs", entities.DefaultHierarchy(), &configpb.Scope{Level: configpb.ConfigLevel_CONFIG_LEVEL_VENUE, CorporateId: "1", VenueId: "2"}, repo.VenueScope("1", "2")},
+		{"named IDs past the level", entities.DefaultHierarchy(), &configpb.Scope{Level: configpb.ConfigLevel_CONFIG_LEVEL_CORPORATE, CorporateId: "1", VenueId: "2"}, repo.CorporateScope("1")},
+		{"ids", entities.DefaultHierarchy(), &configpb.Scope{Level: configpb.ConfigLevel_CONFIG_LEVEL_VENDOR, Ids: []string{"1", "2", "3"}, CorporateId: "9"}, repo.VendorScope("1", "2", "3")},
+		{"a level past vendor", deep, &configpb.Scope{Level: configpb.ConfigLevel(5), Ids: []string{"1", "2", "3", "4", "5"}}, repo.NewScope(5, "1", "2", "3", "4", "5")},
+	} {
+		got, err := ScopeFromProto(test.hierarchy, test.scope)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got.String(), test.want.String())
+		}
+	}
+
+	for _, level := range []configpb.ConfigLevel{configpb.ConfigLevel_CONFIG_LEVEL_UNSPECIFIED, configpb.ConfigLevel(4)} {
+		if got, err := ScopeFromProto(entities.DefaultHierarchy(), &configpb.Scope{Level: level, Ids: []string{"1", "2", "3", "4"}}); err == nil {
+			t.Errorf("level %s converted to %s", level.String(), got.String())
+		}
+	}
+}
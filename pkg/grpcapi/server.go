@@ -0,0 +1,138 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"github.com/mcquackers/config-demo/pkg/grpcapi/configpb"
+	"github.com/mcquackers/config-demo/pkg/repo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var _ configpb.ConfigServiceServer = (*Server)(nil)
+
+//tenantMetadataKey is the request metadata naming the tenant a call acts for, on repositories built with
+//repo.WithTenantResolver
+const tenantMetadataKey = "tenant-id"
+
+//Server implements configpb.ConfigServiceServer on top of a ConfigRepository
+type Server struct {
+	configpb.UnimplementedConfigServiceServer
+	repo repo.ConfigRepository
+}
+
+func NewServer(configRepo repo.ConfigRepository) *Server {
+	return &Server{repo: configRepo}
+}
+
+func (s *Server) SetConfig(ctx context.Context, req *configpb.SetConfigRequest) (*configpb.SetConfigResponse, error) {
+	ctx = withTenant(ctx)
+	scope, err := ScopeFromProto(s.repo.Hierarchy(), req.GetScope())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	config, err := ConfigFromProto(req.GetConfig())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var stored entities.ValidatedConfig
+	if expected := req.GetExpectedRevision(); expected != nil {
+		stored, err = s.repo.SetConfigIfRevision(ctx, scope, config, expected.GetValue())
+	} else {
+		stored, err = s.repo.SetConfig(ctx, scope, config)
+	}
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	resp, err := ConfigToProto(stored)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &configpb.SetConfigResponse{Config: resp}, nil
+}
+
+func (s *Server) GetSpecificConfig(ctx context.Context, req *configpb.GetConfigRequest) (*configpb.GetConfigResponse, error) {
+	return s.getConfig(ctx, req, s.repo.GetSpecificConfig)
+}
+
+func (s *Server) GetActiveConfig(ctx context.Context, req *configpb.GetConfigRequest) (*configpb.GetConfigResponse, error) {
+	var opts []repo.ActiveOption
+	if req.GetAsOf() != nil {
+		opts = append(opts, repo.AsOf(req.GetAsOf().AsTime()))
+	}
+
+	return s.getConfig(ctx, req, func(ctx context.Context, scope repo.Scope, configType entities.ConfigType) (entities.ValidatedConfig, error) {
+		return s.repo.GetActiveConfig(ctx, scope, configType, opts...)
+	})
+}
+
+type getConfigFunc func(ctx context.Context, scope repo.Scope, configType entities.ConfigType) (entities.ValidatedConfig, error)
+
+func (s *Server) getConfig(ctx context.Context, req *configpb.GetConfigRequest, get getConfigFunc) (*configpb.GetConfigResponse, error) {
+	ctx = withTenant(ctx)
+	scope, err := ScopeFromProto(s.repo.Hierarchy(), req.GetScope())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	configType, err := ConfigTypeFromProto(req.GetConfigType())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	config, err := get(ctx, scope, configType)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	resp, err := ConfigToProto(config)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &configpb.GetConfigResponse{Config: resp}, nil
+}
+
+//withTenant carries the tenant named in the call's metadata, if any, into ctx
+func withTenant(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(tenantMetadataKey); len(values) > 0 {
+		return repo.WithTenant(ctx, values[0])
+	}
+
+	return ctx
+}
+
+//statusFromError is the gRPC counterpart of httpapi's writeError
+func statusFromError(err error) error {
+	var notAllowed entities.ErrConfigTypeNotAllowedAtLevel
+	var validationErr *entities.ValidationError
+	var conflict repo.ErrRevisionConflict
+	var configNotFound repo.ErrConfigNotFound
+	var scopeNotFound repo.ErrScopeNotFound
+	var versionNotFound repo.ErrHistoryVersionNotFound
+	var ambiguous repo.ErrAmbiguousScope
+	var tenantRequired repo.ErrTenantRequired
+	var invalidTenant repo.ErrInvalidTenant
+	switch {
+	case errors.As(err, &configNotFound), errors.As(err, &scopeNotFound), errors.As(err, &versionNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.As(err, &validationErr), errors.As(err, &notAllowed), errors.As(err, &tenantRequired), errors.As(err, &invalidTenant):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &conflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.As(err, &ambiguous):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
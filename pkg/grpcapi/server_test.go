@@ -0,0 +1,45 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"github.com/mcquackers/config-demo/pkg/repo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatusFromError(t *testing.T) {
+	scope := repo.VenueScope("1", "2")
+	for _, test := range []struct {
+		err  error
+		code codes.Code
+	}{
+		{repo.ErrConfigNotFound{Scope: scope, ConfigType: entities.CONFIG_TYPE_DEMO_CONFIG}, codes.NotFound},
+		{repo.ErrScopeNotFound{Scope: scope}, codes.NotFound},
+		{repo.ErrHistoryVersionNotFound{}, codes.NotFound},
+		{&entities.ValidationError{Errors: []entities.FieldError{{Path: "cloud_cart.meta.changed_by", Message: "is required"}}}, codes.InvalidArgument},
+		{entities.ErrConfigTypeNotAllowedAtLevel{ConfigLevel: entities.CONFIG_LEVEL_VENUE, ConfigType: entities.CONFIG_TYPE_OTHER_EXAMPLE}, codes.InvalidArgument},
+		{repo.ErrTenantRequired{}, codes.InvalidArgument},
+		{repo.ErrInvalidTenant{}, codes.InvalidArgument},
+		{repo.ErrRevisionConflict{ConfigType: entities.CONFIG_TYPE_DEMO_CONFIG, Expected: 1, Actual: 2}, codes.Aborted},
+		{repo.ErrAmbiguousScope{Scope: scope, ConfigType: entities.CONFIG_TYPE_DEMO_CONFIG}, codes.FailedPrecondition},
+		{context.Canceled, codes.Canceled},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{errors.New("connection refused"), codes.Internal},
+		//Wrapped errors map like the ones they wrap
+		{fmt.Errorf("setting config: %w", repo.ErrRevisionConflict{}), codes.Aborted},
+		{fmt.Errorf("reading config: %w", context.DeadlineExceeded), codes.DeadlineExceeded},
+	} {
+		got := status.Convert(statusFromError(test.err))
+		if got.Code() != test.code {
+			t.Errorf("%T %q: got %s, want %s", test.err, test.err.Error(), got.Code(), test.code)
+		}
+		if got.Message() != test.err.Error() {
+			t.Errorf("%T: got message %q, want %q", test.err, got.Message(), test.err.Error())
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"github.com/mcquackers/config-demo/pkg/repo"
+)
+
+//maxBodyBytes caps PUT bodies; configs are small, flat documents
+const maxBodyBytes = 1 << 20
+
+//Handler serves a ConfigRepository over HTTP/JSON.  Configs are addressed by scope, with the level implied by how deep
+//the path goes.  Each level of the repository's hierarchy adds a pair of segments, named after the level with an "s";
+//in the default hierarchy that is:
+//
+//	/corporates/{corp}/configs/{type}
+//	/corporates/{corp}/venues/{venue}/configs/{type}
+//	/corporates/{corp}/venues/{venue}/vendors/{vendor}/configs/{type}
+//
+//{type} is a registered config key, e.g. cloud_cart, or "full" for the whole scope document.  GET takes
+//?mode=specific (the default), active, resolved (not for "full") or explain, and the last three an RFC 3339 ?as_of= to
+//evaluate effective windows at; PUT takes a JSON config body and calls SetConfig; DELETE takes ?changed_by= and calls
+//DeleteConfig.
+//
+//A request's X-Tenant-ID header names the tenant it acts for, on repositories built with repo.WithTenantResolver.
+//
+//Specific reads and PUT responses carry the config's meta.revision as a strong ETag.  A PUT with If-Match: "<revision>"
+//only goes through at that revision, and If-None-Match: * only if the config isn't set yet; either fails with 412
+type Handler struct {
+	repo repo.ConfigRepository
+	now  func() time.Time
+}
+
+func NewHandler(configRepo repo.ConfigRepository) *Handler {
+	return &Handler{
+		repo: configRepo,
+		now:  time.Now,
+	}
+}
+
+//configRequest is a parsed config path
+type configRequest struct {
+	scope      repo.Scope
+	configType entities.ConfigType
+}
+
+type errorResponse struct {
+	Error  string                `json:"error"`
+	Fields []entities.FieldError `json:"fields,omitempty"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := parseConfigPath(h.repo.Hierarchy(), r.URL.Path)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+	if tenantID := r.Header.Get("X-Tenant-ID"); tenantID != "" {
+		r = r.WithContext(repo.WithTenant(r.Context(), tenantID))
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.getConfig(w, r, req)
+	case http.MethodPut:
+		h.putConfig(w, r, req)
+	case http.MethodDelete:
+		h.deleteConfig(w, r, req)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: fmt.Sprintf("method %s not allowed", r.Method)})
+	}
+}
+
+func (h *Handler) getConfig(w http.ResponseWriter, r *http.Request, req configRequest) {
+	ctx := r.Context()
+
+	var result interface{}
+	var err error
+	var opts []repo.ActiveOption
+	if asOf := r.URL.Query().Get("as_of"); asOf != "" {
+		t, err := time.Parse(time.RFC3339, asOf)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid as_of: %s", err.Error())})
+			return
+		}
+		opts = append(opts, repo.AsOf(t))
+	}
+
+	mode := r.URL.Query().Get("mode")
+	switch mode {
+	case "", "specific":
+		result, err = h.repo.GetSpecificConfig(ctx, req.scope, req.configType)
+	case "active":
+		result, err = h.repo.GetActiveConfig(ctx, req.scope, req.configType, opts...)
+	case "resolved":
+		//Resolving merges one type's fields down the chain; the full document has no fields of its own to merge
+		if req.configType == entities.CONFIG_TYPE_FULL {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "the full config cannot be resolved; GET each config type"})
+			return
+		}
+		result, err = h.repo.GetResolvedConfig(ctx, req.scope, req.configType, opts...)
+	case "explain":
+		result, err = h.repo.ExplainActiveConfig(ctx, req.scope, req.configType, opts...)
+	default:
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("unknown mode %q", mode)})
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	//Only the specific config is the one a PUT to this path would replace, so only it gets an ETag
+	if config, ok := result.(entities.ValidatedConfig); ok && (mode == "" || mode == "specific") {
+		setETag(w, config)
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h *Handler) putConfig(w http.ResponseWriter, r *http.Request, req configRequest) {
+	config := entities.NewConfig(req.configType)
+	if config == nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "the full config cannot be set; PUT each config type"})
+		return
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid body: %s", err.Error())})
+		return
+	}
+	if meta, ok := config.(entities.MetaConfig); ok && meta.GetMeta().ChangedAt.IsZero() {
+		meta.GetMeta().ChangedAt = h.now()
+	}
+
+	expectedRevision, conditional, err := parsePrecondition(r.Header)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	var stored entities.ValidatedConfig
+	if conditional {
+		stored, err = h.repo.SetConfigIfRevision(r.Context(), req.scope, config, expectedRevision)
+	} else {
+		stored, err = h.repo.SetConfig(r.Context(), req.scope, config)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	setETag(w, stored)
+
+	writeJSON(w, http.StatusOK, stored)
+}
+
+func (h *Handler) deleteConfig(w http.ResponseWriter, r *http.Request, req configRequest) {
+	if req.configType == entities.CONFIG_TYPE_FULL {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "the full config cannot be deleted; DELETE each config type"})
+		return
+	}
+	changedBy := r.URL.Query().Get("changed_by")
+	if changedBy == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "changed_by is required"})
+		return
+	}
+
+	if err := h.repo.DeleteConfig(r.Context(), req.scope, req.configType, changedBy); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//parsePrecondition reads the revision a PUT is conditional on.  If-Match takes a single strong ETag as set by setETag;
+//If-None-Match: * asks for revision 0, i.e. the config must not be set yet
+func parsePrecondition(header http.Header) (int64, bool, error) {
+	ifMatch, ifNoneMatch := header.Get("If-Match"), header.Get("If-None-Match")
+	switch {
+	case ifMatch != "" && ifNoneMatch != "":
+		return 0, false, fmt.Errorf("send either If-Match or If-None-Match, not both")
+	case ifNoneMatch != "":
+		if strings.TrimSpace(ifNoneMatch) != "*" {
+			return 0, false, fmt.Errorf("If-None-Match only supports *")
+		}
+		return 0, true, nil
+	case ifMatch != "":
+		tag := strings.TrimSpace(ifMatch)
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			return 0, false, fmt.Errorf("If-Match must be a single ETag from a previous response")
+		}
+		revision, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+		if err != nil || revision < 0 {
+			return 0, false, fmt.Errorf("If-Match must be a single ETag from a previous response")
+		}
+		return revision, true, nil
+	default:
+		return 0, false, nil
+	}
+}
+
+//setETag tags a single config with its revision; the aggregate full config has none
+func setETag(w http.ResponseWriter, config entities.ValidatedConfig) {
+	if meta, ok := config.(entities.MetaConfig); ok {
+		w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(meta.GetMeta().Revision, 10)))
+	}
+}
+
+func parseConfigPath(hierarchy *entities.Hierarchy, path string) (configRequest, error) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	req := configRequest{}
+	for _, segment := range segments {
+		if segment == "" {
+			return req, fmt.Errorf("no route for %s", path)
+		}
+	}
+
+	//{name}s/{id} for each level down to the scope's, then configs/{type}
+	depth := len(segments)/2 - 1
+	if len(segments)%2 != 0 || depth < 1 || depth > hierarchy.Depth() || segments[len(segments)-2] != "configs" {
+		return req, fmt.Errorf("no route for %s", path)
+	}
+	ids := make([]string, depth)
+	for i := range ids {
+		if segments[2*i] != hierarchy.LevelName(entities.ConfigLevel(i+1))+"s" {
+			return req, fmt.Errorf("no route for %s", path)
+		}
+		ids[i] = segments[2*i+1]
+	}
+	req.scope = repo.NewScope(entities.ConfigLevel(depth), ids...)
+
+	configType, err := entities.ParseConfigType(segments[len(segments)-1])
+	if err != nil {
+		return req, err
+	}
+	req.configType = configType
+
+	return req, nil
+}
+
+//writeError maps repository errors onto status codes: anything the caller got wrong is a 400, a missing target a 404,
+//duplicate scope documents a 409, a failed precondition a 412, the rest are 500s
+func writeError(w http.ResponseWriter, err error) {
+	var notAllowed entities.ErrConfigTypeNotAllowedAtLevel
+	var validationErr *entities.ValidationError
+	var conflict repo.ErrRevisionConflict
+	var configNotFound repo.ErrConfigNotFound
+	var scopeNotFound repo.ErrScopeNotFound
+	var versionNotFound repo.ErrHistoryVersionNotFound
+	var ambiguous repo.ErrAmbiguousScope
+	var tenantRequired repo.ErrTenantRequired
+	var invalidTenant repo.ErrInvalidTenant
+	switch {
+	case errors.As(err, &configNotFound), errors.As(err, &scopeNotFound), errors.As(err, &versionNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+	case errors.As(err, &conflict):
+		writeJSON(w, http.StatusPreconditionFailed, errorResponse{Error: err.Error()})
+	case errors.As(err, &ambiguous):
+		writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+	case errors.As(err, &validationErr):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error(), Fields: validationErr.Errors})
+	case errors.As(err, &notAllowed), errors.As(err, &tenantRequired), errors.As(err, &invalidTenant):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+	default:
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
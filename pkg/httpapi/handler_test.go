@@ -0,0 +1,304 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"github.com/mcquackers/config-demo/pkg/repo"
+)
+
+var testEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestHandler() *Handler {
+	now := func() time.Time { return testEpoch }
+	h := NewHandler(repo.NewMemoryRepo(repo.WithClock(now)))
+	h.now = now
+
+	return h
+}
+
+//serve sends a request to h; headers are name, value pairs
+func serve(h http.Handler, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec
+}
+
+//mustServe fails the test unless the request gets status
+func mustServe(t *testing.T, h http.Handler, status int, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := serve(h, method, path, body, headers...)
+	if rec.Code != status {
+		t.Fatalf("%s %s: got %d %s, want %d", method, path, rec.Code, rec.Body.String(), status)
+	}
+
+	return rec
+}
+
+func decodeCloudCart(t *testing.T, rec *httptest.ResponseRecorder) *entities.CloudCartConfig {
+	t.Helper()
+	config := &entities.CloudCartConfig{}
+	if err := json.Unmarshal(rec.Body.Bytes(), config); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body.String(), err)
+	}
+
+	return config
+}
+
+const aliceCloudCart = `{"meta": {"enabled": true, "changed_by": "alice"}, "enable_validate_prices": true}`
+
+func TestRoutes(t *testing.T) {
+	h := newTestHandler()
+	mustServe(t, h, http.StatusOK, http.MethodPut, "/corporates/1/configs/cloud_cart", aliceCloudCart)
+
+	for _, test := range []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/corporates/1/configs/cloud_cart", http.StatusOK},
+		{http.MethodGet, "/corporates/1/venues/2/configs/cloud_cart", http.StatusOK},
+		{http.MethodGet, "/corporates/1/venues/2/vendors/3/configs/other_example", http.StatusOK},
+		{http.MethodGet, "/corporates/1/venues/2/vendors/3/configs/full", http.StatusOK},
+		{http.MethodGet, "/corporates/1/configs/cloud_cart/", http.StatusOK},
+		{http.MethodGet, "/", http.StatusNotFound},
+		{http.MethodGet, "/corporates/1", http.StatusNotFound},
+		{http.MethodGet, "/corporates/1/configs", http.StatusNotFound},
+		{http.MethodGet, "/venues/2/configs/cloud_cart", http.StatusNotFound},
+		{http.MethodGet, "/corporates/1/vendors/3/configs/cloud_cart", http.StatusNotFound},
+		{http.MethodGet, "/corporates//configs/cloud_cart", http.StatusNotFound},
+		{http.MethodGet, "/corporates/1/settings/cloud_cart", http.StatusNotFound},
+		{http.MethodGet, "/corporates/1/configs/no_such_config", http.StatusNotFound},
+		{http.MethodGet, "/corporates/1/venues/2/vendors/3/terminals/4/configs/cloud_cart", http.StatusNotFound},
+		{http.MethodPost, "/corporates/1/configs/cloud_cart", http.StatusMethodNotAllowed},
+	} {
+		rec := serve(h, test.method, test.path, "")
+		if rec.Code != test.status {
+			t.Errorf("%s %s: got %d %s, want %d", test.method, test.path, rec.Code, rec.Body.String(), test.status)
+		}
+		if got := rec.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("%s %s: Content-Type is %q", test.method, test.path, got)
+		}
+	}
+
+	rec := serve(h, http.MethodPost, "/corporates/1/configs/cloud_cart", "")
+	if got := rec.Header().Get("Allow"); got != "GET, PUT, DELETE" {
+		t.Errorf("405 allows %q", got)
+	}
+
+	//Reads at each level see the corporate's config through the path's scope
+	rec = mustServe(t, h, http.StatusOK, http.MethodGet, "/corporates/1/venues/2/vendors/3/configs/cloud_cart?mode=active", "")
+	if got := decodeCloudCart(t, rec).ChangedBy; got != "alice" {
+		t.Errorf("the vendor's active config is from %q, want alice", got)
+	}
+	rec = mustServe(t, h, http.StatusOK, http.MethodGet, "/corporates/1/venues/2/configs/cloud_cart", "")
+	if got := decodeCloudCart(t, rec).ChangedBy; got != "" {
+		t.Errorf("the venue's specific config is from %q, want unset", got)
+	}
+
+	mustServe(t, h, http.StatusNoContent, http.MethodDelete, "/corporates/1/configs/cloud_cart?changed_by=bob", "")
+	rec = mustServe(t, h, http.StatusOK, http.MethodGet, "/corporates/1/configs/cloud_cart", "")
+	if got := decodeCloudCart(t, rec).ChangedBy; got != "" {
+		t.Errorf("the deleted config is still from %q", got)
+	}
+}
+
+//ambiguousRepo fails every specific read as if the scope had duplicate documents
+type ambiguousRepo struct {
+	repo.ConfigRepository
+}
+
+func (r ambiguousRepo) GetSpecificConfig(ctx context.Context, scope repo.Scope, configType entities.ConfigType) (entities.ValidatedConfig, error) {
+	return nil, repo.ErrAmbiguousScope{Scope: scope, ConfigType: configType}
+}
+
+func TestErrorStatus(t *testing.T) {
+	h := newTestHandler()
+	mustServe(t, h, http.StatusOK, http.MethodPut, "/corporates/1/configs/cloud_cart", aliceCloudCart)
+
+	for _, test := range []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		headers []string
+		status  int
+	}{
+		{"invalid payload", http.MethodPut, "/corporates/1/configs/cloud_cart", `{"meta": {"changed_by": "alice"}, "enable_validate_cart_sums": true}`, nil, http.StatusBadRequest},
+		{"missing changed_by", http.MethodPut, "/corporates/1/configs/cloud_cart", `{"meta": {"enabled": true}}`, nil, http.StatusBadRequest},
+		{"unknown field", http.MethodPut, "/corporates/1/configs/cloud_cart", `{"enable_everything": true}`, nil, http.StatusBadRequest},
+		{"malformed body", http.MethodPut, "/corporates/1/configs/cloud_cart", `{`, nil, http.StatusBadRequest},
+		{"type not allowed at the level", http.MethodPut, "/corporates/1/venues/2/configs/other_example", `{"meta": {"changed_by": "alice"}}`, nil, http.StatusBadRequest},
+		{"setting the full config", http.MethodPut, "/corporates/1/configs/full", `{}`, nil, http.StatusBadRequest},
+		{"deleting the full config", http.MethodDelete, "/corporates/1/configs/full?changed_by=bob", "", nil, http.StatusBadRequest},
+		{"deleting without changed_by", http.MethodDelete, "/corporates/1/configs/cloud_cart", "", nil, http.StatusBadRequest},
+		{"deleting an unset config", http.MethodDelete, "/corporates/1/venues/2/configs/cloud_cart?changed_by=bob", "", nil, http.StatusNotFound},
+		{"resolving the full config", http.MethodGet, "/corporates/1/configs/full?mode=resolved", "", nil, http.StatusBadRequest},
+		{"unknown mode", http.MethodGet, "/corporates/1/configs/cloud_cart?mode=newest", "", nil, http.StatusBadRequest},
+		{"stale If-Match", http.MethodPut, "/corporates/1/configs/cloud_cart", aliceCloudCart, []string{"If-Match", `"7"`}, http.StatusPreconditionFailed},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rec := serve(h, test.method, test.path, test.body, test.headers...)
+			if rec.Code != test.status {
+				t.Fatalf("got %d %s, want %d", rec.Code, rec.Body.String(), test.status)
+			}
+			var body errorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error == "" {
+				t.Errorf("the body %s isn't an error response", rec.Body.String())
+			}
+		})
+	}
+
+	//Validation errors carry the failing fields' paths
+	rec := mustServe(t, h, http.StatusBadRequest, http.MethodPut, "/corporates/1/configs/cloud_cart", `{"meta": {"changed_by": "alice"}, "enable_validate_cart_sums": true}`)
+	var body errorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Fields) != 1 || body.Fields[0].Path != "cloud_cart.enable_validate_cart_sums" {
+		t.Errorf("got fields %+v, want cloud_cart.enable_validate_cart_sums", body.Fields)
+	}
+
+	ambiguous := NewHandler(ambiguousRepo{repo.NewMemoryRepo()})
+	mustServe(t, ambiguous, http.StatusConflict, http.MethodGet, "/corporates/1/configs/cloud_cart", "")
+}
+
+func TestETag(t *testing.T) {
+	h := newTestHandler()
+	path := "/corporates/1/configs/cloud_cart"
+
+	for i, want := range []string{`"1"`, `"2"`} {
+		rec := mustServe(t, h, http.StatusOK, http.MethodPut, path, aliceCloudCart)
+		if got := rec.Header().Get("ETag"); got != want {
+			t.Errorf("PUT %d is tagged %q, want %s", i+1, got, want)
+		}
+		if got := decodeCloudCart(t, rec).Revision; got != int64(i+1) {
+			t.Errorf("PUT %d stored revision %d", i+1, got)
+		}
+	}
+
+	for _, test := range []struct {
+		query string
+		want  string
+	}{
+		{"", `"2"`},
+		{"?mode=specific", `"2"`},
+		{"?mode=active", ""},
+		{"?mode=resolved", ""},
+		{"?mode=explain", ""},
+	} {
+		rec := mustServe(t, h, http.StatusOK, http.MethodGet, path+test.query, "")
+		if got := rec.Header().Get("ETag"); got != test.want {
+			t.Errorf("GET %s is tagged %q, want %q", test.query, got, test.want)
+		}
+	}
+
+	rec := mustServe(t, h, http.StatusOK, http.MethodGet, "/corporates/1/configs/full", "")
+	if got := rec.Header().Get("ETag"); got != "" {
+		t.Errorf("the full config is tagged %q", got)
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	h := newTestHandler()
+	path := "/corporates/1/configs/cloud_cart"
+
+	//If-None-Match: * only writes a config that isn't set yet
+	mustServe(t, h, http.StatusOK, http.MethodPut, path, aliceCloudCart, "If-None-Match", "*")
+	mustServe(t, h, http.StatusPreconditionFailed, http.MethodPut, path, aliceCloudCart, "If-None-Match", "*")
+
+	//If-Match writes at the tagged revision, once
+	rec := mustServe(t, h, http.StatusOK, http.MethodPut, path, aliceCloudCart, "If-Match", `"1"`)
+	if got := rec.Header().Get("ETag"); got != `"2"` {
+		t.Errorf("the conditional PUT is tagged %q, want \"2\"", got)
+	}
+	mustServe(t, h, http.StatusPreconditionFailed, http.MethodPut, path, aliceCloudCart, "If-Match", `"1"`)
+	mustServe(t, h, http.StatusOK, http.MethodPut, path, aliceCloudCart, "If-Match", ` "2" `)
+
+	for _, headers := range [][]string{
+		{"If-Match", "3"},
+		{"If-Match", `"three"`},
+		{"If-Match", `"-1"`},
+		{"If-Match", `"3", "4"`},
+		{"If-Match", `W/"3"`},
+		{"If-None-Match", `"3"`},
+		{"If-Match", `"3"`, "If-None-Match", "*"},
+	} {
+		if rec := serve(h, http.MethodPut, path, aliceCloudCart, headers...); rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: got %d %s, want 400", headers, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec = mustServe(t, h, http.StatusOK, http.MethodGet, path, "")
+	if got := rec.Header().Get("ETag"); got != `"3"` {
+		t.Errorf("after the refused PUTs the config is tagged %q, want \"3\"", got)
+	}
+}
+
+func TestAsOf(t *testing.T) {
+	h := newTestHandler()
+	from := testEpoch.Add(time.Hour)
+	body, err := json.Marshal(map[string]interface{}{
+		"meta": map[string]interface{}{"enabled": true, "changed_by": "alice", "effective_from": from},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustServe(t, h, http.StatusOK, http.MethodPut, "/corporates/1/configs/cloud_cart", string(body))
+
+	vendor := "/corporates/1/venues/2/vendors/3/configs/cloud_cart"
+	for _, test := range []struct {
+		query string
+		want  string
+	}{
+		{"?mode=active", ""},
+		{"?mode=active&as_of=" + from.Add(-time.Second).Format(time.RFC3339), ""},
+		{"?mode=active&as_of=" + from.Format(time.RFC3339), "alice"},
+		{"?mode=active&as_of=" + from.Add(time.Hour).Format(time.RFC3339), "alice"},
+		{"?mode=active&as_of=2024-01-01T01:30:00%2B01:00", ""},
+		{"?mode=active&as_of=2024-01-01T02:00:00Z", "alice"},
+	} {
+		rec := mustServe(t, h, http.StatusOK, http.MethodGet, vendor+test.query, "")
+		if got := decodeCloudCart(t, rec).ChangedBy; got != test.want {
+			t.Errorf("GET %s is from %q, want %q", test.query, got, test.want)
+		}
+	}
+
+	rec := mustServe(t, h, http.StatusOK, http.MethodGet, vendor+"?mode=resolved&as_of="+from.Format(time.RFC3339), "")
+	var resolved struct {
+		Config *entities.CloudCartConfig `json:"config"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resolved); err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Config == nil || resolved.Config.ChangedBy != "alice" {
+		t.Errorf("resolved as of %s to %s, want alice's config", from, rec.Body.String())
+	}
+
+	rec = mustServe(t, h, http.StatusOK, http.MethodGet, vendor+"?mode=explain&as_of="+from.Format(time.RFC3339), "")
+	var explanation struct {
+		AsOf   time.Time `json:"as_of"`
+		Winner int       `json:"winner"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &explanation); err != nil {
+		t.Fatal(err)
+	}
+	if !explanation.AsOf.Equal(from) || explanation.Winner < 0 {
+		t.Errorf("explained as of %s with winner %d, want as of %s with a winner", explanation.AsOf, explanation.Winner, from)
+	}
+
+	for _, asOf := range []string{"yesterday", "2024-01-01", "1704067200"} {
+		mustServe(t, h, http.StatusBadRequest, http.MethodGet, vendor+"?mode=active&as_of="+asOf, "")
+	}
+}
@@ -0,0 +1,342 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+//activeWrite is one config set up before an activeTest's query.  ChangedBy names it, so the active config's
+//meta.changed_by says which write won
+type activeWrite struct {
+	scope     Scope
+	enabled   bool
+	changedBy string
+}
+
+//activeTest sets up writes, asks for the active config at query and expects the write named want to win, or the empty
+//config if want is "".  duplicates are written as second documents for their scopes, as data from before scope_unique
+//might hold them; if the winner's scope has one, the read fails with ErrAmbiguousScope for wantAmbiguous instead
+type activeTest struct {
+	name          string
+	configType    entities.ConfigType
+	writes        []activeWrite
+	duplicates    []activeWrite
+	query         Scope
+	want          string
+	wantAmbiguous *Scope
+}
+
+//Cloud cart is allowed at corporate and venue, other example at corporate and vendor
+var defaultHierarchyActiveTests = []activeTest{
+	{
+		name:       "nothing set resolves to the empty config",
+		configType: entities.CONFIG_TYPE_DEMO_CONFIG,
+		query:      VendorScope("1", "2", "3"),
+	},
+	{
+		name:       "venue config is active for its vendors",
+		configType: entities.CONFIG_TYPE_DEMO_CONFIG,
+		writes:     []activeWrite{{VenueScope("1", "2"), true, "VENUE"}},
+		query:      VendorScope("1", "2", "3"),
+		want:       "VENUE",
+	},
+	{
+		name:       "venue outranks corporate",
+		configType: entities.CONFIG_TYPE_DEMO_CONFIG,
+		writes:     []activeWrite{{CorporateScope("1"), true, "CORPORATE"}, {VenueScope("1", "2"), true, "VENUE"}},
+		query:      VendorScope("1", "2", "3"),
+		want:       "VENUE",
+	},
+	{
+		name:       "disabled venue falls back to corporate",
+		configType: entities.CONFIG_TYPE_DEMO_CONFIG,
+		writes:     []activeWrite{{CorporateScope("1"), true, "CORPORATE"}, {VenueScope("1", "2"), false, "VENUE"}},
+		query:      VendorScope("1", "2", "3"),
+		want:       "CORPORATE",
+	},
+	{
+		name:       "sibling venue is not in the chain",
+		configType: entities.CONFIG_TYPE_DEMO_CONFIG,
+		writes:     []activeWrite{{CorporateScope("1"), true, "CORPORATE"}, {VenueScope("1", "9"), true, "OTHER VENUE"}},
+		query:      VenueScope("1", "2"),
+		want:       "CORPORATE",
+	},
+	{
+		name:       "sibling venue without a corporate config leaves nothing active",
+		configType: entities.CONFIG_TYPE_DEMO_CONFIG,
+		writes:     []activeWrite{{VenueScope("1", "9"), true, "OTHER VENUE"}},
+		query:      VenueScope("1", "2"),
+	},
+	{
+		name:       "venue config is not visible from the corporate level",
+		configType: entities.CONFIG_TYPE_DEMO_CONFIG,
+		writes:     []activeWrite{{VenueScope("1", "2"), true, "VENUE"}},
+		query:      CorporateScope("1"),
+	},
+	{
+		name:       "another corporate's config never applies",
+		configType: entities.CONFIG_TYPE_DEMO_CONFIG,
+		writes:     []activeWrite{{CorporateScope("8"), true, "OTHER CORPORATE"}},
+		query:      VendorScope("1", "2", "3"),
+	},
+	{
+		name:       "vendor outranks corporate",
+		configType: entities.CONFIG_TYPE_OTHER_EXAMPLE,
+		writes:     []activeWrite{{CorporateScope("1"), true, "CORPORATE"}, {VendorScope("1", "2", "3"), true, "VENDOR"}},
+		query:      VendorScope("1", "2", "3"),
+		want:       "VENDOR",
+	},
+	{
+		name:       "vendor of another venue is not in the chain",
+		configType: entities.CONFIG_TYPE_OTHER_EXAMPLE,
+		writes:     []activeWrite{{CorporateScope("1"), true, "CORPORATE"}, {VendorScope("1", "9", "3"), true, "OTHER VENDOR"}},
+		query:      VendorScope("1", "2", "3"),
+		want:       "CORPORATE",
+	},
+	{
+		name:       "vendor config is not visible from the venue level",
+		configType: entities.CONFIG_TYPE_OTHER_EXAMPLE,
+		writes:     []activeWrite{{CorporateScope("1"), true, "CORPORATE"}, {VendorScope("1", "2", "3"), true, "VENDOR"}},
+		query:      VenueScope("1", "2"),
+		want:       "CORPORATE",
+	},
+	{
+		name:       "disabled everywhere leaves nothing active",
+		configType: entities.CONFIG_TYPE_OTHER_EXAMPLE,
+		writes:     []activeWrite{{CorporateScope("1"), false, "CORPORATE"}, {VendorScope("1", "2", "3"), false, "VENDOR"}},
+		query:      VendorScope("1", "2", "3"),
+	},
+	{
+		name:          "duplicate winning venue is ambiguous",
+		configType:    entities.CONFIG_TYPE_DEMO_CONFIG,
+		writes:        []activeWrite{{CorporateScope("1"), true, "CORPORATE"}, {VenueScope("1", "2"), true, "VENUE"}},
+		duplicates:    []activeWrite{{VenueScope("1", "2"), true, "DUPLICATE VENUE"}},
+		query:         VendorScope("1", "2", "3"),
+		wantAmbiguous: scopePtr(VenueScope("1", "2")),
+	},
+	{
+		name:          "duplicate corporate is ambiguous when nothing below it is enabled",
+		configType:    entities.CONFIG_TYPE_DEMO_CONFIG,
+		writes:        []activeWrite{{CorporateScope("1"), true, "CORPORATE"}, {VenueScope("1", "2"), false, "VENUE"}},
+		duplicates:    []activeWrite{{CorporateScope("1"), true, "DUPLICATE CORPORATE"}},
+		query:         VenueScope("1", "2"),
+		wantAmbiguous: scopePtr(CorporateScope("1")),
+	},
+	{
+		name:       "duplicate corporate is outranked by an enabled venue",
+		configType: entities.CONFIG_TYPE_DEMO_CONFIG,
+		writes:     []activeWrite{{CorporateScope("1"), true, "CORPORATE"}, {VenueScope("1", "2"), true, "VENUE"}},
+		duplicates: []activeWrite{{CorporateScope("1"), true, "DUPLICATE CORPORATE"}},
+		query:      VendorScope("1", "2", "3"),
+		want:       "VENUE",
+	},
+	{
+		name:       "disabled duplicate isn't a candidate",
+		configType: entities.CONFIG_TYPE_DEMO_CONFIG,
+		writes:     []activeWrite{{VenueScope("1", "2"), true, "VENUE"}},
+		duplicates: []activeWrite{{VenueScope("1", "2"), false, "DUPLICATE VENUE"}},
+		query:      VenueScope("1", "2"),
+		want:       "VENUE",
+	},
+	{
+		name:       "duplicate of a sibling venue isn't in the chain",
+		configType: entities.CONFIG_TYPE_DEMO_CONFIG,
+		writes:     []activeWrite{{VenueScope("1", "2"), true, "VENUE"}, {VenueScope("1", "9"), true, "OTHER VENUE"}},
+		duplicates: []activeWrite{{VenueScope("1", "9"), true, "DUPLICATE OTHER VENUE"}},
+		query:      VenueScope("1", "2"),
+		want:       "VENUE",
+	},
+}
+
+//deepHierarchy puts a region between corporate and venue and a terminal below vendor.  Cloud cart may also be set per
+//region and other example per terminal
+func deepHierarchy(t *testing.T) *entities.Hierarchy {
+	t.Helper()
+	hierarchy, err := entities.HierarchyFromNames("corporate", "region=cloud_cart", "venue", "vendor", "terminal=other_example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hierarchy
+}
+
+//deepHierarchyActiveTests run against deepHierarchy, where levels are numbered corporate 1, region 2, venue 3, vendor 4
+//and terminal 5
+var deepHierarchyActiveTests = []activeTest{
+	{
+		name:       "region outranks corporate",
+		configType: entities.CONFIG_TYPE_DEMO_CONFIG,
+		writes:     []activeWrite{{NewScope(1, "1"), true, "CORPORATE"}, {NewScope(2, "1", "eu"), true, "REGION"}},
+		query:      NewScope(5, "1", "eu", "2", "3", "4"),
+		want:       "REGION",
+	},
+	{
+		name:       "venue outranks region",
+		configType: entities.CONFIG_TYPE_DEMO_CONFIG,
+		writes:     []activeWrite{{NewScope(2, "1", "eu"), true, "REGION"}, {NewScope(3, "1", "eu", "2"), true, "VENUE"}},
+		query:      NewScope(4, "1", "eu", "2", "3"),
+		want:       "VENUE",
+	},
+	{
+		name:       "sibling region is not in the chain",
+		configType: entities.CONFIG_TYPE_DEMO_CONFIG,
+		writes:     []activeWrite{{NewScope(1, "1"), true, "CORPORATE"}, {NewScope(2, "1", "us"), true, "OTHER REGION"}},
+		query:      NewScope(3, "1", "eu", "2"),
+		want:       "CORPORATE",
+	},
+	{
+		name:       "terminal outranks vendor",
+		configType: entities.CONFIG_TYPE_OTHER_EXAMPLE,
+		writes:     []activeWrite{{NewScope(4, "1", "eu", "2", "3"), true, "VENDOR"}, {NewScope(5, "1", "eu", "2", "3", "4"), true, "TERMINAL"}},
+		query:      NewScope(5, "1", "eu", "2", "3", "4"),
+		want:       "TERMINAL",
+	},
+	{
+		name:       "terminal config is not visible from its vendor",
+		configType: entities.CONFIG_TYPE_OTHER_EXAMPLE,
+		writes:     []activeWrite{{NewScope(5, "1", "eu", "2", "3", "4"), true, "TERMINAL"}},
+		query:      NewScope(4, "1", "eu", "2", "3"),
+	},
+	{
+		name:          "duplicate winning region is ambiguous",
+		configType:    entities.CONFIG_TYPE_DEMO_CONFIG,
+		writes:        []activeWrite{{NewScope(1, "1"), true, "CORPORATE"}, {NewScope(2, "1", "eu"), true, "REGION"}},
+		duplicates:    []activeWrite{{NewScope(2, "1", "eu"), true, "DUPLICATE REGION"}},
+		query:         NewScope(4, "1", "eu", "2", "3"),
+		wantAmbiguous: scopePtr(NewScope(2, "1", "eu")),
+	},
+}
+
+func TestActiveConfig(t *testing.T) {
+	runActiveTests(t, nil, defaultHierarchyActiveTests)
+}
+
+func TestActiveConfigDeepHierarchy(t *testing.T) {
+	runActiveTests(t, []RepoOption{WithHierarchy(deepHierarchy(t))}, deepHierarchyActiveTests)
+}
+
+//TestRegionAndTerminal sets and resolves configs at the levels deepHierarchy adds, which only hold config because
+//HierarchyFromNames lists their types
+func TestRegionAndTerminal(t *testing.T) {
+	ctx := context.Background()
+	configRepo := NewMemoryRepo(WithHierarchy(deepHierarchy(t)))
+	region := NewScope(2, "1", "eu")
+	terminal := NewScope(5, "1", "eu", "2", "3", "4")
+	mustSet(t, configRepo, NewScope(1, "1"), cloudCart("CORPORATE", true))
+	mustSet(t, configRepo, region, cloudCart("REGION", true))
+	mustSet(t, configRepo, terminal, otherExample("TERMINAL", true))
+
+	if got := mustActive(t, configRepo, terminal, entities.CONFIG_TYPE_DEMO_CONFIG); got != "REGION" {
+		t.Errorf("cloud_cart active at the terminal is %q, want REGION", got)
+	}
+	if got := mustActive(t, configRepo, terminal, entities.CONFIG_TYPE_OTHER_EXAMPLE); got != "TERMINAL" {
+		t.Errorf("other_example active at the terminal is %q, want TERMINAL", got)
+	}
+	resolved, err := configRepo.GetResolvedConfig(ctx, terminal, entities.CONFIG_TYPE_DEMO_CONFIG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := changedBy(resolved.Config); got != "REGION" {
+		t.Errorf("cloud_cart resolved at the terminal is from %q, want REGION", got)
+	}
+	if got := resolved.Hierarchy().LevelName(resolved.Sources["enable_validate_prices"]); got != "region" {
+		t.Errorf("enable_validate_prices resolved from %q, want region", got)
+	}
+
+	_, err = configRepo.SetConfig(ctx, terminal, cloudCart("TERMINAL", true))
+	if !errors.As(err, &entities.ErrConfigTypeNotAllowedAtLevel{}) {
+		t.Errorf("setting cloud_cart at the terminal: got %v, want ErrConfigTypeNotAllowedAtLevel", err)
+	}
+}
+
+//runActiveTests plays each test against a MemoryRepo of its own, through GetActiveConfig, the batch and
+//ExplainActiveConfig, which must all agree, and checks GetResolvedConfig is ambiguous where they are
+func runActiveTests(t *testing.T, opts []RepoOption, tests []activeTest) {
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			configRepo := NewMemoryRepo(opts...)
+			for _, write := range test.writes {
+				mustSet(t, configRepo, write.scope, activeConfig(test.configType, write))
+			}
+			for _, duplicate := range test.duplicates {
+				addDuplicateDocument(t, configRepo, duplicate.scope, activeConfig(test.configType, duplicate))
+			}
+
+			active, err := configRepo.GetActiveConfig(ctx, test.query, test.configType)
+			batch, batchErr := configRepo.GetActiveConfigsForScopes(ctx, test.configType, []Scope{test.query})
+			explanation, explainErr := configRepo.ExplainActiveConfig(ctx, test.query, test.configType)
+			if explainErr != nil {
+				t.Fatalf("explain: %v", explainErr)
+			}
+
+			if test.wantAmbiguous != nil {
+				//Resolving merges the whole chain, so a duplicate anywhere in it is ambiguous, winner or not
+				_, resolvedErr := configRepo.GetResolvedConfig(ctx, test.query, test.configType)
+				for name, err := range map[string]error{"GetActiveConfig": err, "GetActiveConfigsForScopes": batchErr, "GetResolvedConfig": resolvedErr} {
+					var ambiguous ErrAmbiguousScope
+					if !errors.As(err, &ambiguous) || ambiguous.Scope != *test.wantAmbiguous || ambiguous.ConfigType != test.configType {
+						t.Errorf("%s: got %v, want ErrAmbiguousScope at %s", name, err, test.wantAmbiguous.String())
+					}
+				}
+				if !explanation.Ambiguous {
+					t.Error("explanation isn't marked ambiguous")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if batchErr != nil {
+				t.Fatalf("batch: %v", batchErr)
+			}
+			if got := changedBy(active); got != test.want {
+				t.Errorf("got config from %q, want %q", got, test.want)
+			}
+			if got := changedBy(batch[test.query]); got != test.want {
+				t.Errorf("batch got config from %q, want %q", got, test.want)
+			}
+			if explanation.Ambiguous {
+				t.Error("explanation is marked ambiguous")
+			}
+			if got := changedBy(explanation.Config); got != test.want {
+				t.Errorf("explanation got config from %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func activeConfig(configType entities.ConfigType, write activeWrite) entities.ValidatedConfig {
+	config := entities.NewConfig(configType)
+	meta := config.(entities.MetaConfig).GetMeta()
+	meta.Enabled = write.enabled
+	meta.ChangedBy = write.changedBy
+	meta.ChangedAt = testEpoch
+
+	return config
+}
+
+//addDuplicateDocument adds a second document for scope holding config, which SetConfig never does
+func addDuplicateDocument(t *testing.T, configRepo *MemoryRepo, scope Scope, config entities.ValidatedConfig) {
+	t.Helper()
+	raw, err := bson.Marshal(withRevision(config, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := newMemoryDocument(scope)
+	doc.configs[config.GetConfigType().String()] = raw
+
+	configRepo.mu.Lock()
+	defer configRepo.mu.Unlock()
+	configRepo.documents = append(configRepo.documents, doc)
+}
+
+func scopePtr(scope Scope) *Scope {
+	return &scope
+}
@@ -0,0 +1,69 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+)
+
+//GetActiveConfigsForScopes fetches every candidate document of the batch in one query and ranks them per scope in
+//memory, with the same match and sort as GetActiveConfig
+func (r *MDBRepo) GetActiveConfigsForScopes(ctx context.Context, configType entities.ConfigType, scopes []Scope, opts ...ActiveOption) (map[Scope]entities.ValidatedConfig, error) {
+	if entities.NewConfig(configType) == nil {
+		return nil, fmt.Errorf("unsupported config type")
+	}
+	if len(scopes) == 0 {
+		return map[Scope]entities.ValidatedConfig{}, nil
+	}
+	activeOpts := newActiveOptions(r.now, opts)
+	filter, err := makeBatchActiveCandidatesFilter(r.hierarchy, scopes, configType, activeOpts.asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	configs, err := r.configCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := configs.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer csr.Close(ctx)
+
+	documents, err := memoryDocumentsFromCursor(ctx, csr, r.hierarchy)
+	if err != nil {
+		return nil, err
+	}
+
+	return activeConfigsForScopes(r.hierarchy, documents, configType, scopes, activeOpts.asOf)
+}
+
+//activeConfigsForScopes picks each scope's active config out of documents, which must hold all of their candidates.
+//Scopes are keyed as given
+func activeConfigsForScopes(h *entities.Hierarchy, documents []*memoryDocument, configType entities.ConfigType, scopes []Scope, asOf time.Time) (map[Scope]entities.ValidatedConfig, error) {
+	configs := make(map[Scope]entities.ValidatedConfig, len(scopes))
+	for _, scope := range scopes {
+		if _, ok := configs[scope]; ok {
+			continue
+		}
+
+		winner, err := activeWinner(selectActiveCandidates(documents, scope, configType, true, asOf), configType)
+		if err != nil {
+			return nil, err
+		}
+		if winner == nil {
+			configs[scope] = emptyConfigForType(h, scope.ConfigLevel, configType)
+			continue
+		}
+		config, err := decodeActive(h, winner, scope, configType)
+		if err != nil {
+			return nil, err
+		}
+		configs[scope] = config
+	}
+
+	return configs, nil
+}
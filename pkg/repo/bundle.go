@@ -0,0 +1,96 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+)
+
+//ActiveBundle is every registered config type's active config for one scope, each resolved through the hierarchy on
+//its own, as GetActiveConfig would.  Sources holds the level each config came from; types with no active config are
+//missing from it and hold their empty default in Configs
+type ActiveBundle struct {
+	Configs entities.ConfigSet                           `json:"configs"`
+	Sources map[entities.ConfigType]entities.ConfigLevel `json:"sources"`
+	AsOf    time.Time                                    `json:"as_of"`
+
+	hierarchy *entities.Hierarchy
+}
+
+//MarshalJSON names the Sources levels through the hierarchy the bundle was read in
+func (b ActiveBundle) MarshalJSON() ([]byte, error) {
+	sources := make(map[entities.ConfigType]string, len(b.Sources))
+	for configType, level := range b.Sources {
+		sources[configType] = b.hierarchy.LevelName(level)
+	}
+
+	return json.Marshal(struct {
+		Configs entities.ConfigSet             `json:"configs"`
+		Sources map[entities.ConfigType]string `json:"sources"`
+		AsOf    time.Time                      `json:"as_of"`
+	}{Configs: b.Configs, Sources: sources, AsOf: b.AsOf})
+}
+
+//Source is the level configType's active config came from, or CONFIG_LEVEL_UNSPECIFIED if it has none
+func (b *ActiveBundle) Source(configType entities.ConfigType) entities.ConfigLevel {
+	return b.Sources[configType]
+}
+
+func (r *MDBRepo) GetActiveBundle(ctx context.Context, scope Scope, opts ...ActiveOption) (*ActiveBundle, error) {
+	if _, err := makeUpsertConfigFilter(r.hierarchy, scope); err != nil {
+		return nil, err
+	}
+	activeOpts := newActiveOptions(r.now, opts)
+
+	pipeline, err := makeActiveBundlePipeline(r.hierarchy, scope)
+	if err != nil {
+		return nil, err
+	}
+	configs, err := r.configCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := configs.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer csr.Close(ctx)
+
+	documents, err := memoryDocumentsFromCursor(ctx, csr, r.hierarchy)
+	if err != nil {
+		return nil, err
+	}
+
+	return activeBundle(r.hierarchy, documents, scope, activeOpts.asOf)
+}
+
+//activeBundle resolves every registered type over documents, which must hold all of the scope's candidates
+func activeBundle(h *entities.Hierarchy, documents []*memoryDocument, scope Scope, asOf time.Time) (*ActiveBundle, error) {
+	bundle := &ActiveBundle{
+		Configs: entities.ConfigSet{},
+		Sources: map[entities.ConfigType]entities.ConfigLevel{},
+		AsOf:    asOf,
+
+		hierarchy: h,
+	}
+	for _, configType := range entities.RegisteredConfigTypes() {
+		winner, err := activeWinner(selectActiveCandidates(documents, scope, configType, true, asOf), configType)
+		if err != nil {
+			return nil, err
+		}
+		if winner == nil {
+			bundle.Configs[configType] = entities.NewConfig(configType)
+			continue
+		}
+		config, err := decodeActive(h, winner, scope, configType)
+		if err != nil {
+			return nil, err
+		}
+		bundle.Configs[configType] = config
+		bundle.Sources[configType] = winner.scope.ConfigLevel
+	}
+
+	return bundle, nil
+}
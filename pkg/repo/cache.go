@@ -0,0 +1,440 @@
+package repo
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+)
+
+var _ ConfigRepository = (*CachedResolver)(nil)
+
+const (
+	defaultCacheTTL        = time.Minute
+	defaultCacheMaxEntries = 10000
+)
+
+//CachedResolver wraps a ConfigRepository and caches active and resolved configs per scope and type.  Writes through
+//the resolver invalidate the written scope and every scope below it; writes made elsewhere (another process, say)
+//should be fed to Invalidate, e.g. from MDBRepo.Watch.  Entries also expire after the TTL, which bounds how stale a
+//missed invalidation can leave a value, or a config whose effective window opened or closed since it was cached.
+//Reads with AsOf are passed straight through.  Returned configs are copies, so callers may modify them.  Around a
+//repository built WithTenantResolver, entries are kept per tenant location, so one tenant is never served another's
+//configs
+type CachedResolver struct {
+	//Counters first, to keep them 64-bit aligned for sync/atomic on 32-bit platforms
+	hits          uint64
+	misses        uint64
+	evictions     uint64
+	invalidations uint64
+
+	repo       ConfigRepository
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[cacheKey]*list.Element
+	lru     *list.List
+	//byScope files every entry under its scope and each of the scope's ancestors, so an invalidation only visits the
+	//entries below the written scope
+	byScope map[scopeIndexKey]map[*list.Element]struct{}
+	//generation is bumped by every invalidation, so a read that raced one doesn't cache what it read
+	generation uint64
+}
+
+type cacheMode int
+
+const (
+	cacheModeActive cacheMode = iota
+	cacheModeResolved
+)
+
+type cacheKey struct {
+	//tenant is the location the wrapped repository routed the read to; see tenantLocator
+	tenant     string
+	scope      Scope
+	configType entities.ConfigType
+	mode       cacheMode
+}
+
+//scopeIndexKey is one of an entry's byScope filings: its scope or an ancestor of it, and its config type
+type scopeIndexKey struct {
+	scope      Scope
+	configType entities.ConfigType
+}
+
+type cacheEntry struct {
+	key       cacheKey
+	active    entities.ValidatedConfig
+	resolved  *entities.ResolvedConfig
+	expiresAt time.Time
+}
+
+//CacheStats are cumulative counts since the resolver was created
+type CacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64
+	Invalidations uint64
+	Entries       int
+}
+
+type CacheOption func(*CachedResolver)
+
+//WithCacheTTL sets how long an entry is served before it is re-read; the default is a minute
+func WithCacheTTL(ttl time.Duration) CacheOption {
+	return func(c *CachedResolver) {
+		c.ttl = ttl
+	}
+}
+
+//WithCacheMaxEntries bounds the cache; the least recently used entry is evicted past it
+func WithCacheMaxEntries(maxEntries int) CacheOption {
+	return func(c *CachedResolver) {
+		c.maxEntries = maxEntries
+	}
+}
+
+func NewCachedResolver(configRepo ConfigRepository, opts ...CacheOption) *CachedResolver {
+	c := &CachedResolver{
+		repo:       configRepo,
+		ttl:        defaultCacheTTL,
+		maxEntries: defaultCacheMaxEntries,
+		now:        time.Now,
+		entries:    map[cacheKey]*list.Element{},
+		lru:        list.New(),
+		byScope:    map[scopeIndexKey]map[*list.Element]struct{}{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func (c *CachedResolver) Hierarchy() *entities.Hierarchy {
+	return c.repo.Hierarchy()
+}
+
+//SetConfig writes through and invalidates scope and its descendants for the written type
+func (c *CachedResolver) SetConfig(ctx context.Context, scope Scope, config entities.ValidatedConfig) (entities.ValidatedConfig, error) {
+	stored, err := c.repo.SetConfig(ctx, scope, config)
+	//Invalidate even on error; the write may have landed before it failed
+	c.invalidateWrite(ctx, scope, config.GetConfigType())
+
+	return stored, err
+}
+
+//SetConfigIfRevision writes through and invalidates like SetConfig
+func (c *CachedResolver) SetConfigIfRevision(ctx context.Context, scope Scope, config entities.ValidatedConfig, expectedRevision int64) (entities.ValidatedConfig, error) {
+	stored, err := c.repo.SetConfigIfRevision(ctx, scope, config, expectedRevision)
+	c.invalidateWrite(ctx, scope, config.GetConfigType())
+
+	return stored, err
+}
+
+//GetSpecificConfig is not cached; it is a single indexed read and is mostly used by admin tooling
+func (c *CachedResolver) GetSpecificConfig(ctx context.Context, scope Scope, configType entities.ConfigType) (entities.ValidatedConfig, error) {
+	return c.repo.GetSpecificConfig(ctx, scope, configType)
+}
+
+func (c *CachedResolver) GetActiveConfig(ctx context.Context, scope Scope, configType entities.ConfigType, opts ...ActiveOption) (entities.ValidatedConfig, error) {
+	tenant, err := c.tenantKey(ctx)
+	if len(opts) > 0 || err != nil {
+		return c.repo.GetActiveConfig(ctx, scope, configType, opts...)
+	}
+	key := cacheKey{tenant: tenant, scope: scope.normalized(), configType: configType, mode: cacheModeActive}
+	entry, generation, ok := c.get(key)
+	if ok {
+		return entities.CloneConfig(entry.active), nil
+	}
+
+	active, err := c.repo.GetActiveConfig(ctx, scope, configType)
+	if err != nil {
+		return nil, err
+	}
+	c.put(&cacheEntry{key: key, active: entities.CloneConfig(active)}, generation)
+
+	return active, nil
+}
+
+//GetActiveConfigsForScopes serves what it can from the cache and fetches the rest in one batch
+func (c *CachedResolver) GetActiveConfigsForScopes(ctx context.Context, configType entities.ConfigType, scopes []Scope, opts ...ActiveOption) (map[Scope]entities.ValidatedConfig, error) {
+	tenant, err := c.tenantKey(ctx)
+	if len(opts) > 0 || err != nil {
+		return c.repo.GetActiveConfigsForScopes(ctx, configType, scopes, opts...)
+	}
+
+	configs := make(map[Scope]entities.ValidatedConfig, len(scopes))
+	var missed []Scope
+	var generation uint64
+	for _, scope := range scopes {
+		key := cacheKey{tenant: tenant, scope: scope.normalized(), configType: configType, mode: cacheModeActive}
+		entry, entryGeneration, ok := c.get(key)
+		if ok {
+			configs[scope] = entities.CloneConfig(entry.active)
+			continue
+		}
+		//The first miss's generation; anything invalidated after it means none of the batch is cached
+		if len(missed) == 0 {
+			generation = entryGeneration
+		}
+		missed = append(missed, scope)
+	}
+	if len(missed) == 0 {
+		return configs, nil
+	}
+
+	fetched, err := c.repo.GetActiveConfigsForScopes(ctx, configType, missed)
+	if err != nil {
+		return nil, err
+	}
+	for scope, active := range fetched {
+		key := cacheKey{tenant: tenant, scope: scope.normalized(), configType: configType, mode: cacheModeActive}
+		c.put(&cacheEntry{key: key, active: entities.CloneConfig(active)}, generation)
+		configs[scope] = active
+	}
+
+	return configs, nil
+}
+
+//GetActiveBundle is not cached; it is one query already, and is meant for start up rather than hot paths
+func (c *CachedResolver) GetActiveBundle(ctx context.Context, scope Scope, opts ...ActiveOption) (*ActiveBundle, error) {
+	return c.repo.GetActiveBundle(ctx, scope, opts...)
+}
+
+func (c *CachedResolver) GetResolvedConfig(ctx context.Context, scope Scope, configType entities.ConfigType, opts ...ActiveOption) (*entities.ResolvedConfig, error) {
+	tenant, err := c.tenantKey(ctx)
+	if len(opts) > 0 || err != nil {
+		return c.repo.GetResolvedConfig(ctx, scope, configType, opts...)
+	}
+	key := cacheKey{tenant: tenant, scope: scope.normalized(), configType: configType, mode: cacheModeResolved}
+	entry, generation, ok := c.get(key)
+	if ok {
+		return entry.resolved.Clone(), nil
+	}
+
+	resolved, err := c.repo.GetResolvedConfig(ctx, scope, configType)
+	if err != nil {
+		return nil, err
+	}
+	c.put(&cacheEntry{key: key, resolved: resolved.Clone()}, generation)
+
+	return resolved, nil
+}
+
+//ExplainActiveConfig is not cached; an explanation should always reflect what is stored
+func (c *CachedResolver) ExplainActiveConfig(ctx context.Context, scope Scope, configType entities.ConfigType, opts ...ActiveOption) (*ActiveConfigExplanation, error) {
+	return c.repo.ExplainActiveConfig(ctx, scope, configType, opts...)
+}
+
+//GetConfigHistory is not cached; history only grows, and is only read by admin tooling
+func (c *CachedResolver) GetConfigHistory(ctx context.Context, scope Scope, configType entities.ConfigType, query HistoryQuery) ([]ConfigHistoryEntry, error) {
+	return c.repo.GetConfigHistory(ctx, scope, configType, query)
+}
+
+//RevertConfig writes through and invalidates like SetConfig
+func (c *CachedResolver) RevertConfig(ctx context.Context, scope Scope, configType entities.ConfigType, toVersion int64, changedBy string) (entities.ValidatedConfig, error) {
+	stored, err := c.repo.RevertConfig(ctx, scope, configType, toVersion, changedBy)
+	c.invalidateWrite(ctx, scope, configType)
+
+	return stored, err
+}
+
+//DeleteConfig writes through and invalidates like SetConfig
+func (c *CachedResolver) DeleteConfig(ctx context.Context, scope Scope, configType entities.ConfigType, changedBy string) error {
+	err := c.repo.DeleteConfig(ctx, scope, configType, changedBy)
+	c.invalidateWrite(ctx, scope, configType)
+
+	return err
+}
+
+//DeleteScope writes through and invalidates every type for the scope and its descendants, cascading or not: without
+//cascade the descendants lose the deleted document from their chain
+func (c *CachedResolver) DeleteScope(ctx context.Context, scope Scope, cascade bool, changedBy string) (int64, error) {
+	deleted, err := c.repo.DeleteScope(ctx, scope, cascade, changedBy)
+	for _, configType := range entities.RegisteredConfigTypes() {
+		c.invalidateWrite(ctx, scope, configType)
+	}
+
+	return deleted, err
+}
+
+func (c *CachedResolver) PlanSetConfig(ctx context.Context, scope Scope, config entities.ValidatedConfig, opts ...ActiveOption) (*SetConfigPlan, error) {
+	return c.repo.PlanSetConfig(ctx, scope, config, opts...)
+}
+
+func (c *CachedResolver) Export(ctx context.Context, topID string) (*ConfigTree, error) {
+	return c.repo.Export(ctx, topID)
+}
+
+//Import writes through the resolver's own SetConfig, so every imported config is invalidated.  Configs are stamped by
+//the wrapped repository's clock
+func (c *CachedResolver) Import(ctx context.Context, tree *ConfigTree, changedBy string) (*ImportResult, error) {
+	return importConfigTree(ctx, c, tree, changedBy, repoClock(c.repo))
+}
+
+//repoClock is configRepo's clock, see WithClock, or time.Now for repositories that don't have one
+func repoClock(configRepo ConfigRepository) func() time.Time {
+	switch r := configRepo.(type) {
+	case *MDBRepo:
+		return r.now
+	case *MemoryRepo:
+		return r.now
+	case *CachedResolver:
+		return repoClock(r.repo)
+	default:
+		return time.Now
+	}
+}
+
+//Invalidate drops every cached configType entry for scope and the scopes below it, active and resolved, along with
+//their CONFIG_TYPE_FULL entries, which hold every type: a corporate write drops the whole corporate, a venue write that
+//venue and its vendors, a vendor write just that vendor.  It has no context to name a tenant by, so it drops the scope
+//for every tenant; writes through the resolver only drop their own tenant's
+func (c *CachedResolver) Invalidate(scope Scope, configType entities.ConfigType) {
+	c.invalidate(scope, configType, func(string) bool { return true })
+}
+
+//invalidateWrite is Invalidate for a write made with ctx, limited to its tenant.  A context the repository couldn't
+//route wrote nothing, so there is nothing to drop
+func (c *CachedResolver) invalidateWrite(ctx context.Context, scope Scope, configType entities.ConfigType) {
+	tenant, err := c.tenantKey(ctx)
+	if err != nil {
+		return
+	}
+	c.invalidate(scope, configType, func(entryTenant string) bool { return entryTenant == tenant })
+}
+
+func (c *CachedResolver) invalidate(scope Scope, configType entities.ConfigType, tenant func(string) bool) {
+	scope = scope.normalized()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for _, indexKey := range []scopeIndexKey{{scope: scope, configType: configType}, {scope: scope, configType: entities.CONFIG_TYPE_FULL}} {
+		for elem := range c.byScope[indexKey] {
+			if tenant(elem.Value.(*cacheEntry).key.tenant) {
+				c.remove(elem)
+				atomic.AddUint64(&c.invalidations, 1)
+			}
+		}
+	}
+}
+
+//tenantKey is where the wrapped repository routes ctx, "" if it isn't multi-tenant
+func (c *CachedResolver) tenantKey(ctx context.Context) (string, error) {
+	if locator, ok := c.repo.(tenantLocator); ok {
+		return locator.tenantKey(ctx)
+	}
+
+	return "", nil
+}
+
+//Purge drops everything
+func (c *CachedResolver) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.entries = map[cacheKey]*list.Element{}
+	c.lru.Init()
+	c.byScope = map[scopeIndexKey]map[*list.Element]struct{}{}
+}
+
+func (c *CachedResolver) Stats() CacheStats {
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+
+	return CacheStats{
+		Hits:          atomic.LoadUint64(&c.hits),
+		Misses:        atomic.LoadUint64(&c.misses),
+		Evictions:     atomic.LoadUint64(&c.evictions),
+		Invalidations: atomic.LoadUint64(&c.invalidations),
+		Entries:       entries,
+	}
+}
+
+//get returns the live entry for key, if any, and the generation to hand back to put on a miss
+func (c *CachedResolver) get(key cacheKey) (*cacheEntry, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return nil, c.generation, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.remove(elem)
+		atomic.AddUint64(&c.misses, 1)
+		return nil, c.generation, false
+	}
+
+	c.lru.MoveToFront(elem)
+	atomic.AddUint64(&c.hits, 1)
+	return entry, c.generation, true
+}
+
+func (c *CachedResolver) put(entry *cacheEntry, generation uint64) {
+	if c.maxEntries <= 0 {
+		return
+	}
+	entry.expiresAt = c.now().Add(c.ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	if elem, ok := c.entries[entry.key]; ok {
+		c.remove(elem)
+	}
+	elem := c.lru.PushFront(entry)
+	c.entries[entry.key] = elem
+	for _, indexKey := range entry.scopeIndexKeys() {
+		filed, ok := c.byScope[indexKey]
+		if !ok {
+			filed = map[*list.Element]struct{}{}
+			c.byScope[indexKey] = filed
+		}
+		filed[elem] = struct{}{}
+	}
+
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+		atomic.AddUint64(&c.evictions, 1)
+	}
+}
+
+//remove must be called with mu held
+func (c *CachedResolver) remove(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.key)
+	for _, indexKey := range entry.scopeIndexKeys() {
+		delete(c.byScope[indexKey], elem)
+		if len(c.byScope[indexKey]) == 0 {
+			delete(c.byScope, indexKey)
+		}
+	}
+}
+
+//scopeIndexKeys are the entry's byScope filings, under its scope and each ancestor, most general first
+func (e *cacheEntry) scopeIndexKeys() []scopeIndexKey {
+	keys := make([]scopeIndexKey, 0, e.key.scope.ConfigLevel)
+	for level := entities.ConfigLevel(1); level <= e.key.scope.ConfigLevel; level++ {
+		keys = append(keys, scopeIndexKey{scope: e.key.scope.At(level), configType: e.key.configType})
+	}
+
+	return keys
+}
@@ -0,0 +1,296 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+)
+
+//newTestCache wraps a MemoryRepo holding a corporate cloud_cart in a CachedResolver on the fake clock
+func newTestCache(t *testing.T, opts ...CacheOption) (*CachedResolver, *testClock) {
+	clock := newTestClock()
+	configRepo := NewMemoryRepo(WithClock(clock.Now))
+	mustSet(t, configRepo, CorporateScope("1"), cloudCart("CORPORATE", true))
+	cache := NewCachedResolver(configRepo, opts...)
+	cache.now = clock.Now
+
+	return cache, clock
+}
+
+//checkStats compares the hits and misses since before with want
+func checkStats(t *testing.T, cache *CachedResolver, before CacheStats, wantHits, wantMisses uint64) {
+	t.Helper()
+	after := cache.Stats()
+	if hits, misses := after.Hits-before.Hits, after.Misses-before.Misses; hits != wantHits || misses != wantMisses {
+		t.Errorf("got %d hits and %d misses, want %d and %d", hits, misses, wantHits, wantMisses)
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	cache, _ := newTestCache(t)
+	vendor := VendorScope("1", "2", "3")
+
+	before := cache.Stats()
+	for i := 0; i < 3; i++ {
+		if got := mustActive(t, cache, vendor, entities.CONFIG_TYPE_DEMO_CONFIG); got != "CORPORATE" {
+			t.Fatalf("read %d is from %q, want CORPORATE", i, got)
+		}
+	}
+	checkStats(t, cache, before, 2, 1)
+
+	//Active and resolved reads are cached apart
+	before = cache.Stats()
+	for i := 0; i < 2; i++ {
+		if _, err := cache.GetResolvedConfig(context.Background(), vendor, entities.CONFIG_TYPE_DEMO_CONFIG); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkStats(t, cache, before, 1, 1)
+	if entries := cache.Stats().Entries; entries != 2 {
+		t.Errorf("cache holds %d entries, want 2", entries)
+	}
+
+	//Reads as of a time are passed through
+	before = cache.Stats()
+	if _, err := cache.GetActiveConfig(context.Background(), vendor, entities.CONFIG_TYPE_DEMO_CONFIG, AsOf(testEpoch)); err != nil {
+		t.Fatal(err)
+	}
+	checkStats(t, cache, before, 0, 0)
+}
+
+func TestCacheReturnsCopies(t *testing.T) {
+	cache, _ := newTestCache(t)
+	vendor := VendorScope("1", "2", "3")
+
+	for i := 0; i < 2; i++ {
+		active, err := cache.GetActiveConfig(context.Background(), vendor, entities.CONFIG_TYPE_DEMO_CONFIG)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := changedBy(active); got != "CORPORATE" {
+			t.Fatalf("read %d is from %q after the last was modified, want CORPORATE", i, got)
+		}
+		active.(*entities.CloudCartConfig).ChangedBy = "CALLER"
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	cache, clock := newTestCache(t, WithCacheTTL(time.Minute))
+	vendor := VendorScope("1", "2", "3")
+	mustActive(t, cache, vendor, entities.CONFIG_TYPE_DEMO_CONFIG)
+
+	//A write the resolver doesn't see is served stale until the entry expires
+	if _, err := cache.repo.SetConfig(context.Background(), CorporateScope("1"), cloudCart("ELSEWHERE", true)); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute - time.Millisecond)
+	before := cache.Stats()
+	if got := mustActive(t, cache, vendor, entities.CONFIG_TYPE_DEMO_CONFIG); got != "CORPORATE" {
+		t.Errorf("read before the TTL is from %q, want the cached CORPORATE", got)
+	}
+	checkStats(t, cache, before, 1, 0)
+
+	clock.Advance(time.Millisecond)
+	before = cache.Stats()
+	if got := mustActive(t, cache, vendor, entities.CONFIG_TYPE_DEMO_CONFIG); got != "ELSEWHERE" {
+		t.Errorf("read at the TTL is from %q, want ELSEWHERE", got)
+	}
+	checkStats(t, cache, before, 0, 1)
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache, _ := newTestCache(t, WithCacheMaxEntries(2))
+	a, b, c := VendorScope("1", "2", "a"), VendorScope("1", "2", "b"), VendorScope("1", "2", "c")
+	for _, scope := range []Scope{a, b, a, c} {
+		mustActive(t, cache, scope, entities.CONFIG_TYPE_DEMO_CONFIG)
+	}
+	if stats := cache.Stats(); stats.Entries != 2 || stats.Evictions != 1 {
+		t.Fatalf("cache holds %d entries after %d evictions, want 2 after 1", stats.Entries, stats.Evictions)
+	}
+
+	//a was read after b, so b went
+	before := cache.Stats()
+	mustActive(t, cache, a, entities.CONFIG_TYPE_DEMO_CONFIG)
+	mustActive(t, cache, c, entities.CONFIG_TYPE_DEMO_CONFIG)
+	checkStats(t, cache, before, 2, 0)
+	before = cache.Stats()
+	mustActive(t, cache, b, entities.CONFIG_TYPE_DEMO_CONFIG)
+	checkStats(t, cache, before, 0, 1)
+}
+
+func TestCacheDisabled(t *testing.T) {
+	cache, _ := newTestCache(t, WithCacheMaxEntries(0))
+	for i := 0; i < 2; i++ {
+		mustActive(t, cache, CorporateScope("1"), entities.CONFIG_TYPE_DEMO_CONFIG)
+	}
+	if entries := cache.Stats().Entries; entries != 0 {
+		t.Errorf("a cache of no entries holds %d", entries)
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	corporate := CorporateScope("1")
+	venue, otherVenue := VenueScope("1", "2"), VenueScope("1", "3")
+	vendor, otherVendor := VendorScope("1", "2", "3"), VendorScope("1", "3", "4")
+	otherCorporate := VendorScope("9", "2", "3")
+	type cached struct {
+		scope      Scope
+		configType entities.ConfigType
+	}
+	everything := []cached{
+		{corporate, entities.CONFIG_TYPE_DEMO_CONFIG},
+		{venue, entities.CONFIG_TYPE_DEMO_CONFIG},
+		{otherVenue, entities.CONFIG_TYPE_DEMO_CONFIG},
+		{vendor, entities.CONFIG_TYPE_DEMO_CONFIG},
+		{otherVendor, entities.CONFIG_TYPE_DEMO_CONFIG},
+		{otherCorporate, entities.CONFIG_TYPE_DEMO_CONFIG},
+		{vendor, entities.CONFIG_TYPE_OTHER_EXAMPLE},
+		{vendor, entities.CONFIG_TYPE_FULL},
+	}
+
+	for _, test := range []struct {
+		name        string
+		write       func(cache *CachedResolver) error
+		invalidated []cached
+	}{
+		{
+			name: "a corporate write drops the corporate's venues and vendors and their full configs",
+			write: func(cache *CachedResolver) error {
+				_, err := cache.SetConfig(context.Background(), corporate, cloudCart("CORPORATE", false))
+				return err
+			},
+			invalidated: append(everything[:5:5], everything[7]),
+		},
+		{
+			name: "a venue write drops the venue and its vendors",
+			write: func(cache *CachedResolver) error {
+				_, err := cache.SetConfig(context.Background(), venue, cloudCart("VENUE", true))
+				return err
+			},
+			invalidated: []cached{everything[1], everything[3], everything[7]},
+		},
+		{
+			name: "a vendor write drops the vendor's entries for the type and the full config",
+			write: func(cache *CachedResolver) error {
+				_, err := cache.SetConfig(context.Background(), vendor, otherExample("VENDOR", true))
+				return err
+			},
+			invalidated: everything[6:],
+		},
+		{
+			name: "a deleted config is dropped like a write",
+			write: func(cache *CachedResolver) error {
+				return cache.DeleteConfig(context.Background(), corporate, entities.CONFIG_TYPE_DEMO_CONFIG, "alice")
+			},
+			invalidated: append(everything[:5:5], everything[7]),
+		},
+		{
+			name: "Invalidate drops like a write",
+			write: func(cache *CachedResolver) error {
+				cache.Invalidate(venue, entities.CONFIG_TYPE_DEMO_CONFIG)
+				return nil
+			},
+			invalidated: []cached{everything[1], everything[3], everything[7]},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cache, _ := newTestCache(t)
+			read := func(c cached) {
+				t.Helper()
+				if _, err := cache.GetActiveConfig(context.Background(), c.scope, c.configType); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := cache.GetResolvedConfig(context.Background(), c.scope, c.configType); err != nil && c.configType != entities.CONFIG_TYPE_FULL {
+					t.Fatal(err)
+				}
+			}
+			for _, c := range everything {
+				read(c)
+			}
+			if err := test.write(cache); err != nil {
+				t.Fatal(err)
+			}
+
+			dropped := map[cached]bool{}
+			for _, c := range test.invalidated {
+				dropped[c] = true
+			}
+			for _, c := range everything {
+				before := cache.Stats()
+				if _, err := cache.GetActiveConfig(context.Background(), c.scope, c.configType); err != nil {
+					t.Fatal(err)
+				}
+				after := cache.Stats()
+				if missed := after.Misses > before.Misses; missed != dropped[c] {
+					t.Errorf("%s at %s: missed %t, want %t", c.configType, c.scope.String(), missed, dropped[c])
+				}
+			}
+		})
+	}
+}
+
+//racingRepo runs during between reading an active config and returning it, as if a write landed while the read was in
+//flight
+type racingRepo struct {
+	ConfigRepository
+	during func()
+}
+
+func (r racingRepo) GetActiveConfig(ctx context.Context, scope Scope, configType entities.ConfigType, opts ...ActiveOption) (entities.ValidatedConfig, error) {
+	active, err := r.ConfigRepository.GetActiveConfig(ctx, scope, configType, opts...)
+	if r.during != nil {
+		r.during()
+	}
+
+	return active, err
+}
+
+func TestCacheDoesNotCacheReadsThatRacedAnInvalidation(t *testing.T) {
+	configRepo := NewMemoryRepo()
+	mustSet(t, configRepo, CorporateScope("1"), cloudCart("CORPORATE", true))
+	racing := &racingRepo{ConfigRepository: configRepo}
+	cache := NewCachedResolver(racing)
+	vendor := VendorScope("1", "2", "3")
+
+	racing.during = func() {
+		racing.during = nil
+		if _, err := cache.SetConfig(context.Background(), CorporateScope("1"), cloudCart("NEWER", true)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := mustActive(t, cache, vendor, entities.CONFIG_TYPE_DEMO_CONFIG); got != "CORPORATE" {
+		t.Fatalf("the racing read is from %q, want CORPORATE", got)
+	}
+	if entries := cache.Stats().Entries; entries != 0 {
+		t.Errorf("cache holds %d entries after a read that raced a write, want none", entries)
+	}
+	if got := mustActive(t, cache, vendor, entities.CONFIG_TYPE_DEMO_CONFIG); got != "NEWER" {
+		t.Errorf("the next read is from %q, want NEWER", got)
+	}
+}
+
+func TestCacheBatch(t *testing.T) {
+	cache, _ := newTestCache(t)
+	scopes := []Scope{VendorScope("1", "2", "3"), VendorScope("1", "2", "4"), VendorScope("1", "5", "6")}
+	mustActive(t, cache, scopes[0], entities.CONFIG_TYPE_DEMO_CONFIG)
+
+	before := cache.Stats()
+	configs, err := cache.GetActiveConfigsForScopes(context.Background(), entities.CONFIG_TYPE_DEMO_CONFIG, scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStats(t, cache, before, 1, 2)
+	for _, scope := range scopes {
+		if got := changedBy(configs[scope]); got != "CORPORATE" {
+			t.Errorf("%s is from %q, want CORPORATE", scope.String(), got)
+		}
+	}
+
+	before = cache.Stats()
+	if _, err := cache.GetActiveConfigsForScopes(context.Background(), entities.CONFIG_TYPE_DEMO_CONFIG, scopes); err != nil {
+		t.Fatal(err)
+	}
+	checkStats(t, cache, before, 3, 0)
+}
@@ -0,0 +1,600 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+//conformanceMongoURIEnv names a MongoDB to run the conformance suite and the other MDBRepo tests on, e.g.
+//mongodb://localhost:27017/?replicaSet=testRepl; Watch needs the replica set.  Every test gets its own database,
+//dropped when it ends
+const conformanceMongoURIEnv = "CONFIG_DEMO_MONGO_URI"
+
+//testEpoch is where every testClock starts.  It is whole milliseconds, like every time bson stores
+var testEpoch = time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+//testClock is a WithClock clock that only moves when told to
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: testEpoch}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+//newTestRepo builds an empty repository with opts for one test
+type newTestRepo func(t *testing.T, opts ...RepoOption) ConfigRepository
+
+func TestMemoryRepoConformance(t *testing.T) {
+	testConformance(t, func(t *testing.T, opts ...RepoOption) ConfigRepository {
+		return NewMemoryRepo(opts...)
+	})
+}
+
+func TestMDBRepoConformance(t *testing.T) {
+	client := newTestMongoClient(t)
+	testConformance(t, func(t *testing.T, opts ...RepoOption) ConfigRepository {
+		return newTestMDBRepo(t, client, opts...)
+	})
+}
+
+//newTestMongoClient connects to the MongoDB conformanceMongoURIEnv names, skipping the test if it names none
+func newTestMongoClient(t *testing.T) *mongo.Client {
+	t.Helper()
+	uri := os.Getenv(conformanceMongoURIEnv)
+	if uri == "" {
+		t.Skipf("%s is not set", conformanceMongoURIEnv)
+	}
+	client, err := mongo.NewClient(options.Client().ApplyURI(uri).SetConnectTimeout(5 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Disconnect(context.Background())
+	})
+	if err := client.Ping(context.Background(), readpref.PrimaryPreferred()); err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+var testDatabases uint64
+
+//newTestMDBRepo is an MDBRepo on a database of its own, with its indexes, dropped when the test ends
+func newTestMDBRepo(t *testing.T, client *mongo.Client, opts ...RepoOption) *MDBRepo {
+	t.Helper()
+	database := fmt.Sprintf("config-demo-test-%d-%d", time.Now().UnixNano(), atomic.AddUint64(&testDatabases, 1))
+	configRepo := NewMDBRepo(client, append(opts, WithDatabase(database))...)
+	t.Cleanup(func() {
+		client.Database(database).Drop(context.Background())
+	})
+	if _, err := configRepo.EnsureIndexes(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return &configRepo
+}
+
+//testConformance checks the behaviour ConfigRepository documents, which every implementation has to share
+func testConformance(t *testing.T, newRepo newTestRepo) {
+	for _, test := range []struct {
+		name string
+		run  func(t *testing.T, newRepo newTestRepo)
+	}{
+		{"set and get", testSetAndGet},
+		{"set refuses invalid configs", testSetRefusesInvalid},
+		{"set if revision", testSetIfRevision},
+		{"type missing from a document", testTypeMissingFromDocument},
+		{"active precedence", testActivePrecedence},
+		{"effective windows", testEffectiveWindows},
+		{"resolved", testResolved},
+		{"batch and bundle", testBatchAndBundle},
+		{"explain", testExplain},
+		{"plan follows the clock", testPlanFollowsClock},
+		{"history and revert", testHistoryAndRevert},
+		{"delete config", testDeleteConfig},
+		{"delete scope", testDeleteScope},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, newRepo)
+		})
+	}
+}
+
+func testSetAndGet(t *testing.T, newRepo newTestRepo) {
+	ctx := context.Background()
+	configRepo := newRepo(t)
+	venue := VenueScope("1", "2")
+
+	empty, err := configRepo.GetSpecificConfig(ctx, venue, entities.CONFIG_TYPE_DEMO_CONFIG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changedBy(empty) != "" || revisionOfConfig(empty) != 0 {
+		t.Fatalf("unset config read as %+v, want the empty config", empty)
+	}
+
+	written := cloudCart("alice", true)
+	written.EnableCalculateReductionsAndTaxes = true
+	for revision := int64(1); revision <= 2; revision++ {
+		stored := mustSet(t, configRepo, venue, written)
+		if got := revisionOfConfig(stored); got != revision {
+			t.Fatalf("write %d stored revision %d", revision, got)
+		}
+	}
+
+	read, err := configRepo.GetSpecificConfig(ctx, venue, entities.CONFIG_TYPE_DEMO_CONFIG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := withRevision(written, 2)
+	if !reflect.DeepEqual(read, want) {
+		t.Fatalf("read %+v, want %+v", read, want)
+	}
+}
+
+func testSetRefusesInvalid(t *testing.T, newRepo newTestRepo) {
+	ctx := context.Background()
+	configRepo := newRepo(t)
+	venue := VenueScope("1", "2")
+
+	_, err := configRepo.SetConfig(ctx, venue, otherExample("alice", true))
+	if !errors.As(err, &entities.ErrConfigTypeNotAllowedAtLevel{}) {
+		t.Fatalf("other_example at a venue: got %v, want ErrConfigTypeNotAllowedAtLevel", err)
+	}
+
+	invalid := cloudCart("alice", true)
+	invalid.EnableValidateCartSums = true
+	var validationErr *entities.ValidationError
+	if _, err := configRepo.SetConfig(ctx, venue, invalid); !errors.As(err, &validationErr) {
+		t.Fatalf("invalid cloud_cart: got %v, want a ValidationError", err)
+	}
+
+	history, err := configRepo.GetConfigHistory(ctx, venue, entities.CONFIG_TYPE_DEMO_CONFIG, HistoryQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 0 {
+		t.Fatalf("refused writes left %d history entries", len(history))
+	}
+}
+
+func testSetIfRevision(t *testing.T, newRepo newTestRepo) {
+	ctx := context.Background()
+	configRepo := newRepo(t)
+	corporate := CorporateScope("1")
+
+	if _, err := configRepo.SetConfigIfRevision(ctx, corporate, cloudCart("alice", true), 0); err != nil {
+		t.Fatalf("creating at revision 0: %v", err)
+	}
+	_, err := configRepo.SetConfigIfRevision(ctx, corporate, cloudCart("bob", true), 0)
+	var conflict ErrRevisionConflict
+	if !errors.As(err, &conflict) || conflict.Expected != 0 || conflict.Actual != 1 {
+		t.Fatalf("creating again: got %v, want a conflict at revision 1", err)
+	}
+	stored, err := configRepo.SetConfigIfRevision(ctx, corporate, cloudCart("bob", true), 1)
+	if err != nil {
+		t.Fatalf("writing at revision 1: %v", err)
+	}
+	if changedBy(stored) != "bob" || revisionOfConfig(stored) != 2 {
+		t.Fatalf("stored %+v, want bob's write at revision 2", stored)
+	}
+}
+
+//testTypeMissingFromDocument reads and writes a type on a scope whose document holds only another type, first because
+//it was never set there and then because it was deleted; both read as the empty config
+func testTypeMissingFromDocument(t *testing.T, newRepo newTestRepo) {
+	ctx := context.Background()
+	configRepo := newRepo(t)
+	corporate := CorporateScope("1")
+	mustSet(t, configRepo, corporate, otherExample("alice", true))
+
+	checkEmpty := func(when string) {
+		t.Helper()
+		specific, err := configRepo.GetSpecificConfig(ctx, corporate, entities.CONFIG_TYPE_DEMO_CONFIG)
+		if err != nil {
+			t.Fatalf("%s: %v", when, err)
+		}
+		if changedBy(specific) != "" || revisionOfConfig(specific) != 0 {
+			t.Errorf("%s: read %+v, want the empty config", when, specific)
+		}
+	}
+
+	checkEmpty("before it was set")
+	_, err := configRepo.SetConfigIfRevision(ctx, corporate, cloudCart("bob", true), 4)
+	var conflict ErrRevisionConflict
+	if !errors.As(err, &conflict) || conflict.Actual != 0 {
+		t.Errorf("writing at revision 4 before it was set: got %v, want a conflict at revision 0", err)
+	}
+	if _, err := configRepo.SetConfigIfRevision(ctx, corporate, cloudCart("bob", true), 0); err != nil {
+		t.Fatalf("writing at revision 0 beside another type: %v", err)
+	}
+
+	if err := configRepo.DeleteConfig(ctx, corporate, entities.CONFIG_TYPE_DEMO_CONFIG, "carol"); err != nil {
+		t.Fatal(err)
+	}
+	checkEmpty("after it was deleted")
+	stored, err := configRepo.SetConfigIfRevision(ctx, corporate, cloudCart("dave", true), 0)
+	if err != nil {
+		t.Fatalf("writing at revision 0 after it was deleted: %v", err)
+	}
+	if got := revisionOfConfig(stored); got != 3 {
+		t.Errorf("write after the deletion stored revision %d, want 3", got)
+	}
+	if got := changedBy(mustSpecific(t, configRepo, corporate, entities.CONFIG_TYPE_OTHER_EXAMPLE)); got != "alice" {
+		t.Errorf("other_example after cloud_cart was deleted is %q, want alice", got)
+	}
+}
+
+func testActivePrecedence(t *testing.T, newRepo newTestRepo) {
+	configRepo := newRepo(t)
+	mustSet(t, configRepo, CorporateScope("1"), cloudCart("CORPORATE", true))
+	mustSet(t, configRepo, VenueScope("1", "2"), cloudCart("VENUE", true))
+	mustSet(t, configRepo, VenueScope("1", "3"), cloudCart("DISABLED VENUE", false))
+
+	for _, scope := range []struct {
+		scope Scope
+		want  string
+	}{
+		{CorporateScope("1"), "CORPORATE"},
+		{VendorScope("1", "2", "9"), "VENUE"},
+		{VendorScope("1", "3", "9"), "CORPORATE"},
+		{VendorScope("1", "4", "9"), "CORPORATE"},
+		{VendorScope("2", "2", "9"), ""},
+	} {
+		if got := mustActive(t, configRepo, scope.scope, entities.CONFIG_TYPE_DEMO_CONFIG); got != scope.want {
+			t.Errorf("active at %s is %q, want %q", scope.scope.String(), got, scope.want)
+		}
+	}
+}
+
+func testEffectiveWindows(t *testing.T, newRepo newTestRepo) {
+	clock := newTestClock()
+	configRepo := newRepo(t, WithClock(clock.Now))
+	vendor := VendorScope("1", "2", "3")
+
+	mustSet(t, configRepo, CorporateScope("1"), cloudCart("ALWAYS", true))
+	from := clock.Now().Add(time.Hour)
+	until := from.Add(time.Hour)
+	windowed := cloudCart("WINDOWED", true)
+	windowed.EffectiveFrom = &from
+	windowed.EffectiveUntil = &until
+	mustSet(t, configRepo, VenueScope("1", "2"), windowed)
+
+	for _, step := range []struct {
+		advance time.Duration
+		want    string
+	}{
+		{0, "ALWAYS"},
+		{time.Hour, "WINDOWED"},
+		{59 * time.Minute, "WINDOWED"},
+		{time.Minute, "ALWAYS"},
+	} {
+		clock.Advance(step.advance)
+		if got := mustActive(t, configRepo, vendor, entities.CONFIG_TYPE_DEMO_CONFIG); got != step.want {
+			t.Errorf("active at %s is %q, want %q", clock.Now().Format(time.RFC3339), got, step.want)
+		}
+	}
+
+	//AsOf overrides the clock
+	active, err := configRepo.GetActiveConfig(context.Background(), vendor, entities.CONFIG_TYPE_DEMO_CONFIG, AsOf(from))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changedBy(active) != "WINDOWED" {
+		t.Errorf("active as of %s is %q, want WINDOWED", from.Format(time.RFC3339), changedBy(active))
+	}
+}
+
+func testResolved(t *testing.T, newRepo newTestRepo) {
+	configRepo := newRepo(t)
+	corporate := cloudCart("CORPORATE", true)
+	corporate.EnableCalculateReductionsAndTaxes = true
+	corporate.EnableValidatePrices = true
+	mustSet(t, configRepo, CorporateScope("1"), corporate)
+	venue := cloudCart("VENUE", true)
+	venue.Overrides = []string{"enable_validate_prices"}
+	mustSet(t, configRepo, VenueScope("1", "2"), venue)
+
+	resolved, err := configRepo.GetResolvedConfig(context.Background(), VendorScope("1", "2", "3"), entities.CONFIG_TYPE_DEMO_CONFIG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := resolved.Config.(*entities.CloudCartConfig)
+	if !config.EnableCalculateReductionsAndTaxes || config.EnableValidatePrices {
+		t.Errorf("resolved %+v, want reductions from the corporate and prices off from the venue", config)
+	}
+	if got := resolved.Sources["enable_validate_prices"]; got != entities.CONFIG_LEVEL_VENUE {
+		t.Errorf("enable_validate_prices came from %s, want venue", got.String())
+	}
+	if got := resolved.Sources["enable_calculate_reductions_and_taxes"]; got != entities.CONFIG_LEVEL_CORPORATE {
+		t.Errorf("enable_calculate_reductions_and_taxes came from %s, want corporate", got.String())
+	}
+}
+
+func testBatchAndBundle(t *testing.T, newRepo newTestRepo) {
+	ctx := context.Background()
+	configRepo := newRepo(t)
+	mustSet(t, configRepo, CorporateScope("1"), cloudCart("CORPORATE", true))
+	mustSet(t, configRepo, VenueScope("1", "2"), cloudCart("VENUE", true))
+	mustSet(t, configRepo, VendorScope("1", "2", "3"), otherExample("VENDOR", true))
+
+	scopes := []Scope{CorporateScope("1"), VenueScope("1", "2"), VendorScope("1", "2", "3"), VendorScope("1", "4", "5")}
+	batch, err := configRepo.GetActiveConfigsForScopes(ctx, entities.CONFIG_TYPE_DEMO_CONFIG, scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, scope := range scopes {
+		if got, want := changedBy(batch[scope]), mustActive(t, configRepo, scope, entities.CONFIG_TYPE_DEMO_CONFIG); got != want {
+			t.Errorf("batch has %q at %s, GetActiveConfig %q", got, scope.String(), want)
+		}
+	}
+
+	bundle, err := configRepo.GetActiveBundle(ctx, VendorScope("1", "2", "3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := changedBy(bundle.Configs.CloudCart()); got != "VENUE" {
+		t.Errorf("bundle's cloud_cart is %q, want VENUE", got)
+	}
+	if got := changedBy(bundle.Configs.OtherExample()); got != "VENDOR" {
+		t.Errorf("bundle's other_example is %q, want VENDOR", got)
+	}
+	if got := bundle.Sources[entities.CONFIG_TYPE_DEMO_CONFIG]; got != entities.CONFIG_LEVEL_VENUE {
+		t.Errorf("bundle's cloud_cart came from %s, want venue", got.String())
+	}
+}
+
+func testExplain(t *testing.T, newRepo newTestRepo) {
+	configRepo := newRepo(t)
+	mustSet(t, configRepo, CorporateScope("1"), cloudCart("CORPORATE", true))
+	mustSet(t, configRepo, VenueScope("1", "2"), cloudCart("VENUE", false))
+
+	explanation, err := configRepo.ExplainActiveConfig(context.Background(), VendorScope("1", "2", "3"), entities.CONFIG_TYPE_DEMO_CONFIG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(explanation.Candidates) != 2 {
+		t.Fatalf("explained %d candidates, want 2", len(explanation.Candidates))
+	}
+	if explanation.Winner < 0 || explanation.Candidates[explanation.Winner].ConfigLevel != entities.CONFIG_LEVEL_CORPORATE {
+		t.Errorf("winner is candidate %d, want the corporate", explanation.Winner)
+	}
+	if got := changedBy(explanation.Config); got != "CORPORATE" {
+		t.Errorf("explained config is %q, want CORPORATE", got)
+	}
+}
+
+func testPlanFollowsClock(t *testing.T, newRepo newTestRepo) {
+	clock := newTestClock()
+	configRepo := newRepo(t, WithClock(clock.Now))
+	mustSet(t, configRepo, CorporateScope("1"), cloudCart("CORPORATE", true))
+	from := clock.Now().Add(time.Hour)
+	planned := cloudCart("VENUE", true)
+	planned.EffectiveFrom = &from
+
+	for _, step := range []struct {
+		advance time.Duration
+		changes int
+	}{
+		{0, 0},
+		{time.Hour, 1},
+	} {
+		clock.Advance(step.advance)
+		plan, err := configRepo.PlanSetConfig(context.Background(), VenueScope("1", "2"), planned)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !plan.AsOf.Equal(clock.Now()) {
+			t.Errorf("planned as of %s, want the clock's %s", plan.AsOf.Format(time.RFC3339), clock.Now().Format(time.RFC3339))
+		}
+		if len(plan.Changes) != step.changes {
+			t.Errorf("planned at %s changes %d scopes, want %d", clock.Now().Format(time.RFC3339), len(plan.Changes), step.changes)
+		}
+	}
+}
+
+func testHistoryAndRevert(t *testing.T, newRepo newTestRepo) {
+	ctx := context.Background()
+	clock := newTestClock()
+	configRepo := newRepo(t, WithClock(clock.Now))
+	venue := VenueScope("1", "2")
+
+	for _, author := range []string{"alice", "bob"} {
+		config := cloudCart(author, true)
+		config.ChangedAt = clock.Now()
+		mustSet(t, configRepo, venue, config)
+		clock.Advance(time.Minute)
+	}
+	if err := configRepo.DeleteConfig(ctx, venue, entities.CONFIG_TYPE_DEMO_CONFIG, "carol"); err != nil {
+		t.Fatal(err)
+	}
+	deletedAt := clock.Now()
+	clock.Advance(time.Minute)
+	reverted, err := configRepo.RevertConfig(ctx, venue, entities.CONFIG_TYPE_DEMO_CONFIG, 1, "dave")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changedBy(reverted) != "dave" || revisionOfConfig(reverted) != 4 || !reverted.(entities.MetaConfig).GetMeta().ChangedAt.Equal(clock.Now()) {
+		t.Errorf("reverted to %+v, want dave's write at revision 4, now", reverted)
+	}
+
+	history, err := configRepo.GetConfigHistory(ctx, venue, entities.CONFIG_TYPE_DEMO_CONFIG, HistoryQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []int64
+	var authors []string
+	for _, entry := range history {
+		versions = append(versions, entry.Version)
+		authors = append(authors, entry.ChangedBy)
+	}
+	if want := []int64{4, 3, 2, 1}; !reflect.DeepEqual(versions, want) {
+		t.Fatalf("history versions %v, want %v", versions, want)
+	}
+	if want := []string{"dave", "carol", "bob", "alice"}; !reflect.DeepEqual(authors, want) {
+		t.Errorf("history authors %v, want %v", authors, want)
+	}
+	deletion := history[1]
+	if deletion.After != nil || changedBy(deletion.Before) != "bob" || !deletion.ChangedAt.Equal(deletedAt) {
+		t.Errorf("deletion entry %+v, want bob's config removed at %s", deletion, deletedAt.Format(time.RFC3339))
+	}
+
+	paged, err := configRepo.GetConfigHistory(ctx, venue, entities.CONFIG_TYPE_DEMO_CONFIG, HistoryQuery{BeforeVersion: 3, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paged) != 1 || paged[0].Version != 2 {
+		t.Errorf("page before version 3 is %+v, want version 2 alone", paged)
+	}
+	windowed, err := configRepo.GetConfigHistory(ctx, venue, entities.CONFIG_TYPE_DEMO_CONFIG, HistoryQuery{Since: testEpoch.Add(time.Minute), Until: deletedAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(windowed) != 1 || windowed[0].ChangedBy != "bob" {
+		t.Errorf("history between bob's write and the deletion is %+v, want bob's write alone", windowed)
+	}
+
+	if _, err := configRepo.RevertConfig(ctx, venue, entities.CONFIG_TYPE_DEMO_CONFIG, 3, "dave"); err == nil {
+		t.Error("reverting to a deletion succeeded")
+	}
+	_, err = configRepo.RevertConfig(ctx, venue, entities.CONFIG_TYPE_DEMO_CONFIG, 9, "dave")
+	if !errors.As(err, &ErrHistoryVersionNotFound{}) {
+		t.Errorf("reverting to version 9: got %v, want ErrHistoryVersionNotFound", err)
+	}
+}
+
+func testDeleteConfig(t *testing.T, newRepo newTestRepo) {
+	ctx := context.Background()
+	configRepo := newRepo(t)
+	venue := VenueScope("1", "2")
+	mustSet(t, configRepo, CorporateScope("1"), cloudCart("CORPORATE", true))
+	mustSet(t, configRepo, venue, cloudCart("VENUE", true))
+
+	if err := configRepo.DeleteConfig(ctx, venue, entities.CONFIG_TYPE_DEMO_CONFIG, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustActive(t, configRepo, venue, entities.CONFIG_TYPE_DEMO_CONFIG); got != "CORPORATE" {
+		t.Errorf("active after the venue's config was deleted is %q, want CORPORATE", got)
+	}
+	err := configRepo.DeleteConfig(ctx, venue, entities.CONFIG_TYPE_DEMO_CONFIG, "alice")
+	if !errors.As(err, &ErrConfigNotFound{}) {
+		t.Errorf("deleting again: got %v, want ErrConfigNotFound", err)
+	}
+
+	//Setting it again numbers on from the deletion rather than reusing versions
+	if got := revisionOfConfig(mustSet(t, configRepo, venue, cloudCart("VENUE", true))); got != 3 {
+		t.Errorf("set after a deletion stored revision %d, want 3", got)
+	}
+}
+
+func testDeleteScope(t *testing.T, newRepo newTestRepo) {
+	ctx := context.Background()
+	configRepo := newRepo(t)
+	mustSet(t, configRepo, CorporateScope("1"), cloudCart("CORPORATE", true))
+	mustSet(t, configRepo, VenueScope("1", "2"), cloudCart("VENUE", true))
+	mustSet(t, configRepo, VendorScope("1", "2", "3"), otherExample("VENDOR", true))
+
+	if _, err := configRepo.DeleteScope(ctx, CorporateScope("1"), true, "alice"); err == nil {
+		t.Error("deleting the top level succeeded")
+	}
+	deleted, err := configRepo.DeleteScope(ctx, VenueScope("1", "2"), true, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("cascading delete removed %d documents, want 2", deleted)
+	}
+	if got := mustActive(t, configRepo, VendorScope("1", "2", "3"), entities.CONFIG_TYPE_DEMO_CONFIG); got != "CORPORATE" {
+		t.Errorf("active after the venue was deleted is %q, want CORPORATE", got)
+	}
+	_, err = configRepo.DeleteScope(ctx, VenueScope("1", "2"), true, "alice")
+	if !errors.As(err, &ErrScopeNotFound{}) {
+		t.Errorf("deleting again: got %v, want ErrScopeNotFound", err)
+	}
+
+	//A scope written again after its document was deleted numbers on from the history
+	if got := revisionOfConfig(mustSet(t, configRepo, VenueScope("1", "2"), cloudCart("VENUE", true))); got != 3 {
+		t.Errorf("set after the scope was deleted stored revision %d, want 3", got)
+	}
+}
+
+func cloudCart(changedBy string, enabled bool) *entities.CloudCartConfig {
+	return &entities.CloudCartConfig{ConfigMeta: entities.ConfigMeta{Enabled: enabled, ChangedBy: changedBy, ChangedAt: testEpoch}}
+}
+
+func otherExample(changedBy string, enabled bool) *entities.OtherConfig {
+	return &entities.OtherConfig{ConfigMeta: entities.ConfigMeta{Enabled: enabled, ChangedBy: changedBy, ChangedAt: testEpoch}}
+}
+
+//changedBy is the config's meta.changed_by, which the tests name writes by; "" for the empty config or none
+func changedBy(config entities.ValidatedConfig) string {
+	if meta, ok := config.(entities.MetaConfig); ok && !reflect.ValueOf(config).IsNil() {
+		return meta.GetMeta().ChangedBy
+	}
+
+	return ""
+}
+
+func mustSet(t *testing.T, configRepo ConfigRepository, scope Scope, config entities.ValidatedConfig) entities.ValidatedConfig {
+	t.Helper()
+	stored, err := configRepo.SetConfig(context.Background(), scope, config)
+	if err != nil {
+		t.Fatalf("setting %s at %s: %v", config.GetConfigType().String(), scope.String(), err)
+	}
+
+	return stored
+}
+
+func mustSpecific(t *testing.T, configRepo ConfigRepository, scope Scope, configType entities.ConfigType) entities.ValidatedConfig {
+	t.Helper()
+	specific, err := configRepo.GetSpecificConfig(context.Background(), scope, configType)
+	if err != nil {
+		t.Fatalf("specific %s at %s: %v", configType.String(), scope.String(), err)
+	}
+
+	return specific
+}
+
+//mustActive names the write active at scope
+func mustActive(t *testing.T, configRepo ConfigRepository, scope Scope, configType entities.ConfigType) string {
+	t.Helper()
+	active, err := configRepo.GetActiveConfig(context.Background(), scope, configType)
+	if err != nil {
+		t.Fatalf("active %s at %s: %v", configType.String(), scope.String(), err)
+	}
+
+	return changedBy(active)
+}
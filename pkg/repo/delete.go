@@ -0,0 +1,167 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//ErrConfigNotFound is returned by DeleteConfig when the scope has no config of the type to delete
+type ErrConfigNotFound struct {
+	Scope      Scope
+	ConfigType entities.ConfigType
+}
+
+func (e ErrConfigNotFound) Error() string {
+	return fmt.Sprintf("config type %s is not set at %s", e.ConfigType.String(), e.Scope.String())
+}
+
+//ErrScopeNotFound is returned by DeleteScope when there is no document to delete
+type ErrScopeNotFound struct {
+	Scope Scope
+}
+
+func (e ErrScopeNotFound) Error() string {
+	return fmt.Sprintf("no config document at %s", e.Scope.String())
+}
+
+func (r *MDBRepo) DeleteConfig(ctx context.Context, scope Scope, configType entities.ConfigType, changedBy string) error {
+	if entities.NewConfig(configType) == nil {
+		return fmt.Errorf("unsupported config type")
+	}
+	if changedBy == "" {
+		return fmt.Errorf("changedBy is required")
+	}
+	filter, err := makeUpsertConfigFilter(r.hierarchy, scope)
+	if err != nil {
+		return err
+	}
+
+	key := configType.String()
+	filter[key] = bson.M{"$exists": true}
+	configs, err := r.configCollection(ctx)
+	if err != nil {
+		return err
+	}
+	updateOpts := options.FindOneAndUpdate().SetProjection(makeWriteProjection(key))
+	result := configs.FindOneAndUpdate(ctx, filter, makeDeleteConfigUpdate(key), updateOpts)
+	if err := result.Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ErrConfigNotFound{Scope: scope.normalized(), ConfigType: configType}
+		}
+		return err
+	}
+	before, err := result.DecodeBytes()
+	if err != nil {
+		return err
+	}
+
+	entry, err := newDeletionEntry(r.hierarchy, scope, configType, before, deletedRevision(before, key, 0), changedBy, r.now())
+	if err != nil {
+		return err
+	}
+
+	return r.appendHistory(ctx, entry)
+}
+
+//DeleteScope deletes the matching documents one at a time, so each deleted config's history entry is taken from the
+//document as it was deleted rather than as it was found
+func (r *MDBRepo) DeleteScope(ctx context.Context, scope Scope, cascade bool, changedBy string) (int64, error) {
+	if changedBy == "" {
+		return 0, fmt.Errorf("changedBy is required")
+	}
+	filter, err := makeDeleteScopeFilter(r.hierarchy, scope, cascade)
+	if err != nil {
+		return 0, err
+	}
+
+	configs, err := r.configCollection(ctx)
+	if err != nil {
+		return 0, err
+	}
+	csr, err := configs.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, err
+	}
+	var found []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := csr.All(ctx, &found); err != nil {
+		return 0, err
+	}
+
+	now := r.now()
+	var deleted int64
+	for _, doc := range found {
+		before, err := configs.FindOneAndDelete(ctx, bson.M{"_id": doc.ID}).DecodeBytes()
+		if err == mongo.ErrNoDocuments {
+			//Deleted by someone else in the meantime
+			continue
+		}
+		if err != nil {
+			return deleted, err
+		}
+		deleted++
+
+		if err := r.recordScopeDeletion(ctx, before, changedBy, now); err != nil {
+			return deleted, err
+		}
+	}
+	if deleted == 0 {
+		return 0, ErrScopeNotFound{Scope: scope.normalized()}
+	}
+
+	return deleted, nil
+}
+
+//recordScopeDeletion adds a deletion to the history of every config the deleted document held
+func (r *MDBRepo) recordScopeDeletion(ctx context.Context, before bson.Raw, changedBy string, now time.Time) error {
+	scope, err := decodeScope(r.hierarchy, before)
+	if err != nil {
+		return err
+	}
+
+	for _, configType := range entities.RegisteredConfigTypes() {
+		if _, err := before.LookupErr(configType.String()); err != nil {
+			continue
+		}
+		entry, err := newDeletionEntry(r.hierarchy, scope, configType, before, deletedRevision(before, configType.String(), 0), changedBy, now)
+		if err != nil {
+			return err
+		}
+		if err := r.appendHistory(ctx, entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//decodeScope reads the scope fields of a document from the configs collection: config_level and the ID field of every
+//level down to it
+func decodeScope(h *entities.Hierarchy, doc bson.Raw) (Scope, error) {
+	var stored struct {
+		ConfigLevel entities.ConfigLevel `bson:"config_level"`
+	}
+	if err := bson.Unmarshal(doc, &stored); err != nil {
+		return Scope{}, err
+	}
+	if !h.ValidLevel(stored.ConfigLevel) {
+		return Scope{}, fmt.Errorf("invalid config level: %d", stored.ConfigLevel)
+	}
+
+	scope := Scope{ConfigLevel: stored.ConfigLevel}
+	for level := entities.ConfigLevel(1); level <= stored.ConfigLevel; level++ {
+		if value, err := doc.LookupErr(h.IDField(level)); err == nil {
+			scope.IDs[level-1], _ = value.StringValueOK()
+		}
+	}
+
+	return scope, nil
+}
@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+)
+
+//ConfigDiff is two configs of one type and the fields that differ between them; see entities.Diff.  Fields is empty,
+//not nil, when they agree.  To leave fields out, e.g. the write meta, call entities.Diff on A and B with options
+type ConfigDiff struct {
+	ConfigType entities.ConfigType      `json:"config_type"`
+	A          entities.ValidatedConfig `json:"a"`
+	B          entities.ValidatedConfig `json:"b"`
+	Fields     []entities.FieldDiff     `json:"fields"`
+}
+
+//DiffActiveConfigs compares the configs active at two scopes, e.g. a venue against its corporate default, read in one
+//round trip
+func DiffActiveConfigs(ctx context.Context, configRepo ConfigRepository, configType entities.ConfigType, a, b Scope, opts ...ActiveOption) (*ConfigDiff, error) {
+	active, err := configRepo.GetActiveConfigsForScopes(ctx, configType, []Scope{a, b}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConfigDiff(configType, active[a], active[b])
+}
+
+//DiffSpecificToActive compares the config set at scope, A, with the one active there, B.  They only differ when the
+//scope's own config doesn't win, e.g. because it is disabled or not set
+func DiffSpecificToActive(ctx context.Context, configRepo ConfigRepository, scope Scope, configType entities.ConfigType, opts ...ActiveOption) (*ConfigDiff, error) {
+	specific, err := configRepo.GetSpecificConfig(ctx, scope, configType)
+	if err != nil {
+		return nil, err
+	}
+	active, err := configRepo.GetActiveConfig(ctx, scope, configType, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConfigDiff(configType, specific, active)
+}
+
+//DiffSince compares the config set at scope at the time at, A, with the one set there now, B; see SpecificConfigAt
+func DiffSince(ctx context.Context, configRepo ConfigRepository, scope Scope, configType entities.ConfigType, at time.Time) (*ConfigDiff, error) {
+	then, err := SpecificConfigAt(ctx, configRepo, scope, configType, at)
+	if err != nil {
+		return nil, err
+	}
+	now, err := configRepo.GetSpecificConfig(ctx, scope, configType)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConfigDiff(configType, then, now)
+}
+
+//SpecificConfigAt is the config set at scope as of the time at, read back from its history: the value written by the
+//last change before or at that time, or an empty config if there was none or it was deleted.  Writes made before the
+//history was kept don't count
+func SpecificConfigAt(ctx context.Context, configRepo ConfigRepository, scope Scope, configType entities.ConfigType, at time.Time) (entities.ValidatedConfig, error) {
+	//History keeps milliseconds, so everything changed at or before at was changed before the next millisecond
+	until := at.Truncate(time.Millisecond).Add(time.Millisecond)
+	entries, err := configRepo.GetConfigHistory(ctx, scope, configType, HistoryQuery{Until: until, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 || entries[0].After == nil {
+		return entities.NewConfig(configType), nil
+	}
+
+	return entries[0].After, nil
+}
+
+func newConfigDiff(configType entities.ConfigType, a, b entities.ValidatedConfig) (*ConfigDiff, error) {
+	fields, err := entities.Diff(a, b)
+	if err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		fields = []entities.FieldDiff{}
+	}
+
+	return &ConfigDiff{ConfigType: configType, A: a, B: b, Fields: fields}, nil
+}
@@ -0,0 +1,129 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+)
+
+//diffTestRepo has a corporate cloud_cart validating prices and a venue below it that calculates reductions and taxes,
+//set at testEpoch and disabled an hour later
+func diffTestRepo(t *testing.T) ConfigRepository {
+	configRepo := NewMemoryRepo()
+	corporate := cloudCart("CORP", true)
+	corporate.EnableValidatePrices = true
+	mustSet(t, configRepo, CorporateScope("1"), corporate)
+
+	venue := cloudCart("VENUE", true)
+	venue.EnableCalculateReductionsAndTaxes = true
+	mustSet(t, configRepo, VenueScope("1", "2"), venue)
+	disabled := cloudCart("VENUE", false)
+	disabled.EnableCalculateReductionsAndTaxes = true
+	disabled.ChangedAt = testEpoch.Add(time.Hour)
+	mustSet(t, configRepo, VenueScope("1", "2"), disabled)
+
+	return configRepo
+}
+
+func TestDiffHelpers(t *testing.T) {
+	ctx := context.Background()
+	configRepo := diffTestRepo(t)
+	venue := VenueScope("1", "2")
+
+	for _, test := range []struct {
+		name string
+		diff func() (*ConfigDiff, error)
+		want []string
+	}{
+		{
+			name: "active configs of a disabled venue and a vendor below another venue",
+			diff: func() (*ConfigDiff, error) {
+				return DiffActiveConfigs(ctx, configRepo, entities.CONFIG_TYPE_DEMO_CONFIG, venue, VendorScope("1", "3", "4"))
+			},
+			want: []string{},
+		},
+		{
+			name: "specific and active configs of a disabled venue",
+			diff: func() (*ConfigDiff, error) {
+				return DiffSpecificToActive(ctx, configRepo, venue, entities.CONFIG_TYPE_DEMO_CONFIG)
+			},
+			want: []string{"meta.enabled", "meta.changed_by", "meta.changed_at", "meta.revision", "enable_calculate_reductions_and_taxes", "enable_validate_prices"},
+		},
+		{
+			name: "a config now and before it was disabled",
+			diff: func() (*ConfigDiff, error) {
+				return DiffSince(ctx, configRepo, venue, entities.CONFIG_TYPE_DEMO_CONFIG, testEpoch)
+			},
+			want: []string{"meta.enabled", "meta.changed_at", "meta.revision"},
+		},
+		{
+			name: "a config now and a moment before it was disabled",
+			diff: func() (*ConfigDiff, error) {
+				return DiffSince(ctx, configRepo, venue, entities.CONFIG_TYPE_DEMO_CONFIG, testEpoch.Add(time.Hour-time.Nanosecond))
+			},
+			want: []string{"meta.enabled", "meta.changed_at", "meta.revision"},
+		},
+		{
+			name: "a config now and when it was disabled",
+			diff: func() (*ConfigDiff, error) {
+				return DiffSince(ctx, configRepo, venue, entities.CONFIG_TYPE_DEMO_CONFIG, testEpoch.Add(time.Hour))
+			},
+			want: []string{},
+		},
+		{
+			name: "a config now and before it was set",
+			diff: func() (*ConfigDiff, error) {
+				return DiffSince(ctx, configRepo, venue, entities.CONFIG_TYPE_DEMO_CONFIG, testEpoch.Add(-time.Millisecond))
+			},
+			want: []string{"meta.changed_by", "meta.changed_at", "meta.revision", "enable_calculate_reductions_and_taxes"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			diff, err := test.diff()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff.Fields == nil || diff.ConfigType != entities.CONFIG_TYPE_DEMO_CONFIG {
+				t.Fatalf("got %+v, want a cloud_cart diff with a non-nil field list", diff)
+			}
+			got := make([]string, 0, len(diff.Fields))
+			for _, field := range diff.Fields {
+				got = append(got, field.Path)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("got fields %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDiffSinceDeletion(t *testing.T) {
+	ctx := context.Background()
+	clock := newTestClock()
+	configRepo := NewMemoryRepo(WithClock(clock.Now))
+	venue := VenueScope("1", "2")
+	mustSet(t, configRepo, venue, cloudCart("VENUE", true))
+	clock.Advance(time.Hour)
+	if err := configRepo.DeleteConfig(ctx, venue, entities.CONFIG_TYPE_DEMO_CONFIG, "VENUE"); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := DiffSince(ctx, configRepo, venue, entities.CONFIG_TYPE_DEMO_CONFIG, testEpoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changedBy(diff.A) != "VENUE" || changedBy(diff.B) != "" {
+		t.Errorf("diffed %q against %q, want the venue's config against the empty config", changedBy(diff.A), changedBy(diff.B))
+	}
+	if len(diff.Fields) == 0 || diff.Fields[0].Path != "meta.enabled" {
+		t.Errorf("got fields %v, want meta.enabled first", diff.Fields)
+	}
+
+	then, err := SpecificConfigAt(ctx, configRepo, venue, entities.CONFIG_TYPE_DEMO_CONFIG, clock.Now())
+	if err != nil || changedBy(then) != "" {
+		t.Errorf("config after its deletion read as %q, %v, want the empty config", changedBy(then), err)
+	}
+}
@@ -0,0 +1,175 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+//SkipReason says why an active config candidate didn't win
+type SkipReason string
+
+const (
+	//SkipReasonNotSet means the document matched the scope but has no config of the requested type
+	SkipReasonNotSet SkipReason = "not_set"
+	//SkipReasonDisabled means the config is set but meta.enabled is false
+	SkipReasonDisabled SkipReason = "disabled"
+	//SkipReasonNotEffective means the config is enabled, but the evaluation time is outside its effective window
+	SkipReasonNotEffective SkipReason = "not_effective"
+	//SkipReasonOutranked means the config is enabled, but a candidate ranked above it won
+	SkipReasonOutranked SkipReason = "outranked"
+	//SkipReasonDuplicateScope means the config is active, but so is the winner, from another document for the same scope
+	SkipReasonDuplicateScope SkipReason = "duplicate_scope"
+)
+
+//ActiveConfigCandidate is one document the active resolution considered.  IDs are keyed by the hierarchy's ID fields
+//and hold the candidate's own level and those above it
+type ActiveConfigCandidate struct {
+	ConfigLevel entities.ConfigLevel `json:"config_level"`
+	IDs         map[string]string    `json:"ids"`
+	Set         bool                 `json:"set"`
+	Enabled     bool                 `json:"enabled"`
+	Effective   bool                 `json:"effective"`
+	Selected    bool                 `json:"selected"`
+	SkipReason  SkipReason           `json:"skip_reason,omitempty"`
+	//Config is nil when Set is false
+	Config entities.ValidatedConfig `json:"config,omitempty"`
+
+	hierarchy *entities.Hierarchy
+}
+
+//MarshalJSON names the candidate's level through the hierarchy it was read in
+func (c ActiveConfigCandidate) MarshalJSON() ([]byte, error) {
+	type candidate ActiveConfigCandidate
+	return json.Marshal(struct {
+		ConfigLevel string `json:"config_level"`
+		candidate
+	}{ConfigLevel: c.hierarchy.LevelName(c.ConfigLevel), candidate: candidate(c)})
+}
+
+//ActiveConfigExplanation is the trace of a GetActiveConfig call.  Candidates are in the order the hierarchy ranks them,
+//most specific level first; Winner indexes the selected one, or is -1 if nothing was enabled.  Config is what
+//GetActiveConfig returns, unless Ambiguous is set: then a duplicate scope document is active too and GetActiveConfig
+//fails with ErrAmbiguousScope.  AsOf is the time effective windows were evaluated at.  Config has the winner's rollouts
+//applied, and Rollouts says how each one was assigned for the explained scope; the winning candidate's Config is as set
+type ActiveConfigExplanation struct {
+	ConfigLevel entities.ConfigLevel         `json:"config_level"`
+	ConfigType  entities.ConfigType          `json:"config_type"`
+	AsOf        time.Time                    `json:"as_of"`
+	Candidates  []ActiveConfigCandidate      `json:"candidates"`
+	Winner      int                          `json:"winner"`
+	Ambiguous   bool                         `json:"ambiguous,omitempty"`
+	Config      entities.ValidatedConfig     `json:"config"`
+	Rollouts    []entities.RolloutAssignment `json:"rollouts,omitempty"`
+
+	hierarchy *entities.Hierarchy
+}
+
+//MarshalJSON names the explained level, like the candidates', through the hierarchy it was read in
+func (e ActiveConfigExplanation) MarshalJSON() ([]byte, error) {
+	type explanation ActiveConfigExplanation
+	return json.Marshal(struct {
+		ConfigLevel string `json:"config_level"`
+		explanation
+	}{ConfigLevel: e.hierarchy.LevelName(e.ConfigLevel), explanation: explanation(e)})
+}
+
+func (r *MDBRepo) ExplainActiveConfig(ctx context.Context, scope Scope, configType entities.ConfigType, opts ...ActiveOption) (*ActiveConfigExplanation, error) {
+	activeOpts := newActiveOptions(r.now, opts)
+	pipeline, err := makeExplainActiveConfigPipeline(r.hierarchy, scope, configType)
+	if err != nil {
+		return nil, err
+	}
+	configs, err := r.configCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := configs.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer csr.Close(ctx)
+
+	var docs []bson.Raw
+	for csr.Next(ctx) {
+		docs = append(docs, append(bson.Raw(nil), csr.Current...))
+	}
+	if err := csr.Err(); err != nil {
+		return nil, err
+	}
+
+	return explainActiveCandidates(r.hierarchy, scope, configType, docs, activeOpts.asOf)
+}
+
+//explainActiveCandidates walks ranked candidate documents the way the active pipeline does: the first one enabled and
+//effective at asOf wins, and another active one at its level makes the result ambiguous.  The winner's rollouts are
+//applied for scope
+func explainActiveCandidates(h *entities.Hierarchy, scope Scope, configType entities.ConfigType, docs []bson.Raw, asOf time.Time) (*ActiveConfigExplanation, error) {
+	configLevel := scope.ConfigLevel
+	explanation := &ActiveConfigExplanation{
+		ConfigLevel: configLevel,
+		ConfigType:  configType,
+		AsOf:        asOf,
+		Winner:      -1,
+		hierarchy:   h,
+	}
+
+	for _, doc := range docs {
+		docScope, err := decodeScope(h, doc)
+		if err != nil {
+			return nil, err
+		}
+		candidate := ActiveConfigCandidate{
+			ConfigLevel: docScope.ConfigLevel,
+			IDs:         scopeIDs(h, docScope),
+			hierarchy:   h,
+		}
+
+		if value, err := doc.LookupErr(configType.String()); err == nil {
+			config, err := newConfigForDecode(h, configLevel, configType)
+			if err != nil {
+				return nil, err
+			}
+			if err := value.Unmarshal(config); err != nil {
+				return nil, err
+			}
+			candidate.Set = true
+			candidate.Config = config
+			if subDoc, ok := value.DocumentOK(); ok {
+				candidate.Enabled, _ = subDoc.Lookup("meta", "enabled").BooleanOK()
+			}
+			candidate.Effective = true
+			if meta, ok := config.(entities.MetaConfig); ok {
+				candidate.Effective = meta.GetMeta().EffectiveAt(asOf)
+			}
+		}
+
+		switch {
+		case !candidate.Set:
+			candidate.SkipReason = SkipReasonNotSet
+		case !candidate.Enabled:
+			candidate.SkipReason = SkipReasonDisabled
+		case !candidate.Effective:
+			candidate.SkipReason = SkipReasonNotEffective
+		case explanation.Winner >= 0 && candidate.ConfigLevel == explanation.Candidates[explanation.Winner].ConfigLevel:
+			candidate.SkipReason = SkipReasonDuplicateScope
+			explanation.Ambiguous = true
+		case explanation.Winner >= 0:
+			candidate.SkipReason = SkipReasonOutranked
+		default:
+			candidate.Selected = true
+			explanation.Winner = len(explanation.Candidates)
+			explanation.Config, explanation.Rollouts = entities.ApplyRollouts(candidate.Config, scope.Path())
+		}
+		explanation.Candidates = append(explanation.Candidates, candidate)
+	}
+
+	if explanation.Winner < 0 {
+		explanation.Config = emptyConfigForType(h, configLevel, configType)
+	}
+
+	return explanation, nil
+}
@@ -0,0 +1,648 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"go.mongodb.org/mongo-driver/bson"
+	"gopkg.in/yaml.v2"
+)
+
+//ExportFormat is a file format a ConfigTree can be written in.  DecodeConfigTree reads either, since JSON is YAML
+type ExportFormat string
+
+const (
+	ExportFormatYAML ExportFormat = "yaml"
+	ExportFormatJSON ExportFormat = "json"
+)
+
+//ConfigTree is every config document under one top level scope, nested by level, for keeping configuration in files.
+//In the default hierarchy a file reads:
+//
+//	corporate: "1"
+//	configs:
+//	  cloud_cart: {...}
+//	venues:
+//	- venue: "2"
+//	  configs: {...}
+//	  vendors:
+//	  - vendor: "3"
+//	    configs: {...}
+//
+//Configs are keyed by ConfigType.String() and written as their JSON form, without meta.revision, which the repository
+//maintains
+type ConfigTree struct {
+	Root      *ConfigTreeNode
+	hierarchy *entities.Hierarchy
+}
+
+//ConfigTreeNode is one scope and the configs set on it.  A node without configs only holds its children, e.g. a venue
+//with no document of its own whose vendors have configs
+type ConfigTreeNode struct {
+	Scope    Scope
+	Configs  entities.ConfigSet
+	Children []*ConfigTreeNode
+}
+
+//ImportedConfig is a config Import wrote
+type ImportedConfig struct {
+	ConfigLevel entities.ConfigLevel `json:"config_level"`
+	Path        []string             `json:"path"`
+	ConfigType  entities.ConfigType  `json:"config_type"`
+
+	hierarchy *entities.Hierarchy
+}
+
+//MarshalJSON names the config's level through the hierarchy it was imported into
+func (c ImportedConfig) MarshalJSON() ([]byte, error) {
+	type imported ImportedConfig
+	return json.Marshal(struct {
+		ConfigLevel string `json:"config_level"`
+		imported
+	}{ConfigLevel: c.hierarchy.LevelName(c.ConfigLevel), imported: imported(c)})
+}
+
+//ImportResult lists the configs Import wrote.  Configs already stored as the tree has them, meta aside, are left alone
+//so re-importing a file adds no revisions or history; Unchanged counts them
+type ImportResult struct {
+	Applied   []ImportedConfig `json:"applied"`
+	Unchanged int              `json:"unchanged"`
+}
+
+//ErrInvalidConfigTree is returned by DecodeConfigTree and Import for a tree that can't be applied.  Nothing has been
+//written when Import returns it.  Path locates the problem, e.g. corporate "1" / venue "2" / cloud_cart
+type ErrInvalidConfigTree struct {
+	Path string
+	Err  error
+}
+
+func (e ErrInvalidConfigTree) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("invalid config tree: %s", e.Err.Error())
+	}
+
+	return fmt.Sprintf("invalid config tree at %s: %s", e.Path, e.Err.Error())
+}
+
+func (e ErrInvalidConfigTree) Unwrap() error {
+	return e.Err
+}
+
+func (r *MDBRepo) Export(ctx context.Context, topID string) (*ConfigTree, error) {
+	configs, err := r.configCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := configs.Find(ctx, makeExportFilter(r.hierarchy, topID))
+	if err != nil {
+		return nil, err
+	}
+	defer csr.Close(ctx)
+	documents, err := memoryDocumentsFromCursor(ctx, csr, r.hierarchy)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildConfigTree(r.hierarchy, topID, documents)
+}
+
+func (r *MDBRepo) Import(ctx context.Context, tree *ConfigTree, changedBy string) (*ImportResult, error) {
+	return importConfigTree(ctx, r, tree, changedBy, r.now)
+}
+
+func (r *MemoryRepo) Export(ctx context.Context, topID string) (*ConfigTree, error) {
+	r, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var documents []*memoryDocument
+	for _, doc := range r.documents {
+		if doc.scope.ID(entities.CONFIG_LEVEL_CORPORATE) == topID {
+			documents = append(documents, doc)
+		}
+	}
+
+	return buildConfigTree(r.hierarchy, topID, documents)
+}
+
+func (r *MemoryRepo) Import(ctx context.Context, tree *ConfigTree, changedBy string) (*ImportResult, error) {
+	return importConfigTree(ctx, r, tree, changedBy, r.now)
+}
+
+//Hierarchy is the hierarchy the tree's scopes are read against
+func (t *ConfigTree) Hierarchy() *entities.Hierarchy {
+	return t.hierarchy
+}
+
+//Encode writes the tree in format
+func (t *ConfigTree) Encode(format ExportFormat) ([]byte, error) {
+	switch format {
+	case ExportFormatYAML:
+		return yaml.Marshal(t)
+	case ExportFormatJSON:
+		return json.MarshalIndent(t, "", "  ")
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+func (t *ConfigTree) MarshalJSON() ([]byte, error) {
+	node, err := t.encodeNode(t.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(node)
+}
+
+//MarshalYAML returns the root as a MapSlice: yaml.v2 doesn't call MarshalYAML again on what MarshalYAML returns
+func (t *ConfigTree) MarshalYAML() (interface{}, error) {
+	node, err := t.encodeNode(t.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.MarshalYAML()
+}
+
+//encodeNode lays a node out as the file has it: its ID under the level's name, its configs, then its children under
+//the next level's name with an "s"
+func (t *ConfigTree) encodeNode(node *ConfigTreeNode) (orderedObject, error) {
+	level := node.Scope.ConfigLevel
+	object := orderedObject{{key: t.hierarchy.LevelName(level), value: node.Scope.ID(level)}}
+
+	var configs orderedObject
+	for _, configType := range entities.RegisteredConfigTypes() {
+		config := node.Configs.Get(configType)
+		if config == nil {
+			continue
+		}
+		encoded, err := encodeExportedConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, orderedField{key: configType.String(), value: encoded})
+	}
+	if len(configs) > 0 {
+		object = append(object, orderedField{key: "configs", value: configs})
+	}
+
+	if len(node.Children) > 0 {
+		children := make([]interface{}, 0, len(node.Children))
+		for _, child := range node.Children {
+			encoded, err := t.encodeNode(child)
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, encoded)
+		}
+		object = append(object, orderedField{key: t.hierarchy.LevelName(level+1) + "s", value: children})
+	}
+
+	return object, nil
+}
+
+//encodeExportedConfig is config's JSON form, fields in declaration order, without meta.revision
+func encodeExportedConfig(config entities.ValidatedConfig) (interface{}, error) {
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	encoded, err := decodeOrderedJSON(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	if object, ok := encoded.(orderedObject); ok {
+		for i, field := range object {
+			if meta, ok := field.value.(orderedObject); ok && field.key == "meta" {
+				object[i].value = meta.without("revision")
+			}
+		}
+	}
+
+	return encoded, nil
+}
+
+//DecodeConfigTree reads a YAML or JSON file written by ConfigTree.Encode.  It checks the file's shape, that every config
+//key is registered and allowed at its level, and that config fields are known; whole configs are validated by Import,
+//which may stamp their meta first
+func DecodeConfigTree(h *entities.Hierarchy, data []byte) (*ConfigTree, error) {
+	var root yaml.MapSlice
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, ErrInvalidConfigTree{Err: err}
+	}
+
+	tree := &ConfigTree{hierarchy: h}
+	node, err := tree.decodeNode(root, Scope{}, 1, "")
+	if err != nil {
+		return nil, err
+	}
+	tree.Root = node
+
+	return tree, nil
+}
+
+func (t *ConfigTree) decodeNode(object yaml.MapSlice, parent Scope, level entities.ConfigLevel, parentPath string) (*ConfigTreeNode, error) {
+	levelName := t.hierarchy.LevelName(level)
+	childrenKey := ""
+	if int(level) < t.hierarchy.Depth() {
+		childrenKey = t.hierarchy.LevelName(level+1) + "s"
+	}
+
+	var id interface{}
+	var configs, children interface{}
+	for _, item := range object {
+		key, _ := item.Key.(string)
+		switch {
+		case key == levelName:
+			id = item.Value
+		case key == "configs":
+			configs = item.Value
+		case key == childrenKey && childrenKey != "":
+			children = item.Value
+		default:
+			return nil, ErrInvalidConfigTree{Path: parentPath, Err: fmt.Errorf("unexpected key %q in a %s", fmt.Sprint(item.Key), levelName)}
+		}
+	}
+	idString, ok := id.(string)
+	if !ok || idString == "" {
+		return nil, ErrInvalidConfigTree{Path: parentPath, Err: fmt.Errorf("every %s needs a %s ID, quoted as a string", levelName, levelName)}
+	}
+
+	ids := append(parent.Path(), idString)
+	node := &ConfigTreeNode{Scope: NewScope(level, ids...), Configs: entities.ConfigSet{}}
+	path := joinTreePath(parentPath, fmt.Sprintf("%s %q", levelName, idString))
+
+	if configs != nil {
+		configObject, ok := configs.(yaml.MapSlice)
+		if !ok {
+			return nil, ErrInvalidConfigTree{Path: path, Err: fmt.Errorf("configs must map config types to configs")}
+		}
+		for _, item := range configObject {
+			key := fmt.Sprint(item.Key)
+			config, err := t.decodeConfig(level, key, item.Value)
+			if err != nil {
+				return nil, ErrInvalidConfigTree{Path: joinTreePath(path, key), Err: err}
+			}
+			node.Configs[config.GetConfigType()] = config
+		}
+	}
+
+	if children != nil {
+		list, ok := children.([]interface{})
+		if !ok {
+			return nil, ErrInvalidConfigTree{Path: path, Err: fmt.Errorf("%s must be a list", childrenKey)}
+		}
+		seen := map[string]bool{}
+		for _, item := range list {
+			childObject, ok := item.(yaml.MapSlice)
+			if !ok {
+				return nil, ErrInvalidConfigTree{Path: path, Err: fmt.Errorf("%s must be a list of objects", childrenKey)}
+			}
+			child, err := t.decodeNode(childObject, node.Scope, level+1, path)
+			if err != nil {
+				return nil, err
+			}
+			childID := child.Scope.ID(level + 1)
+			if seen[childID] {
+				return nil, ErrInvalidConfigTree{Path: path, Err: fmt.Errorf("%s %q is listed twice", t.hierarchy.LevelName(level+1), childID)}
+			}
+			seen[childID] = true
+			node.Children = append(node.Children, child)
+		}
+	}
+
+	return node, nil
+}
+
+//decodeConfig reads one config through its JSON form, refusing fields the type doesn't have
+func (t *ConfigTree) decodeConfig(level entities.ConfigLevel, key string, value interface{}) (entities.ValidatedConfig, error) {
+	descriptor, ok := entities.LookupConfigKey(key)
+	if !ok {
+		return nil, fmt.Errorf("unsupported config type: %s", key)
+	}
+	if err := t.hierarchy.ValidateConfigLevel(level, descriptor.ID); err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(plainYAMLValue(value))
+	if err != nil {
+		return nil, err
+	}
+	config := entities.NewConfig(descriptor.ID)
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+//importConfigTree is Import for any ConfigRepository, written on top of SetConfig so a CachedResolver invalidates what
+//it writes.  Every config is checked before the first write.  now is the repository's clock, which stamps the configs
+//when changedBy is given
+func importConfigTree(ctx context.Context, configRepo ConfigRepository, tree *ConfigTree, changedBy string, now func() time.Time) (*ImportResult, error) {
+	if tree == nil || tree.Root == nil {
+		return nil, ErrInvalidConfigTree{Err: fmt.Errorf("the tree is empty")}
+	}
+	if !tree.hierarchy.SameLevels(configRepo.Hierarchy()) {
+		return nil, ErrInvalidConfigTree{Err: fmt.Errorf("the tree was read for another hierarchy")}
+	}
+
+	type pendingConfig struct {
+		scope  Scope
+		config entities.ValidatedConfig
+	}
+	var pending []pendingConfig
+	changedAt := now()
+	var walkErr error
+	tree.walk(func(node *ConfigTreeNode, path string) bool {
+		for _, configType := range entities.RegisteredConfigTypes() {
+			config := node.Configs.Get(configType)
+			if config == nil {
+				continue
+			}
+			config = entities.CloneConfig(config)
+			if meta, ok := config.(entities.MetaConfig); ok && changedBy != "" {
+				meta.GetMeta().ChangedBy = changedBy
+				meta.GetMeta().ChangedAt = changedAt
+			}
+			if err := tree.hierarchy.ValidateConfigLevel(node.Scope.ConfigLevel, configType); err != nil {
+				walkErr = ErrInvalidConfigTree{Path: joinTreePath(path, configType.String()), Err: err}
+				return false
+			}
+			if err := entities.ValidateConfig(config); err != nil {
+				walkErr = ErrInvalidConfigTree{Path: joinTreePath(path, configType.String()), Err: err}
+				return false
+			}
+			pending = append(pending, pendingConfig{scope: node.Scope, config: config})
+		}
+		return true
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+
+	result := &ImportResult{Applied: []ImportedConfig{}}
+	for _, p := range pending {
+		stored, err := configRepo.GetSpecificConfig(ctx, p.scope, p.config.GetConfigType())
+		if err != nil {
+			return result, err
+		}
+		same, err := sameExportedConfig(stored, p.config)
+		if err != nil {
+			return result, err
+		}
+		if same {
+			result.Unchanged++
+			continue
+		}
+		if _, err := configRepo.SetConfig(ctx, p.scope, p.config); err != nil {
+			return result, err
+		}
+		result.Applied = append(result.Applied, ImportedConfig{ConfigLevel: p.scope.ConfigLevel, Path: p.scope.Path(), ConfigType: p.config.GetConfigType(), hierarchy: configRepo.Hierarchy()})
+	}
+
+	return result, nil
+}
+
+//sameExportedConfig compares two configs as an export would write them, ignoring who changed them and when
+func sameExportedConfig(a, b entities.ValidatedConfig) (bool, error) {
+	a, b = entities.CloneConfig(a), entities.CloneConfig(b)
+	for _, config := range []entities.ValidatedConfig{a, b} {
+		if meta, ok := config.(entities.MetaConfig); ok {
+			meta.GetMeta().ChangedBy = ""
+			meta.GetMeta().ChangedAt = time.Time{}
+		}
+	}
+	encodedA, err := encodeExportedConfig(a)
+	if err != nil {
+		return false, err
+	}
+	encodedB, err := encodeExportedConfig(b)
+	if err != nil {
+		return false, err
+	}
+
+	return reflect.DeepEqual(encodedA, encodedB), nil
+}
+
+//walk visits the tree top down, passing each node's path, until visit returns false
+func (t *ConfigTree) walk(visit func(node *ConfigTreeNode, path string) bool) {
+	var walkNode func(node *ConfigTreeNode, parentPath string) bool
+	walkNode = func(node *ConfigTreeNode, parentPath string) bool {
+		level := node.Scope.ConfigLevel
+		path := joinTreePath(parentPath, fmt.Sprintf("%s %q", t.hierarchy.LevelName(level), node.Scope.ID(level)))
+		if !visit(node, path) {
+			return false
+		}
+		for _, child := range node.Children {
+			if !walkNode(child, path) {
+				return false
+			}
+		}
+		return true
+	}
+	walkNode(t.Root, "")
+}
+
+//buildConfigTree nests the documents under the top level scope topID.  Scopes with descendants but no document of
+//their own get a node without configs
+func buildConfigTree(h *entities.Hierarchy, topID string, documents []*memoryDocument) (*ConfigTree, error) {
+	root := &ConfigTreeNode{Scope: NewScope(entities.CONFIG_LEVEL_CORPORATE, topID), Configs: entities.ConfigSet{}}
+	if len(documents) == 0 {
+		return nil, ErrScopeNotFound{Scope: root.Scope}
+	}
+
+	nodes := map[Scope]*ConfigTreeNode{root.Scope: root}
+	var nodeFor func(scope Scope) *ConfigTreeNode
+	nodeFor = func(scope Scope) *ConfigTreeNode {
+		if node, ok := nodes[scope]; ok {
+			return node
+		}
+		parent := nodeFor(scope.At(scope.ConfigLevel - 1))
+		node := &ConfigTreeNode{Scope: scope, Configs: entities.ConfigSet{}}
+		parent.Children = append(parent.Children, node)
+		nodes[scope] = node
+		return node
+	}
+
+	seen := map[Scope]bool{}
+	for _, doc := range documents {
+		if seen[doc.scope] {
+			return nil, ErrAmbiguousScope{Scope: doc.scope, ConfigType: entities.CONFIG_TYPE_FULL}
+		}
+		seen[doc.scope] = true
+		node := nodeFor(doc.scope)
+		for key, raw := range doc.configs {
+			descriptor, ok := entities.LookupConfigKey(key)
+			if !ok {
+				continue
+			}
+			config := entities.NewConfig(descriptor.ID)
+			if err := bson.Unmarshal(raw, config); err != nil {
+				return nil, err
+			}
+			node.Configs[descriptor.ID] = config
+		}
+	}
+
+	for _, node := range nodes {
+		sort.Slice(node.Children, func(i, j int) bool {
+			return node.Children[i].Scope.ID(node.Children[i].Scope.ConfigLevel) < node.Children[j].Scope.ID(node.Children[j].Scope.ConfigLevel)
+		})
+	}
+
+	return &ConfigTree{Root: root, hierarchy: h}, nil
+}
+
+func joinTreePath(parent, element string) string {
+	if parent == "" {
+		return element
+	}
+
+	return parent + " / " + element
+}
+
+//orderedObject is an object that keeps its keys in the order they were added, in JSON and YAML alike, so exported
+//files read the way the structs are declared
+type orderedObject []orderedField
+
+type orderedField struct {
+	key   string
+	value interface{}
+}
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(field.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+func (o orderedObject) MarshalYAML() (interface{}, error) {
+	slice := make(yaml.MapSlice, len(o))
+	for i, field := range o {
+		slice[i] = yaml.MapItem{Key: field.key, Value: field.value}
+	}
+
+	return slice, nil
+}
+
+//without returns o less the field named key
+func (o orderedObject) without(key string) orderedObject {
+	kept := make(orderedObject, 0, len(o))
+	for _, field := range o {
+		if field.key != key {
+			kept = append(kept, field)
+		}
+	}
+
+	return kept
+}
+
+//decodeOrderedJSON reads the next JSON value, objects as orderedObject and whole numbers as int64 so YAML doesn't print
+//them as floats
+func decodeOrderedJSON(decoder *json.Decoder) (interface{}, error) {
+	decoder.UseNumber()
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		object := orderedObject{}
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrderedJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+			object = append(object, orderedField{key: keyToken.(string), value: value})
+		}
+		_, err = decoder.Token()
+		return object, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for decoder.More() {
+			value, err := decodeOrderedJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err = decoder.Token()
+		return list, err
+	case nil:
+		return nil, nil
+	default:
+		if number, ok := token.(json.Number); ok {
+			if n, err := number.Int64(); err == nil {
+				return n, nil
+			}
+			return number.Float64()
+		}
+		return token, nil
+	}
+}
+
+//plainYAMLValue turns what yaml.v2 decodes into values encoding/json can marshal: MapSlice and interface keyed maps
+//become string keyed maps
+func plainYAMLValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		object := make(map[string]interface{}, len(v))
+		for _, item := range v {
+			object[fmt.Sprint(item.Key)] = plainYAMLValue(item.Value)
+		}
+		return object
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			object[fmt.Sprint(key)] = plainYAMLValue(item)
+		}
+		return object
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = plainYAMLValue(item)
+		}
+		return list
+	default:
+		return v
+	}
+}
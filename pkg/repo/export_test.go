@@ -0,0 +1,194 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+)
+
+type exportWrite struct {
+	scope  Scope
+	config entities.ValidatedConfig
+}
+
+//exportWrites is a corporate with configs on each level, sub-second times and fractional floats, plus a config on
+//another corporate that mustn't be exported with it
+func exportWrites() []exportWrite {
+	changedAt := time.Date(2026, 3, 1, 9, 30, 0, 123456789, time.UTC)
+	effectiveFrom := changedAt.Add(-time.Hour)
+	effectiveUntil := changedAt.AddDate(0, 6, 0).Add(987 * time.Millisecond)
+
+	return []exportWrite{
+		{CorporateScope("1"), &entities.CloudCartConfig{ConfigMeta: entities.ConfigMeta{Enabled: true, ChangedBy: "CORP", ChangedAt: changedAt}, EnableValidatePrices: true}},
+		{CorporateScope("1"), &entities.OtherConfig{ConfigMeta: entities.ConfigMeta{Enabled: true, ChangedBy: "CORP", ChangedAt: changedAt, EffectiveFrom: &effectiveFrom, EffectiveUntil: &effectiveUntil}, ADifferentValue: "corporate", AFloat: 2.5}},
+		{VenueScope("1", "2"), &entities.CloudCartConfig{ConfigMeta: entities.ConfigMeta{Enabled: false, ChangedBy: "VENUE", ChangedAt: changedAt, Overrides: []string{"enable_validate_prices"}}}},
+		{VendorScope("1", "2", "3"), &entities.OtherConfig{ConfigMeta: entities.ConfigMeta{Enabled: true, ChangedBy: "VENDOR", ChangedAt: changedAt}, ADifferentValue: "vendor", AFloat: 999.99}},
+		{VendorScope("1", "5", "4"), &entities.OtherConfig{ConfigMeta: entities.ConfigMeta{Enabled: true, ChangedBy: "VENDOR", ChangedAt: changedAt}, AFloat: 0.1}},
+		{CorporateScope("9"), &entities.CloudCartConfig{ConfigMeta: entities.ConfigMeta{Enabled: true, ChangedBy: "OTHER CORP", ChangedAt: changedAt}}},
+	}
+}
+
+//TestExportRoundTrip checks that a corporate exported in each format and imported into an empty repository exports
+//again byte for byte, keeps its times and floats, and that importing it a second time writes nothing
+func TestExportRoundTrip(t *testing.T) {
+	for _, format := range []ExportFormat{ExportFormatYAML, ExportFormatJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			ctx := context.Background()
+			source, target := NewMemoryRepo(), NewMemoryRepo()
+			writes := exportWrites()
+			for _, w := range writes {
+				mustSet(t, source, w.scope, w.config)
+			}
+			exported := writes[:len(writes)-1]
+
+			tree, err := source.Export(ctx, "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := tree.Encode(format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := DecodeConfigTree(target.Hierarchy(), data)
+			if err != nil {
+				t.Fatalf("decode: %v\n%s", err, data)
+			}
+			result, err := target.Import(ctx, decoded, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Applied) != len(exported) || result.Unchanged != 0 {
+				t.Errorf("import applied %d and left %d unchanged, want %d and 0", len(result.Applied), result.Unchanged, len(exported))
+			}
+
+			reexported, err := target.Export(ctx, "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			again, err := reexported.Encode(format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(again) != string(data) {
+				t.Errorf("export after import got\n%s\nwant\n%s", again, data)
+			}
+
+			for _, w := range exported {
+				imported, err := target.GetSpecificConfig(ctx, w.scope, w.config.GetConfigType())
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkExportedFields(t, w, imported)
+			}
+
+			result, err = target.Import(ctx, decoded, "")
+			if err != nil || len(result.Applied) != 0 || result.Unchanged != len(exported) {
+				t.Errorf("second import got %+v, %v, want no writes", result, err)
+			}
+		})
+	}
+}
+
+//checkExportedFields compares the times and floats of an imported config with the write it came from.  Times are
+//stored to the millisecond, as BSON keeps them, and must come back in UTC
+func checkExportedFields(t *testing.T, w exportWrite, imported entities.ValidatedConfig) {
+	t.Helper()
+	checkTime := func(field string, got, want *time.Time) {
+		t.Helper()
+		if want == nil {
+			if got != nil {
+				t.Errorf("%s %s: got %s, want none", w.scope.String(), field, got)
+			}
+			return
+		}
+		truncated := want.Truncate(time.Millisecond)
+		if got == nil || !got.Equal(truncated) || got.Location() != time.UTC {
+			t.Errorf("%s %s: got %v, want %s", w.scope.String(), field, got, truncated)
+		}
+	}
+
+	wantMeta := w.config.(entities.MetaConfig).GetMeta()
+	gotMeta := imported.(entities.MetaConfig).GetMeta()
+	checkTime("changed_at", &gotMeta.ChangedAt, &wantMeta.ChangedAt)
+	checkTime("effective_from", gotMeta.EffectiveFrom, wantMeta.EffectiveFrom)
+	checkTime("effective_until", gotMeta.EffectiveUntil, wantMeta.EffectiveUntil)
+
+	if want, ok := w.config.(*entities.OtherConfig); ok {
+		if got := imported.(*entities.OtherConfig).AFloat; got != want.AFloat {
+			t.Errorf("%s a_float: got %v, want %v", w.scope.String(), got, want.AFloat)
+		}
+	}
+}
+
+func TestDecodeConfigTreeRefusesInvalidFiles(t *testing.T) {
+	h := entities.DefaultHierarchy()
+	for name, file := range map[string]string{
+		"a type not allowed at its level": "corporate: \"1\"\nvenues:\n- venue: \"2\"\n  vendors:\n  - vendor: \"3\"\n    configs:\n      cloud_cart: {meta: {enabled: true}}\n",
+		"an unknown field":                "corporate: \"1\"\nconfigs:\n  cloud_cart: {enable_everything: true}\n",
+		"an unregistered config type":     "corporate: \"1\"\nconfigs:\n  no_such_config: {}\n",
+		"a venue listed twice":            "corporate: \"1\"\nvenues:\n- venue: \"2\"\n- venue: \"2\"\n",
+		"not a tree":                      "[corporate, \"1\"]\n",
+	} {
+		if _, err := DecodeConfigTree(h, []byte(file)); !errors.As(err, &ErrInvalidConfigTree{}) {
+			t.Errorf("%s: got %v, want ErrInvalidConfigTree", name, err)
+		}
+	}
+}
+
+func TestImportRefusesInvalidConfigs(t *testing.T) {
+	ctx := context.Background()
+	configRepo := NewMemoryRepo()
+	unstamped, err := DecodeConfigTree(configRepo.Hierarchy(), []byte("corporate: \"1\"\nconfigs:\n  cloud_cart: {meta: {enabled: true}}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := configRepo.Import(ctx, unstamped, ""); !errors.As(err, &ErrInvalidConfigTree{}) {
+		t.Errorf("import without changed_by: got %v, want ErrInvalidConfigTree", err)
+	}
+	if got := mustActive(t, configRepo, CorporateScope("1"), entities.CONFIG_TYPE_DEMO_CONFIG); got != "" {
+		t.Errorf("a refused import wrote config from %q", got)
+	}
+
+	if _, err := configRepo.Import(ctx, unstamped, "IMPORT"); err != nil {
+		t.Fatalf("import stamped with changed_by: %v", err)
+	}
+	if got := mustActive(t, configRepo, CorporateScope("1"), entities.CONFIG_TYPE_DEMO_CONFIG); got != "IMPORT" {
+		t.Errorf("import stamped config from %q, want IMPORT", got)
+	}
+}
+
+func TestImportStampsWithTheRepositoryClock(t *testing.T) {
+	clock := newTestClock()
+	clock.Advance(time.Hour)
+	configRepo := NewMemoryRepo(WithClock(clock.Now))
+	for _, target := range []struct {
+		name      string
+		repo      ConfigRepository
+		corporate string
+	}{
+		{"MemoryRepo", configRepo, "1"},
+		{"CachedResolver", NewCachedResolver(configRepo), "2"},
+	} {
+		tree, err := DecodeConfigTree(configRepo.Hierarchy(), []byte("corporate: \""+target.corporate+"\"\nconfigs:\n  cloud_cart: {meta: {enabled: true}}\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := target.repo.Import(context.Background(), tree, target.name); err != nil {
+			t.Fatalf("%s: %v", target.name, err)
+		}
+
+		stored, err := configRepo.GetSpecificConfig(context.Background(), CorporateScope(target.corporate), entities.CONFIG_TYPE_DEMO_CONFIG)
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta := stored.(entities.MetaConfig).GetMeta()
+		if meta.ChangedBy != target.name || !meta.ChangedAt.Equal(clock.Now()) {
+			t.Errorf("%s stamped the import as changed by %q at %s, want at %s", target.name, meta.ChangedBy, meta.ChangedAt, clock.Now())
+		}
+		clock.Advance(time.Minute)
+	}
+}
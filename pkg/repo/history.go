@@ -0,0 +1,410 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+)
+
+//HistoryQuery filters and pages GetConfigHistory.  Entries come back newest first; to fetch the next page, pass the
+//Version of the last entry received as BeforeVersion
+type HistoryQuery struct {
+	//Since and Until bound ChangedAt, inclusive and exclusive respectively.  Zero values leave that end open
+	Since time.Time
+	Until time.Time
+	//BeforeVersion only returns versions older than it, if set
+	BeforeVersion int64
+	//Limit defaults to 50 and is capped at 500
+	Limit int
+}
+
+func (q HistoryQuery) limit() int {
+	switch {
+	case q.Limit <= 0:
+		return defaultHistoryLimit
+	case q.Limit > maxHistoryLimit:
+		return maxHistoryLimit
+	default:
+		return q.Limit
+	}
+}
+
+//ConfigHistoryEntry is one recorded write.  Version is the meta.revision the write produced.  Before is nil when the
+//config wasn't set, After when the write deleted it.  IDs are keyed by the hierarchy's ID fields, e.g. corporate_id
+type ConfigHistoryEntry struct {
+	ConfigLevel entities.ConfigLevel     `json:"config_level"`
+	IDs         map[string]string        `json:"ids"`
+	ConfigType  entities.ConfigType      `json:"config_type"`
+	Version     int64                    `json:"version"`
+	Before      entities.ValidatedConfig `json:"before,omitempty"`
+	After       entities.ValidatedConfig `json:"after,omitempty"`
+	ChangedBy   string                   `json:"changed_by"`
+	ChangedAt   time.Time                `json:"changed_at"`
+
+	hierarchy *entities.Hierarchy
+}
+
+//MarshalJSON names the entry's level through the hierarchy it was read in
+func (e ConfigHistoryEntry) MarshalJSON() ([]byte, error) {
+	type entry ConfigHistoryEntry
+	return json.Marshal(struct {
+		ConfigLevel string `json:"config_level"`
+		entry
+	}{ConfigLevel: e.hierarchy.LevelName(e.ConfigLevel), entry: entry(e)})
+}
+
+//ErrHistoryVersionNotFound is returned by RevertConfig when the scope's config has no such version
+type ErrHistoryVersionNotFound struct {
+	ConfigType entities.ConfigType
+	Version    int64
+}
+
+func (e ErrHistoryVersionNotFound) Error() string {
+	return fmt.Sprintf("config type %s has no version %d", e.ConfigType.String(), e.Version)
+}
+
+//historyDocument is the shape of a document in the config_history collection.  Before and After are the config
+//subdocuments as stored, so entries stay readable however the Go types change.  The scope's IDs are inlined under the
+//hierarchy's ID fields, as they are on config documents
+type historyDocument struct {
+	ObjectID    primitive.ObjectID   `bson:"_id,omitempty"`
+	ConfigLevel entities.ConfigLevel `bson:"config_level"`
+	IDs         map[string]string    `bson:",inline"`
+	ConfigType  string               `bson:"config_type"`
+	Version     int64                `bson:"version"`
+	Before      bson.Raw             `bson:"before,omitempty"`
+	After       bson.Raw             `bson:"after,omitempty"`
+	ChangedBy   string               `bson:"changed_by"`
+	ChangedAt   time.Time            `bson:"changed_at"`
+}
+
+//newHistoryEntry records a write of configType over the scope document before (nil if there was none).  config is the
+//value written, or nil for a deletion, which the caller attributes.  The version is one past the revision being
+//replaced, which is what the write stores; see storedRevision for baseRevision
+func newHistoryEntry(h *entities.Hierarchy, scope Scope, configType entities.ConfigType, before bson.Raw, baseRevision int64, config entities.ValidatedConfig) (historyDocument, error) {
+	key := configType.String()
+	entry := historyDocument{
+		ConfigLevel: scope.ConfigLevel,
+		IDs:         scopeIDs(h, scope),
+		ConfigType:  key,
+	}
+
+	if before != nil {
+		if value, err := before.LookupErr(key); err == nil {
+			entry.Before = value.Document()
+		}
+	}
+	revision, err := storedRevision(entry.Before, baseRevision)
+	if err != nil {
+		return historyDocument{}, err
+	}
+	entry.Version = revision + 1
+	if config == nil {
+		return entry, nil
+	}
+
+	after, err := bson.Marshal(withRevision(config, entry.Version))
+	if err != nil {
+		return historyDocument{}, err
+	}
+	entry.After = after
+	//Read back from the marshalled value, so ChangedAt has the precision it is stored with
+	var stored struct {
+		Meta entities.ConfigMeta `bson:"meta"`
+	}
+	if err := bson.Unmarshal(after, &stored); err != nil {
+		return historyDocument{}, err
+	}
+	entry.ChangedBy = stored.Meta.ChangedBy
+	entry.ChangedAt = stored.Meta.ChangedAt
+
+	return entry, nil
+}
+
+//newDeletionEntry records configType being removed from the scope document before
+func newDeletionEntry(h *entities.Hierarchy, scope Scope, configType entities.ConfigType, before bson.Raw, baseRevision int64, changedBy string, changedAt time.Time) (historyDocument, error) {
+	entry, err := newHistoryEntry(h, scope, configType, before, baseRevision, nil)
+	if err != nil {
+		return historyDocument{}, err
+	}
+	entry.ChangedBy = changedBy
+	//Truncated as bson would, to match the entries of other writes
+	entry.ChangedAt = changedAt.Truncate(time.Millisecond)
+
+	return entry, nil
+}
+
+//deletedRevisionsField holds, per config key, the revision of the config's deletion, so a config that is deleted and
+//set again carries on numbering from where it was rather than reusing versions.  See makeDeleteConfigUpdate
+const deletedRevisionsField = "deleted_revisions"
+
+func deletedRevisionField(key string) string {
+	return deletedRevisionsField + "." + key
+}
+
+//deletedRevision is the revision the key config's deletion left on the scope document doc, or baseRevision if there is
+//none, as the inner $ifNull in nextRevision
+func deletedRevision(doc bson.Raw, key string, baseRevision int64) int64 {
+	if doc == nil {
+		return baseRevision
+	}
+	value, err := doc.LookupErr(deletedRevisionsField, key)
+	if err != nil {
+		return baseRevision
+	}
+	if revision, ok := revisionValue(value); ok {
+		return revision
+	}
+
+	return baseRevision
+}
+
+//revisionValue reads a revision, which the server may have stored as an int32 or an int64
+func revisionValue(value bson.RawValue) (int64, bool) {
+	if revision, ok := value.Int64OK(); ok {
+		return revision, true
+	}
+	if revision, ok := value.Int32OK(); ok {
+		return int64(revision), true
+	}
+
+	return 0, false
+}
+
+//storedRevision is the revision a write replaces: meta.revision of the stored config subdocument or, if it has none,
+//baseRevision, which is what the $ifNull in nextRevision falls back to
+func storedRevision(config bson.Raw, baseRevision int64) (int64, error) {
+	if config == nil {
+		return baseRevision, nil
+	}
+	var stored struct {
+		Meta struct {
+			Revision *int64 `bson:"revision"`
+		} `bson:"meta"`
+	}
+	if err := bson.Unmarshal(config, &stored); err != nil {
+		return 0, err
+	}
+	if stored.Meta.Revision == nil {
+		return baseRevision, nil
+	}
+
+	return *stored.Meta.Revision, nil
+}
+
+//revisionOfConfig is the revision config was read at, 0 if it isn't set
+func revisionOfConfig(config entities.ValidatedConfig) int64 {
+	if meta, ok := config.(entities.MetaConfig); ok {
+		return meta.GetMeta().Revision
+	}
+
+	return 0
+}
+
+//withRevision returns a copy of config carrying revision
+func withRevision(config entities.ValidatedConfig, revision int64) entities.ValidatedConfig {
+	stamped := entities.CloneConfig(config)
+	if meta, ok := stamped.(entities.MetaConfig); ok {
+		meta.GetMeta().Revision = revision
+	}
+
+	return stamped
+}
+
+//matches is makeHistoryFilter's scope and type condition
+func (d historyDocument) matches(h *entities.Hierarchy, scope Scope, configType entities.ConfigType) bool {
+	if d.ConfigLevel != scope.ConfigLevel || d.ConfigType != configType.String() {
+		return false
+	}
+	for level := entities.ConfigLevel(1); level <= scope.ConfigLevel; level++ {
+		if d.IDs[h.IDField(level)] != scope.ID(level) {
+			return false
+		}
+	}
+
+	return true
+}
+
+//scopeIDs maps the ID field of each level in scope's chain to its ID
+func scopeIDs(h *entities.Hierarchy, scope Scope) map[string]string {
+	ids := make(map[string]string, scope.ConfigLevel)
+	for level := entities.ConfigLevel(1); level <= scope.ConfigLevel; level++ {
+		ids[h.IDField(level)] = scope.ID(level)
+	}
+
+	return ids
+}
+
+func (d historyDocument) decode(h *entities.Hierarchy) (ConfigHistoryEntry, error) {
+	configType, ok := entities.LookupConfigKey(d.ConfigType)
+	if !ok {
+		return ConfigHistoryEntry{}, fmt.Errorf("unsupported config type: %s", d.ConfigType)
+	}
+
+	entry := ConfigHistoryEntry{
+		ConfigLevel: d.ConfigLevel,
+		IDs:         d.IDs,
+		ConfigType:  configType.ID,
+		Version:     d.Version,
+		ChangedBy:   d.ChangedBy,
+		ChangedAt:   d.ChangedAt,
+		hierarchy:   h,
+	}
+	if d.Before != nil {
+		entry.Before = entities.NewConfig(configType.ID)
+		if err := bson.Unmarshal(d.Before, entry.Before); err != nil {
+			return ConfigHistoryEntry{}, err
+		}
+	}
+	if d.After != nil {
+		entry.After = entities.NewConfig(configType.ID)
+		if err := bson.Unmarshal(d.After, entry.After); err != nil {
+			return ConfigHistoryEntry{}, err
+		}
+	}
+
+	return entry, nil
+}
+
+//revertedConfig is the config a revert to entry writes: its After value, re-attributed to changedBy now.  Reverting to a
+//deletion is refused; DeleteConfig does that
+func revertedConfig(entry ConfigHistoryEntry, changedBy string, now time.Time) (entities.ValidatedConfig, error) {
+	if entry.After == nil {
+		return nil, fmt.Errorf("version %d of config type %s is a deletion", entry.Version, entry.ConfigType.String())
+	}
+	config := entities.CloneConfig(entry.After)
+	if meta, ok := config.(entities.MetaConfig); ok {
+		meta.GetMeta().ChangedBy = changedBy
+		meta.GetMeta().ChangedAt = now
+	}
+
+	return config, nil
+}
+
+//appendHistory records a write.  History isn't written in the config write's transaction (there is none; a standalone
+//server can't run one), so the config write has already happened by now and a failure or crash here leaves a gap in
+//the history rather than undoing it.  The entry is upserted on its scope, type and version, which scope_type_version
+//keeps unique, so recording the same write twice is a no-op rather than a duplicate version
+func (r *MDBRepo) appendHistory(ctx context.Context, entry historyDocument) error {
+	history, err := r.historyCollection(ctx)
+	if err != nil {
+		return err
+	}
+	filter, err := makeHistoryEntryFilter(r.hierarchy, entry)
+	if err != nil {
+		return err
+	}
+	if _, err := history.UpdateOne(ctx, filter, bson.M{"$setOnInsert": entry}, options.Update().SetUpsert(true)); err != nil {
+		return fmt.Errorf("recording config history: %w", err)
+	}
+
+	return nil
+}
+
+//latestHistoryVersion is the version of the config's last recorded write, 0 if there is none.  Writes only need it to
+//number a config whose scope document doesn't exist, e.g. after DeleteScope; otherwise the document itself says
+func (r *MDBRepo) latestHistoryVersion(ctx context.Context, scope Scope, configType entities.ConfigType) (int64, error) {
+	filter, err := makeHistoryFilter(r.hierarchy, scope, configType, HistoryQuery{})
+	if err != nil {
+		return 0, err
+	}
+
+	findOpts := options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}}).SetProjection(bson.M{"version": 1})
+	var latest struct {
+		Version int64 `bson:"version"`
+	}
+	history, err := r.historyCollection(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := history.FindOne(ctx, filter, findOpts).Decode(&latest); err != nil && err != mongo.ErrNoDocuments {
+		return 0, err
+	}
+
+	return latest.Version, nil
+}
+
+func (r *MDBRepo) GetConfigHistory(ctx context.Context, scope Scope, configType entities.ConfigType, query HistoryQuery) ([]ConfigHistoryEntry, error) {
+	if entities.NewConfig(configType) == nil {
+		return nil, fmt.Errorf("unsupported config type")
+	}
+	filter, err := makeHistoryFilter(r.hierarchy, scope, configType, query)
+	if err != nil {
+		return nil, err
+	}
+
+	findOpts := options.Find().SetSort(bson.D{{Key: "version", Value: -1}}).SetLimit(int64(query.limit()))
+	history, err := r.historyCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := history.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer csr.Close(ctx)
+
+	entries := []ConfigHistoryEntry{}
+	for csr.Next(ctx) {
+		var doc historyDocument
+		if err := csr.Decode(&doc); err != nil {
+			return nil, err
+		}
+		entry, err := doc.decode(r.hierarchy)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := csr.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (r *MDBRepo) RevertConfig(ctx context.Context, scope Scope, configType entities.ConfigType, toVersion int64, changedBy string) (entities.ValidatedConfig, error) {
+	if entities.NewConfig(configType) == nil {
+		return nil, fmt.Errorf("unsupported config type")
+	}
+	filter, err := makeHistoryFilter(r.hierarchy, scope, configType, HistoryQuery{})
+	if err != nil {
+		return nil, err
+	}
+	filter["version"] = toVersion
+
+	history, err := r.historyCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var doc historyDocument
+	if err := history.FindOne(ctx, filter).Decode(&doc); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrHistoryVersionNotFound{ConfigType: configType, Version: toVersion}
+		}
+		return nil, err
+	}
+	entry, err := doc.decode(r.hierarchy)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := revertedConfig(entry, changedBy, r.now())
+	if err != nil {
+		return nil, err
+	}
+
+	return r.SetConfig(ctx, scope, config)
+}
@@ -0,0 +1,240 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//duplicateKeyCode is the server error code for a unique index violation
+const duplicateKeyCode = 11000
+
+//expectedIndex is an index the repository's queries rely on
+type expectedIndex struct {
+	name   string
+	keys   bson.D
+	unique bool
+}
+
+//configIndexes back the configs collection.  scope_unique is what stops concurrent upserts creating two documents for
+//one scope; documents above the bottom level index the ID fields they lack as null, so they are covered too.
+//active_lookup serves the $or branches of the active match, which all lead with the top level's ID field
+func configIndexes(h *entities.Hierarchy) []expectedIndex {
+	idKeys := hierarchyIndexKeys(h)
+
+	return []expectedIndex{
+		{
+			name:   "scope_unique",
+			keys:   append(bson.D{{Key: "config_level", Value: 1}}, idKeys...),
+			unique: true,
+		},
+		{
+			name: "active_lookup",
+			keys: append(append(bson.D{}, idKeys...), bson.E{Key: "config_level", Value: 1}),
+		},
+	}
+}
+
+//historyIndexes serve GetConfigHistory, its version paging and latestHistoryVersion.  scope_type_version is unique, so
+//appendHistory's upsert can't record a version twice; one created before it was unique shows up as Mismatched
+func historyIndexes(h *entities.Hierarchy) []expectedIndex {
+	keys := append(bson.D{{Key: "config_level", Value: 1}}, hierarchyIndexKeys(h)...)
+	keys = append(keys, bson.E{Key: "config_type", Value: 1}, bson.E{Key: "version", Value: -1})
+
+	return []expectedIndex{{name: "scope_type_version", keys: keys, unique: true}}
+}
+
+//hierarchyIndexKeys is an ascending key on every level's ID field, top level first
+func hierarchyIndexKeys(h *entities.Hierarchy) bson.D {
+	var keys bson.D
+	for _, level := range h.Levels() {
+		keys = append(keys, bson.E{Key: level.IDField, Value: 1})
+	}
+
+	return keys
+}
+
+//IndexDrift compares one collection's indexes against what the repository expects.  Missing indexes are created by
+//EnsureIndexes; Mismatched ones (same name, different keys or uniqueness) and Unexpected ones are only reported, since
+//fixing them means dropping an index
+type IndexDrift struct {
+	Collection string   `json:"collection"`
+	Created    []string `json:"created,omitempty"`
+	Missing    []string `json:"missing,omitempty"`
+	Mismatched []string `json:"mismatched,omitempty"`
+	Unexpected []string `json:"unexpected,omitempty"`
+}
+
+//InSync reports whether the collection had exactly the expected indexes, or does now that the missing ones were created
+func (d IndexDrift) InSync() bool {
+	return len(d.Missing) == 0 && len(d.Mismatched) == 0 && len(d.Unexpected) == 0
+}
+
+//EnsureIndexes creates any missing indexes on the configs and history collections and reports drift.  It is safe to
+//run on every start up.  Creating scope_unique fails if the collection already holds duplicate scope documents; those
+//have to be merged by hand first
+func (r *MDBRepo) EnsureIndexes(ctx context.Context) ([]IndexDrift, error) {
+	return r.syncIndexes(ctx, true)
+}
+
+//CheckIndexes is EnsureIndexes without creating anything
+func (r *MDBRepo) CheckIndexes(ctx context.Context) ([]IndexDrift, error) {
+	return r.syncIndexes(ctx, false)
+}
+
+func (r *MDBRepo) syncIndexes(ctx context.Context, create bool) ([]IndexDrift, error) {
+	configs, err := r.configCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	history, err := r.historyCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var report []IndexDrift
+	for _, target := range []struct {
+		collection *mongo.Collection
+		indexes    []expectedIndex
+	}{
+		{configs, configIndexes(r.hierarchy)},
+		{history, historyIndexes(r.hierarchy)},
+	} {
+		drift, err := syncCollectionIndexes(ctx, target.collection, target.indexes, create)
+		if err != nil {
+			return report, fmt.Errorf("indexes on %s: %w", target.collection.Name(), err)
+		}
+		report = append(report, drift)
+	}
+
+	return report, nil
+}
+
+//existingIndex is an index as the server lists it
+type existingIndex struct {
+	Name   string `bson:"name"`
+	Key    bson.D `bson:"key"`
+	Unique bool   `bson:"unique"`
+}
+
+func syncCollectionIndexes(ctx context.Context, collection *mongo.Collection, expected []expectedIndex, create bool) (IndexDrift, error) {
+	drift := IndexDrift{Collection: collection.Name()}
+
+	csr, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return drift, err
+	}
+	var existing []existingIndex
+	if err := csr.All(ctx, &existing); err != nil {
+		return drift, err
+	}
+
+	var models []mongo.IndexModel
+	for _, want := range classifyIndexes(&drift, existing, expected) {
+		if !create {
+			drift.Missing = append(drift.Missing, want.name)
+			continue
+		}
+		models = append(models, mongo.IndexModel{Keys: want.keys, Options: options.Index().SetName(want.name).SetUnique(want.unique)})
+	}
+	if len(models) > 0 {
+		created, err := collection.Indexes().CreateMany(ctx, models)
+		if err != nil {
+			return drift, err
+		}
+		drift.Created = created
+	}
+
+	return drift, nil
+}
+
+//classifyIndexes adds the existing indexes that differ from the expected ones of their name to drift.Mismatched, and
+//those with names not expected at all to drift.Unexpected.  It returns the expected indexes that don't exist
+func classifyIndexes(drift *IndexDrift, existing []existingIndex, expected []expectedIndex) []expectedIndex {
+	found := map[string]bool{}
+	for _, index := range existing {
+		if index.Name == "_id_" {
+			continue
+		}
+		want, ok := findExpectedIndex(expected, index.Name)
+		switch {
+		case !ok:
+			drift.Unexpected = append(drift.Unexpected, index.Name)
+		case index.Unique != want.unique || !sameKeys(index.Key, want.keys):
+			drift.Mismatched = append(drift.Mismatched, index.Name)
+		}
+		found[index.Name] = true
+	}
+
+	var missing []expectedIndex
+	for _, want := range expected {
+		if !found[want.name] {
+			missing = append(missing, want)
+		}
+	}
+
+	return missing
+}
+
+func findExpectedIndex(expected []expectedIndex, name string) (expectedIndex, bool) {
+	for _, index := range expected {
+		if index.name == name {
+			return index, true
+		}
+	}
+
+	return expectedIndex{}, false
+}
+
+//sameKeys compares index key documents in order.  The server may hand directions back as doubles, so values are
+//compared as numbers
+func sameKeys(a, b bson.D) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key || keyDirection(a[i].Value) != keyDirection(b[i].Value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func keyDirection(value interface{}) float64 {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	default:
+		return 0
+	}
+}
+
+//isDuplicateKeyError reports whether err is a scope_unique (or any unique index) violation
+func isDuplicateKeyError(err error) bool {
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) {
+		return commandErr.Code == duplicateKeyCode
+	}
+	var writeErr mongo.WriteException
+	if errors.As(err, &writeErr) {
+		for _, we := range writeErr.WriteErrors {
+			if we.Code == duplicateKeyCode {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,179 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestSameKeys(t *testing.T) {
+	keys := bson.D{{Key: "config_level", Value: 1}, {Key: "version", Value: -1}}
+	for _, test := range []struct {
+		name  string
+		other bson.D
+		same  bool
+	}{
+		{"the same ints", bson.D{{Key: "config_level", Value: 1}, {Key: "version", Value: -1}}, true},
+		{"int32 directions", bson.D{{Key: "config_level", Value: int32(1)}, {Key: "version", Value: int32(-1)}}, true},
+		{"int64 directions", bson.D{{Key: "config_level", Value: int64(1)}, {Key: "version", Value: int64(-1)}}, true},
+		{"double directions", bson.D{{Key: "config_level", Value: 1.0}, {Key: "version", Value: -1.0}}, true},
+		{"a flipped direction", bson.D{{Key: "config_level", Value: 1}, {Key: "version", Value: 1.0}}, false},
+		{"another order", bson.D{{Key: "version", Value: -1}, {Key: "config_level", Value: 1}}, false},
+		{"another field", bson.D{{Key: "config_level", Value: 1}, {Key: "revision", Value: -1}}, false},
+		{"a prefix", bson.D{{Key: "config_level", Value: 1}}, false},
+		{"a key more", append(append(bson.D{}, keys...), bson.E{Key: "corporate_id", Value: 1}), false},
+	} {
+		if got := sameKeys(keys, test.other); got != test.same {
+			t.Errorf("%s: sameKeys is %t, want %t", test.name, got, test.same)
+		}
+		if got := sameKeys(test.other, keys); got != test.same {
+			t.Errorf("%s: sameKeys the other way round is %t, want %t", test.name, got, test.same)
+		}
+	}
+
+	for _, value := range []interface{}{1, int32(1), int64(1), 1.0} {
+		if got := keyDirection(value); got != 1 {
+			t.Errorf("keyDirection(%T) is %v, want 1", value, got)
+		}
+	}
+	if got := keyDirection("hashed"); got != 0 {
+		t.Errorf("keyDirection of a string is %v, want 0", got)
+	}
+}
+
+func TestClassifyIndexes(t *testing.T) {
+	expected := configIndexes(entities.DefaultHierarchy())
+	scopeUnique, activeLookup := expected[0], expected[1]
+	//asListed is an expected index as the server would list it, with its directions as doubles
+	asListed := func(index expectedIndex) existingIndex {
+		keys := bson.D{}
+		for _, key := range index.keys {
+			keys = append(keys, bson.E{Key: key.Key, Value: keyDirection(key.Value)})
+		}
+		return existingIndex{Name: index.name, Key: keys, Unique: index.unique}
+	}
+	id := existingIndex{Name: "_id_", Key: bson.D{{Key: "_id", Value: 1}}}
+
+	for _, test := range []struct {
+		name       string
+		existing   []existingIndex
+		missing    []string
+		mismatched []string
+		unexpected []string
+	}{
+		{
+			name:     "in sync",
+			existing: []existingIndex{id, asListed(scopeUnique), asListed(activeLookup)},
+		},
+		{
+			name:    "an empty collection",
+			missing: []string{"scope_unique", "active_lookup"},
+		},
+		{
+			name:     "one missing",
+			existing: []existingIndex{id, asListed(activeLookup)},
+			missing:  []string{"scope_unique"},
+		},
+		{
+			name:       "scope_unique without unique",
+			existing:   []existingIndex{id, {Name: "scope_unique", Key: scopeUnique.keys}, asListed(activeLookup)},
+			mismatched: []string{"scope_unique"},
+		},
+		{
+			name:       "active_lookup on other keys",
+			existing:   []existingIndex{id, asListed(scopeUnique), {Name: "active_lookup", Key: bson.D{{Key: "corporate_id", Value: 1}}}},
+			mismatched: []string{"active_lookup"},
+		},
+		{
+			name:       "an index nobody asked for",
+			existing:   []existingIndex{id, asListed(scopeUnique), asListed(activeLookup), {Name: "changed_by_1", Key: bson.D{{Key: "changed_by", Value: 1}}}},
+			unexpected: []string{"changed_by_1"},
+		},
+		{
+			name:       "all at once",
+			existing:   []existingIndex{{Name: "scope_unique", Key: activeLookup.keys, Unique: true}, {Name: "scope", Key: scopeUnique.keys, Unique: true}},
+			missing:    []string{"active_lookup"},
+			mismatched: []string{"scope_unique"},
+			unexpected: []string{"scope"},
+		},
+	} {
+		drift := IndexDrift{}
+		var missing []string
+		for _, index := range classifyIndexes(&drift, test.existing, expected) {
+			missing = append(missing, index.name)
+		}
+		if fmt.Sprint(missing) != fmt.Sprint(test.missing) || fmt.Sprint(drift.Mismatched) != fmt.Sprint(test.mismatched) || fmt.Sprint(drift.Unexpected) != fmt.Sprint(test.unexpected) {
+			t.Errorf("%s: got missing %v, mismatched %v and unexpected %v, want %v, %v and %v", test.name, missing, drift.Mismatched, drift.Unexpected, test.missing, test.mismatched, test.unexpected)
+		}
+	}
+}
+
+//checkDrift compares the configs and history collections' drift with want, ignoring the collection names
+func checkDrift(t *testing.T, report []IndexDrift, want ...IndexDrift) {
+	t.Helper()
+	if len(report) != len(want) {
+		t.Fatalf("got %d collections, want %d", len(report), len(want))
+	}
+	for i := range report {
+		report[i].Collection = ""
+		if fmt.Sprintf("%+v", report[i]) != fmt.Sprintf("%+v", want[i]) {
+			t.Errorf("collection %d: got %+v, want %+v", i, report[i], want[i])
+		}
+	}
+}
+
+func TestMDBRepoEnsureIndexes(t *testing.T) {
+	ctx := context.Background()
+	configRepo := newTestMDBRepo(t, newTestMongoClient(t))
+
+	//newTestMDBRepo ran it once; running it again creates nothing
+	for i := 0; i < 2; i++ {
+		report, err := configRepo.EnsureIndexes(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkDrift(t, report, IndexDrift{}, IndexDrift{})
+	}
+	report, err := configRepo.CheckIndexes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDrift(t, report, IndexDrift{}, IndexDrift{})
+
+	configs, err := configRepo.configCollection(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scopeUnique := configIndexes(configRepo.hierarchy)[0]
+	if _, err := configs.Indexes().DropOne(ctx, scopeUnique.name); err != nil {
+		t.Fatal(err)
+	}
+	report, err = configRepo.CheckIndexes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDrift(t, report, IndexDrift{Missing: []string{"scope_unique"}}, IndexDrift{})
+
+	//scope_unique as it would be if created by hand without unique: reported, not replaced
+	if _, err := configs.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: scopeUnique.keys, Options: options.Index().SetName(scopeUnique.name)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := configs.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "changed_by", Value: 1}}, Options: options.Index().SetName("changed_by_1")}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		report, err = configRepo.EnsureIndexes(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkDrift(t, report, IndexDrift{Mismatched: []string{"scope_unique"}, Unexpected: []string{"changed_by_1"}}, IndexDrift{})
+		if report[0].InSync() {
+			t.Error("the configs collection is reported in sync")
+		}
+	}
+}
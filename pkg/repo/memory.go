@@ -0,0 +1,672 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var _ ConfigRepository = (*MemoryRepo)(nil)
+
+//MemoryRepo is an in-process ConfigRepository.  Configs are stored as marshalled BSON, exactly as MDBRepo would write
+//them, so round trips behave the same way (time precision, omitted fields, etc.)
+type MemoryRepo struct {
+	mu        sync.RWMutex
+	documents []*memoryDocument
+	//history is the config_history collection, in insertion order
+	history   []historyDocument
+	now       func() time.Time
+	hierarchy *entities.Hierarchy
+	options   repoOptions
+	//tenants holds a repository per location the tenant resolver picks, if there is one
+	tenantsMu sync.Mutex
+	tenants   map[string]*MemoryRepo
+}
+
+//memoryDocument mirrors a single document in the configs collection.  Like an upsert through makeUpsertConfigFilter,
+//a level's ID field only exists on documents at or below that level, so scope is normalized
+type memoryDocument struct {
+	scope   Scope
+	configs map[string]bson.Raw
+	//deletedRevisions is the deleted_revisions field; see makeDeleteConfigUpdate
+	deletedRevisions map[string]int64
+}
+
+func NewMemoryRepo(opts ...RepoOption) *MemoryRepo {
+	repoOpts := newRepoOptions(opts)
+
+	return &MemoryRepo{now: repoOpts.now, hierarchy: repoOpts.hierarchy, options: repoOpts}
+}
+
+//tenant is the repository holding the call's documents: r itself without a tenant resolver, otherwise one per database
+//and collection the resolver picks, so tenants are kept apart as they are by MDBRepo
+func (r *MemoryRepo) tenant(ctx context.Context) (*MemoryRepo, error) {
+	if r.options.tenantResolver == nil {
+		return r, nil
+	}
+	key, err := r.options.tenantKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.tenantsMu.Lock()
+	defer r.tenantsMu.Unlock()
+	tenant, ok := r.tenants[key]
+	if !ok {
+		tenantOpts := r.options
+		tenantOpts.tenantResolver = nil
+		tenant = &MemoryRepo{now: r.now, hierarchy: r.hierarchy, options: tenantOpts}
+		if r.tenants == nil {
+			r.tenants = map[string]*MemoryRepo{}
+		}
+		r.tenants[key] = tenant
+	}
+
+	return tenant, nil
+}
+
+func (r *MemoryRepo) Hierarchy() *entities.Hierarchy {
+	return r.hierarchy
+}
+
+func (r *MemoryRepo) tenantKey(ctx context.Context) (string, error) {
+	return r.options.tenantKey(ctx)
+}
+
+func (r *MemoryRepo) SetConfig(ctx context.Context, scope Scope, config entities.ValidatedConfig) (entities.ValidatedConfig, error) {
+	return r.setConfig(ctx, scope, config, nil)
+}
+
+func (r *MemoryRepo) SetConfigIfRevision(ctx context.Context, scope Scope, config entities.ValidatedConfig, expectedRevision int64) (entities.ValidatedConfig, error) {
+	return r.setConfig(ctx, scope, config, &expectedRevision)
+}
+
+func (r *MemoryRepo) setConfig(ctx context.Context, scope Scope, config entities.ValidatedConfig, expectedRevision *int64) (entities.ValidatedConfig, error) {
+	r, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.hierarchy.ValidateConfigLevel(scope.ConfigLevel, config.GetConfigType()); err != nil {
+		return nil, err
+	}
+	if err := entities.ValidateConfig(config); err != nil {
+		return nil, err
+	}
+	if _, err := makeUpsertConfigFilter(r.hierarchy, scope); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	doc := r.findScopeDocument(scope)
+	var before bson.Raw
+	if doc != nil {
+		var err error
+		if before, err = doc.raw(r.hierarchy); err != nil {
+			r.mu.Unlock()
+			return nil, err
+		}
+	}
+	if expectedRevision != nil {
+		//Mirrors makeRevisionFilter: a config that isn't set is at revision 0
+		current, err := storedRevision(doc.configRaw(config.GetConfigType()), 0)
+		if err != nil {
+			r.mu.Unlock()
+			return nil, err
+		}
+		if current != *expectedRevision {
+			r.mu.Unlock()
+			return nil, ErrRevisionConflict{ConfigType: config.GetConfigType(), Expected: *expectedRevision, Actual: current}
+		}
+	}
+	//As in MDBRepo.setConfig, only a scope document that doesn't exist yet is numbered from the history
+	baseRevision := deletedRevision(before, config.GetConfigType().String(), 0)
+	if doc == nil {
+		baseRevision = r.latestHistoryVersion(scope, config.GetConfigType())
+	}
+	entry, err := newHistoryEntry(r.hierarchy, scope, config.GetConfigType(), before, baseRevision, config)
+	if err != nil {
+		r.mu.Unlock()
+		return nil, err
+	}
+	if doc == nil {
+		doc = newMemoryDocument(scope)
+		r.documents = append(r.documents, doc)
+	}
+	//entry.After is exactly what makeSetConfigUpdate leaves in the subdocument
+	doc.configs[config.GetConfigType().String()] = entry.After
+	r.history = append(r.history, entry)
+	r.mu.Unlock()
+
+	return r.GetSpecificConfig(ctx, scope, config.GetConfigType())
+}
+
+func (r *MemoryRepo) GetSpecificConfig(ctx context.Context, scope Scope, configType entities.ConfigType) (entities.ValidatedConfig, error) {
+	r, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := makeUpsertConfigFilter(r.hierarchy, scope); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	doc := r.findScopeDocument(scope)
+	if doc == nil {
+		return emptyConfigForType(r.hierarchy, scope.ConfigLevel, configType), nil
+	}
+
+	return doc.decode(r.hierarchy, scope.ConfigLevel, configType)
+}
+
+//Mirrors makeGetActiveConfigPipeline: match the scope chain, rank by config_level, take the first unless the runner-up
+//is at the same level
+func (r *MemoryRepo) GetActiveConfig(ctx context.Context, scope Scope, configType entities.ConfigType, opts ...ActiveOption) (entities.ValidatedConfig, error) {
+	r, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	activeOpts := newActiveOptions(r.now, opts)
+	if _, err := makeGetActiveConfigMatch(r.hierarchy, scope, configType, activeOpts.asOf); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	winner, err := activeWinner(r.activeCandidates(scope, configType, true, activeOpts.asOf), configType)
+	if err != nil {
+		return nil, err
+	}
+	if winner == nil {
+		return emptyConfigForType(r.hierarchy, scope.ConfigLevel, configType), nil
+	}
+
+	return decodeActive(r.hierarchy, winner, scope, configType)
+}
+
+func (r *MemoryRepo) ExplainActiveConfig(ctx context.Context, scope Scope, configType entities.ConfigType, opts ...ActiveOption) (*ActiveConfigExplanation, error) {
+	r, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	activeOpts := newActiveOptions(r.now, opts)
+	if _, err := makeExplainActiveConfigPipeline(r.hierarchy, scope, configType); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var docs []bson.Raw
+	for _, doc := range r.activeCandidates(scope, configType, false, activeOpts.asOf) {
+		raw, err := doc.raw(r.hierarchy)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, raw)
+	}
+
+	return explainActiveCandidates(r.hierarchy, scope, configType, docs, activeOpts.asOf)
+}
+
+//activeCandidates is the match and sort of makeActiveCandidatesMatch and makeGetActiveConfigSort.  Callers must hold mu
+func (r *MemoryRepo) activeCandidates(scope Scope, configType entities.ConfigType, requireEnabled bool, asOf time.Time) []*memoryDocument {
+	return selectActiveCandidates(r.documents, scope, configType, requireEnabled, asOf)
+}
+
+//selectActiveCandidates applies the active match and sort to documents.  MDBRepo uses it too, to rank a batch of
+//documents fetched for many scopes at once.  The sort is stable, so duplicates of a scope keep their stored order, as
+//they do under makeGetActiveConfigSort's _id tie break
+func selectActiveCandidates(documents []*memoryDocument, scope Scope, configType entities.ConfigType, requireEnabled bool, asOf time.Time) []*memoryDocument {
+	var candidates []*memoryDocument
+	for _, doc := range documents {
+		if requireEnabled && !doc.isActiveAt(configType, asOf) {
+			continue
+		}
+		if doc.inScopeChain(scope) {
+			candidates = append(candidates, doc)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].scope.ConfigLevel > candidates[j].scope.ConfigLevel
+	})
+
+	return candidates
+}
+
+//activeWinner is the first of the ranked active candidates, or nil if there are none.  A runner-up at the winner's
+//level is a second document for the same scope, and which of the two wins would be arbitrary
+func activeWinner(candidates []*memoryDocument, configType entities.ConfigType) (*memoryDocument, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	winner := candidates[0]
+	if len(candidates) > 1 && candidates[1].scope.ConfigLevel == winner.scope.ConfigLevel {
+		return nil, ErrAmbiguousScope{Scope: winner.scope, ConfigType: configType}
+	}
+
+	return winner, nil
+}
+
+func (r *MemoryRepo) GetActiveConfigsForScopes(ctx context.Context, configType entities.ConfigType, scopes []Scope, opts ...ActiveOption) (map[Scope]entities.ValidatedConfig, error) {
+	r, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if entities.NewConfig(configType) == nil {
+		return nil, fmt.Errorf("unsupported config type")
+	}
+	if len(scopes) == 0 {
+		return map[Scope]entities.ValidatedConfig{}, nil
+	}
+	if _, err := makeBatchActiveCandidatesFilter(r.hierarchy, scopes, configType, time.Time{}); err != nil {
+		return nil, err
+	}
+	activeOpts := newActiveOptions(r.now, opts)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return activeConfigsForScopes(r.hierarchy, r.documents, configType, scopes, activeOpts.asOf)
+}
+
+func (r *MemoryRepo) GetActiveBundle(ctx context.Context, scope Scope, opts ...ActiveOption) (*ActiveBundle, error) {
+	r, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := makeUpsertConfigFilter(r.hierarchy, scope); err != nil {
+		return nil, err
+	}
+	activeOpts := newActiveOptions(r.now, opts)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return activeBundle(r.hierarchy, r.documents, scope, activeOpts.asOf)
+}
+
+func (r *MemoryRepo) GetResolvedConfig(ctx context.Context, scope Scope, configType entities.ConfigType, opts ...ActiveOption) (*entities.ResolvedConfig, error) {
+	r, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	activeOpts := newActiveOptions(r.now, opts)
+	if entities.NewConfig(configType) == nil {
+		return nil, fmt.Errorf("unsupported config type")
+	}
+	if _, err := makeScopeChainFilter(r.hierarchy, scope); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	//Every document in the chain, as makeScopeChainFilter finds them, so duplicates reach resolveLayers
+	var layers []entities.ConfigLayer
+	for _, doc := range r.documents {
+		if !doc.inScopeChain(scope) {
+			continue
+		}
+		if _, ok := doc.configs[configType.String()]; !ok {
+			continue
+		}
+		config, err := doc.decode(r.hierarchy, doc.scope.ConfigLevel, configType)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, entities.ConfigLayer{ConfigLevel: doc.scope.ConfigLevel, Config: config})
+	}
+
+	return resolveLayers(r.hierarchy, scope.normalized(), configType, layers, activeOpts.asOf)
+}
+
+//GetConfigHistory applies makeHistoryFilter's conditions to the recorded history, newest first
+func (r *MemoryRepo) GetConfigHistory(ctx context.Context, scope Scope, configType entities.ConfigType, query HistoryQuery) ([]ConfigHistoryEntry, error) {
+	r, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if entities.NewConfig(configType) == nil {
+		return nil, fmt.Errorf("unsupported config type")
+	}
+	if _, err := makeHistoryFilter(r.hierarchy, scope, configType, query); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := []ConfigHistoryEntry{}
+	for i := len(r.history) - 1; i >= 0 && len(entries) < query.limit(); i-- {
+		doc := r.history[i]
+		if !doc.matches(r.hierarchy, scope, configType) {
+			continue
+		}
+		if (!query.Since.IsZero() && doc.ChangedAt.Before(query.Since)) || (!query.Until.IsZero() && !doc.ChangedAt.Before(query.Until)) {
+			continue
+		}
+		if query.BeforeVersion > 0 && doc.Version >= query.BeforeVersion {
+			continue
+		}
+		entry, err := doc.decode(r.hierarchy)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (r *MemoryRepo) RevertConfig(ctx context.Context, scope Scope, configType entities.ConfigType, toVersion int64, changedBy string) (entities.ValidatedConfig, error) {
+	r, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if entities.NewConfig(configType) == nil {
+		return nil, fmt.Errorf("unsupported config type")
+	}
+	if _, err := makeUpsertConfigFilter(r.hierarchy, scope); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	var found *historyDocument
+	for i := range r.history {
+		if r.history[i].matches(r.hierarchy, scope, configType) && r.history[i].Version == toVersion {
+			found = &r.history[i]
+			break
+		}
+	}
+	r.mu.RUnlock()
+	if found == nil {
+		return nil, ErrHistoryVersionNotFound{ConfigType: configType, Version: toVersion}
+	}
+
+	entry, err := found.decode(r.hierarchy)
+	if err != nil {
+		return nil, err
+	}
+	config, err := revertedConfig(entry, changedBy, r.now())
+	if err != nil {
+		return nil, err
+	}
+
+	return r.SetConfig(ctx, scope, config)
+}
+
+func (r *MemoryRepo) DeleteConfig(ctx context.Context, scope Scope, configType entities.ConfigType, changedBy string) error {
+	r, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	if entities.NewConfig(configType) == nil {
+		return fmt.Errorf("unsupported config type")
+	}
+	if changedBy == "" {
+		return fmt.Errorf("changedBy is required")
+	}
+	if _, err := makeUpsertConfigFilter(r.hierarchy, scope); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	doc := r.findScopeDocument(scope)
+	if doc.configRaw(configType) == nil {
+		return ErrConfigNotFound{Scope: scope.normalized(), ConfigType: configType}
+	}
+	before, err := doc.raw(r.hierarchy)
+	if err != nil {
+		return err
+	}
+	entry, err := newDeletionEntry(r.hierarchy, scope, configType, before, deletedRevision(before, configType.String(), 0), changedBy, r.now())
+	if err != nil {
+		return err
+	}
+	delete(doc.configs, configType.String())
+	doc.deletedRevisions[configType.String()] = entry.Version
+	r.history = append(r.history, entry)
+
+	return nil
+}
+
+func (r *MemoryRepo) DeleteScope(ctx context.Context, scope Scope, cascade bool, changedBy string) (int64, error) {
+	r, err := r.tenant(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if changedBy == "" {
+		return 0, fmt.Errorf("changedBy is required")
+	}
+	if _, err := makeDeleteScopeFilter(r.hierarchy, scope, cascade); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	var kept []*memoryDocument
+	var entries []historyDocument
+	var deleted int64
+	for _, doc := range r.documents {
+		if !doc.inDeletedScope(scope, cascade) {
+			kept = append(kept, doc)
+			continue
+		}
+		before, err := doc.raw(r.hierarchy)
+		if err != nil {
+			return 0, err
+		}
+		for _, configType := range entities.RegisteredConfigTypes() {
+			if doc.configRaw(configType) == nil {
+				continue
+			}
+			entry, err := newDeletionEntry(r.hierarchy, doc.scope, configType, before, deletedRevision(before, configType.String(), 0), changedBy, now)
+			if err != nil {
+				return 0, err
+			}
+			entries = append(entries, entry)
+		}
+		deleted++
+	}
+	if deleted == 0 {
+		return 0, ErrScopeNotFound{Scope: scope.normalized()}
+	}
+	r.documents = kept
+	r.history = append(r.history, entries...)
+
+	return deleted, nil
+}
+
+//latestHistoryVersion is MDBRepo.latestHistoryVersion.  Callers must hold mu, which also makes it atomic with the write
+//it numbers
+func (r *MemoryRepo) latestHistoryVersion(scope Scope, configType entities.ConfigType) int64 {
+	var latest int64
+	for _, doc := range r.history {
+		if doc.matches(r.hierarchy, scope, configType) && doc.Version > latest {
+			latest = doc.Version
+		}
+	}
+
+	return latest
+}
+
+func newMemoryDocument(scope Scope) *memoryDocument {
+	return &memoryDocument{scope: scope.normalized(), configs: map[string]bson.Raw{}, deletedRevisions: map[string]int64{}}
+}
+
+func (r *MemoryRepo) findScopeDocument(scope Scope) *memoryDocument {
+	scope = scope.normalized()
+	for _, doc := range r.documents {
+		if doc.scope == scope {
+			return doc
+		}
+	}
+
+	return nil
+}
+
+//isActiveAt is withEnabledCondition: meta.enabled is true and asOf is inside the effective window
+func (d *memoryDocument) isActiveAt(configType entities.ConfigType, asOf time.Time) bool {
+	raw, ok := d.configs[configType.String()]
+	if !ok {
+		return false
+	}
+	var stored struct {
+		Meta entities.ConfigMeta `bson:"meta"`
+	}
+	if err := bson.Unmarshal(raw, &stored); err != nil {
+		return false
+	}
+
+	return stored.Meta.ActiveAt(asOf)
+}
+
+//inScopeChain is one of makeActiveCandidatesMatch's branches: the document is the scope at its own level in the chain
+//down to scope
+func (d *memoryDocument) inScopeChain(scope Scope) bool {
+	if d.scope.ConfigLevel == entities.CONFIG_LEVEL_UNSPECIFIED || d.scope.ConfigLevel > scope.ConfigLevel {
+		return false
+	}
+
+	return d.scope == scope.At(d.scope.ConfigLevel)
+}
+
+//memoryDocumentFromRaw is the inverse of raw, for documents read from the configs collection.  Fields that aren't
+//subdocuments aren't configs, so they are skipped, as is deleted_revisions
+func memoryDocumentFromRaw(h *entities.Hierarchy, raw bson.Raw) (*memoryDocument, error) {
+	scope, err := decodeScope(h, raw)
+	if err != nil {
+		return nil, err
+	}
+	doc := newMemoryDocument(scope)
+
+	elems, err := raw.Elements()
+	if err != nil {
+		return nil, err
+	}
+	for _, elem := range elems {
+		if elem.Key() == deletedRevisionsField {
+			doc.deletedRevisions = decodeDeletedRevisions(elem.Value())
+			continue
+		}
+		if config, ok := elem.Value().DocumentOK(); ok {
+			doc.configs[elem.Key()] = config
+		}
+	}
+
+	return doc, nil
+}
+
+//memoryDocumentsFromCursor reads every document left on csr with memoryDocumentFromRaw
+func memoryDocumentsFromCursor(ctx context.Context, csr *mongo.Cursor, h *entities.Hierarchy) ([]*memoryDocument, error) {
+	var documents []*memoryDocument
+	for csr.Next(ctx) {
+		doc, err := memoryDocumentFromRaw(h, csr.Current)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, doc)
+	}
+	if err := csr.Err(); err != nil {
+		return nil, err
+	}
+
+	return documents, nil
+}
+
+//inDeletedScope is makeDeleteScopeFilter
+func (d *memoryDocument) inDeletedScope(scope Scope, cascade bool) bool {
+	if d.scope.ConfigLevel != scope.ConfigLevel && !cascade {
+		return false
+	}
+
+	return scope.contains(d.scope)
+}
+
+//configRaw is the stored configType subdocument, nil if it isn't set or there is no document
+func (d *memoryDocument) configRaw(configType entities.ConfigType) bson.Raw {
+	if d == nil {
+		return nil
+	}
+
+	return d.configs[configType.String()]
+}
+
+//raw marshals the whole document as it would be stored in the configs collection
+func (d *memoryDocument) raw(h *entities.Hierarchy) (bson.Raw, error) {
+	fullDoc := bson.D{{Key: "config_level", Value: d.scope.ConfigLevel}}
+	for level := entities.ConfigLevel(1); level <= d.scope.ConfigLevel; level++ {
+		fullDoc = append(fullDoc, bson.E{Key: h.IDField(level), Value: d.scope.ID(level)})
+	}
+	for key, config := range d.configs {
+		fullDoc = append(fullDoc, bson.E{Key: key, Value: config})
+	}
+	if len(d.deletedRevisions) > 0 {
+		fullDoc = append(fullDoc, bson.E{Key: deletedRevisionsField, Value: d.deletedRevisions})
+	}
+
+	return bson.Marshal(fullDoc)
+}
+
+//decodeDeletedRevisions reads a deleted_revisions field, skipping anything that isn't a revision
+func decodeDeletedRevisions(value bson.RawValue) map[string]int64 {
+	revisions := map[string]int64{}
+	elems, err := value.Document().Elements()
+	if err != nil {
+		return revisions
+	}
+	for _, elem := range elems {
+		if revision, ok := revisionValue(elem.Value()); ok {
+			revisions[elem.Key()] = revision
+		}
+	}
+
+	return revisions
+}
+
+//Rebuilds the stored document (or the $replaceRoot'd subdocument) and decodes it the same way getConfigFromCursor does
+func (d *memoryDocument) decode(h *entities.Hierarchy, configLevel entities.ConfigLevel, configType entities.ConfigType) (entities.ValidatedConfig, error) {
+	var raw bson.Raw
+	switch configType {
+	case entities.CONFIG_TYPE_UNSPECIFIED:
+		return nil, fmt.Errorf("unsupported config type")
+	case entities.CONFIG_TYPE_FULL:
+		var err error
+		raw, err = d.raw(h)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		var ok bool
+		raw, ok = d.configs[configType.String()]
+		if !ok {
+			//GetSpecificConfig's pipeline matches no document without the subdocument, which getConfigFromCursor reports as
+			//an empty config
+			return emptyConfigForType(h, configLevel, configType), nil
+		}
+	}
+
+	config, err := newConfigForDecode(h, configLevel, configType)
+	if err != nil {
+		return nil, err
+	}
+	if err := bson.Unmarshal(raw, config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
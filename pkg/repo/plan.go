@@ -0,0 +1,286 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+//SetConfigPlan is what a SetConfig of one config would do to active resolution, worked out without writing it.  The
+//scopes it covers are the written scope and every scope below it that has a document, or has one below it; a scope
+//with no document anywhere down its chain resolves like the nearest one above it, so it changes as that one does.
+//Rollouts are the exception: each scope has its own bucket, so when the written or replaced config has rollouts a
+//scope without a document can get a rolled out field the nearest one above it doesn't, and the plan doesn't list it
+type SetConfigPlan struct {
+	Scope      Scope               `json:"scope"`
+	ConfigType entities.ConfigType `json:"config_type"`
+	AsOf       time.Time           `json:"as_of"`
+	//Changes lists the scopes whose GetActiveConfig result would change, most general first
+	Changes []ActiveConfigChange `json:"changes"`
+	//Shielded lists the scopes whose active config is supplied by an enabled override between them and the written
+	//scope, so the write wouldn't reach them
+	Shielded []ShieldedScope `json:"shielded"`
+	//Unchanged counts the other scopes, whose active config would stay as it is, e.g. because the written config is
+	//disabled or the same as the one it replaces
+	Unchanged int `json:"unchanged"`
+
+	hierarchy *entities.Hierarchy
+}
+
+//MarshalJSON names the written scope's level, like the changes' and shielded scopes', through the plan's hierarchy
+func (p SetConfigPlan) MarshalJSON() ([]byte, error) {
+	type plan SetConfigPlan
+	return json.Marshal(struct {
+		Scope *scopeJSON `json:"scope"`
+		plan
+	}{Scope: newScopeJSON(p.hierarchy, p.Scope), plan: plan(p)})
+}
+
+//ActiveConfigChange is one scope's active config before and after a planned write.  From and To are as GetActiveConfig
+//returns them, the empty config if nothing is active, and FromScope and ToScope are the scopes that supply them, nil
+//likewise.  Fields are their differences, leaving out the write meta; see entities.IgnoreWriteMeta
+type ActiveConfigChange struct {
+	Scope     Scope                    `json:"scope"`
+	From      entities.ValidatedConfig `json:"from"`
+	FromScope *Scope                   `json:"from_scope"`
+	To        entities.ValidatedConfig `json:"to"`
+	ToScope   *Scope                   `json:"to_scope"`
+	Fields    []entities.FieldDiff     `json:"fields"`
+
+	hierarchy *entities.Hierarchy
+}
+
+func (c ActiveConfigChange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Scope     *scopeJSON               `json:"scope"`
+		From      entities.ValidatedConfig `json:"from"`
+		FromScope *scopeJSON               `json:"from_scope"`
+		To        entities.ValidatedConfig `json:"to"`
+		ToScope   *scopeJSON               `json:"to_scope"`
+		Fields    []entities.FieldDiff     `json:"fields"`
+	}{
+		Scope:     newScopeJSON(c.hierarchy, c.Scope),
+		From:      c.From,
+		FromScope: optionalScopeJSON(c.hierarchy, c.FromScope),
+		To:        c.To,
+		ToScope:   optionalScopeJSON(c.hierarchy, c.ToScope),
+		Fields:    c.Fields,
+	})
+}
+
+//ShieldedScope is a scope whose active config comes from ShieldedBy, an enabled override below the written scope
+type ShieldedScope struct {
+	Scope      Scope `json:"scope"`
+	ShieldedBy Scope `json:"shielded_by"`
+
+	hierarchy *entities.Hierarchy
+}
+
+func (s ShieldedScope) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Scope      *scopeJSON `json:"scope"`
+		ShieldedBy *scopeJSON `json:"shielded_by"`
+	}{Scope: newScopeJSON(s.hierarchy, s.Scope), ShieldedBy: newScopeJSON(s.hierarchy, s.ShieldedBy)})
+}
+
+//PlanSetConfig reads the written scope's chain and everything below it in one query and plans the write in memory
+func (r *MDBRepo) PlanSetConfig(ctx context.Context, scope Scope, config entities.ValidatedConfig, opts ...ActiveOption) (*SetConfigPlan, error) {
+	if err := validateSetConfig(r.hierarchy, scope, config); err != nil {
+		return nil, err
+	}
+	activeOpts := newActiveOptions(r.now, opts)
+	filter, err := makePlanSetConfigFilter(r.hierarchy, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	configs, err := r.configCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := configs.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer csr.Close(ctx)
+	documents, err := memoryDocumentsFromCursor(ctx, csr, r.hierarchy)
+	if err != nil {
+		return nil, err
+	}
+
+	return planSetConfig(r.hierarchy, documents, scope, config, activeOpts.asOf)
+}
+
+func (r *MemoryRepo) PlanSetConfig(ctx context.Context, scope Scope, config entities.ValidatedConfig, opts ...ActiveOption) (*SetConfigPlan, error) {
+	r, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSetConfig(r.hierarchy, scope, config); err != nil {
+		return nil, err
+	}
+	activeOpts := newActiveOptions(r.now, opts)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	//makePlanSetConfigFilter
+	var documents []*memoryDocument
+	for _, doc := range r.documents {
+		if doc.inScopeChain(scope) || scope.contains(doc.scope) {
+			documents = append(documents, doc)
+		}
+	}
+
+	return planSetConfig(r.hierarchy, documents, scope, config, activeOpts.asOf)
+}
+
+//validateSetConfig is SetConfig's checks, so a plan fails as the write would
+func validateSetConfig(h *entities.Hierarchy, scope Scope, config entities.ValidatedConfig) error {
+	if err := h.ValidateConfigLevel(scope.ConfigLevel, config.GetConfigType()); err != nil {
+		return err
+	}
+	if err := entities.ValidateConfig(config); err != nil {
+		return err
+	}
+	_, err := makeUpsertConfigFilter(h, scope)
+
+	return err
+}
+
+//planSetConfig resolves every affected scope against documents as they are and as they would be with config written
+//onto scope's document, as the upsert would write it
+func planSetConfig(h *entities.Hierarchy, documents []*memoryDocument, scope Scope, config entities.ValidatedConfig, asOf time.Time) (*SetConfigPlan, error) {
+	scope = scope.normalized()
+	configType := config.GetConfigType()
+
+	planned := make([]*memoryDocument, 0, len(documents)+1)
+	var written *memoryDocument
+	for _, doc := range documents {
+		if written == nil && doc.scope == scope {
+			written = &memoryDocument{scope: doc.scope, configs: make(map[string]bson.Raw, len(doc.configs)+1), deletedRevisions: doc.deletedRevisions}
+			for key, raw := range doc.configs {
+				written.configs[key] = raw
+			}
+			planned = append(planned, written)
+			continue
+		}
+		planned = append(planned, doc)
+	}
+	if written == nil {
+		written = newMemoryDocument(scope)
+		planned = append(planned, written)
+	}
+	revision, err := storedRevision(written.configRaw(configType), 0)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := bson.Marshal(withRevision(config, revision+1))
+	if err != nil {
+		return nil, err
+	}
+	written.configs[configType.String()] = raw
+
+	plan := &SetConfigPlan{
+		Scope:      scope,
+		ConfigType: configType,
+		AsOf:       asOf,
+		Changes:    []ActiveConfigChange{},
+		Shielded:   []ShieldedScope{},
+		hierarchy:  h,
+	}
+	for _, affected := range affectedScopes(documents, scope) {
+		fromDoc, from, err := resolveActive(h, documents, affected, configType, asOf)
+		if err != nil {
+			return nil, err
+		}
+		toDoc, to, err := resolveActive(h, planned, affected, configType, asOf)
+		if err != nil {
+			return nil, err
+		}
+		fields, err := entities.Diff(from, to, entities.IgnoreWriteMeta())
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case len(fields) > 0:
+			plan.Changes = append(plan.Changes, ActiveConfigChange{
+				Scope:     affected,
+				From:      from,
+				FromScope: documentScope(fromDoc),
+				To:        to,
+				ToScope:   documentScope(toDoc),
+				Fields:    fields,
+				hierarchy: h,
+			})
+		case toDoc != nil && toDoc.scope.ConfigLevel > scope.ConfigLevel:
+			plan.Shielded = append(plan.Shielded, ShieldedScope{Scope: affected, ShieldedBy: toDoc.scope, hierarchy: h})
+		default:
+			plan.Unchanged++
+		}
+	}
+
+	return plan, nil
+}
+
+//affectedScopes is scope and every scope below it with a document or a descendant with one, most general first
+func affectedScopes(documents []*memoryDocument, scope Scope) []Scope {
+	seen := map[Scope]bool{scope: true}
+	scopes := []Scope{scope}
+	for _, doc := range documents {
+		if !scope.contains(doc.scope) {
+			continue
+		}
+		for level := scope.ConfigLevel + 1; level <= doc.scope.ConfigLevel; level++ {
+			if between := doc.scope.At(level); !seen[between] {
+				seen[between] = true
+				scopes = append(scopes, between)
+			}
+		}
+	}
+
+	sort.Slice(scopes, func(i, j int) bool {
+		if scopes[i].ConfigLevel != scopes[j].ConfigLevel {
+			return scopes[i].ConfigLevel < scopes[j].ConfigLevel
+		}
+		for k := range scopes[i].IDs {
+			if scopes[i].IDs[k] != scopes[j].IDs[k] {
+				return scopes[i].IDs[k] < scopes[j].IDs[k]
+			}
+		}
+		return false
+	})
+
+	return scopes
+}
+
+//resolveActive is GetActiveConfig over documents, also returning the winning document, nil if nothing is active
+func resolveActive(h *entities.Hierarchy, documents []*memoryDocument, scope Scope, configType entities.ConfigType, asOf time.Time) (*memoryDocument, entities.ValidatedConfig, error) {
+	winner, err := activeWinner(selectActiveCandidates(documents, scope, configType, true, asOf), configType)
+	if err != nil {
+		return nil, nil, err
+	}
+	if winner == nil {
+		return nil, emptyConfigForType(h, scope.ConfigLevel, configType), nil
+	}
+	config, err := decodeActive(h, winner, scope, configType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return winner, config, nil
+}
+
+func documentScope(doc *memoryDocument) *Scope {
+	if doc == nil {
+		return nil
+	}
+	scope := doc.scope
+
+	return &scope
+}
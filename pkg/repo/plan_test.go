@@ -0,0 +1,161 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+)
+
+//planTestRepo has a corporate cloud_cart validating prices, venue 2 overriding it and venue 3 disabled, with vendors
+//below both venues and one under another corporate
+func planTestRepo(t *testing.T) ConfigRepository {
+	configRepo := NewMemoryRepo()
+	for _, w := range []struct {
+		scope  Scope
+		config entities.ValidatedConfig
+	}{
+		{CorporateScope("1"), planCloudCart("CORP", true, true)},
+		{VenueScope("1", "2"), planCloudCart("VENUE 2", true, false)},
+		{VenueScope("1", "3"), planCloudCart("VENUE 3", false, false)},
+		{VendorScope("1", "2", "5"), otherExample("VENDOR", true)},
+		{VendorScope("1", "3", "4"), otherExample("VENDOR", true)},
+		{VendorScope("9", "3", "4"), otherExample("VENDOR", true)},
+	} {
+		mustSet(t, configRepo, w.scope, w.config)
+	}
+
+	return configRepo
+}
+
+func planCloudCart(changedBy string, enabled, validatePrices bool) *entities.CloudCartConfig {
+	config := cloudCart(changedBy, enabled)
+	config.EnableValidatePrices = validatePrices
+	return config
+}
+
+func TestPlanSetConfig(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		scope         Scope
+		config        entities.ValidatedConfig
+		wantChanged   []string
+		wantShielded  []string
+		wantUnchanged int
+	}{
+		{
+			name:         "a corporate write",
+			scope:        CorporateScope("1"),
+			config:       planCloudCart("PLAN", true, false),
+			wantChanged:  []string{`scope "1"`, `scope "1"/"3"`, `scope "1"/"3"/"4"`},
+			wantShielded: []string{`scope "1"/"2" by scope "1"/"2"`, `scope "1"/"2"/"5" by scope "1"/"2"`},
+		},
+		{
+			name:          "a corporate write that changes nothing",
+			scope:         CorporateScope("1"),
+			config:        planCloudCart("PLAN", true, true),
+			wantShielded:  []string{`scope "1"/"2" by scope "1"/"2"`, `scope "1"/"2"/"5" by scope "1"/"2"`},
+			wantUnchanged: 3,
+		},
+		{
+			name:         "a disabled corporate write",
+			scope:        CorporateScope("1"),
+			config:       planCloudCart("PLAN", false, false),
+			wantChanged:  []string{`scope "1"`, `scope "1"/"3"`, `scope "1"/"3"/"4"`},
+			wantShielded: []string{`scope "1"/"2" by scope "1"/"2"`, `scope "1"/"2"/"5" by scope "1"/"2"`},
+		},
+		{
+			name:        "a venue write",
+			scope:       VenueScope("1", "3"),
+			config:      planCloudCart("PLAN", true, false),
+			wantChanged: []string{`scope "1"/"3"`, `scope "1"/"3"/"4"`},
+		},
+		{
+			name:        "a write to a corporate without documents below it",
+			scope:       CorporateScope("7"),
+			config:      planCloudCart("PLAN", true, false),
+			wantChanged: []string{`scope "7"`},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			plan, err := planTestRepo(t).PlanSetConfig(context.Background(), test.scope, test.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var changed, shielded []string
+			for _, change := range plan.Changes {
+				changed = append(changed, change.Scope.String())
+			}
+			for _, s := range plan.Shielded {
+				shielded = append(shielded, s.Scope.String()+" by "+s.ShieldedBy.String())
+			}
+			if fmt.Sprint(changed) != fmt.Sprint(test.wantChanged) {
+				t.Errorf("changes %v, want %v", changed, test.wantChanged)
+			}
+			if fmt.Sprint(shielded) != fmt.Sprint(test.wantShielded) {
+				t.Errorf("shields %v, want %v", shielded, test.wantShielded)
+			}
+			if plan.Unchanged != test.wantUnchanged {
+				t.Errorf("leaves %d scopes unchanged, want %d", plan.Unchanged, test.wantUnchanged)
+			}
+		})
+	}
+}
+
+func TestPlanSetConfigFields(t *testing.T) {
+	plan, err := planTestRepo(t).PlanSetConfig(context.Background(), CorporateScope("1"), planCloudCart("PLAN", true, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Changes) == 0 {
+		t.Fatal("the plan changes nothing")
+	}
+	change := plan.Changes[0]
+	if fields := change.Fields; len(fields) != 1 || fields[0].Path != "enable_validate_prices" {
+		t.Errorf("%s changes fields %v, want enable_validate_prices alone", change.Scope.String(), fields)
+	}
+	if changedBy(change.From) != "CORP" || changedBy(change.To) != "PLAN" {
+		t.Errorf("%s changes from %q to %q, want CORP to PLAN", change.Scope.String(), changedBy(change.From), changedBy(change.To))
+	}
+
+	//The disabled venue takes its config from the corporate before the write and after it
+	venue := plan.Changes[1]
+	if venue.FromScope == nil || venue.ToScope == nil || venue.FromScope.String() != `scope "1"` || venue.ToScope.String() != `scope "1"` {
+		t.Errorf("%s changes from %v to %v, want the corporate both times", venue.Scope.String(), venue.FromScope, venue.ToScope)
+	}
+}
+
+func TestPlanSetConfigWritesNothing(t *testing.T) {
+	configRepo := planTestRepo(t)
+	if _, err := configRepo.PlanSetConfig(context.Background(), CorporateScope("1"), planCloudCart("PLAN", true, false)); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustActive(t, configRepo, CorporateScope("1"), entities.CONFIG_TYPE_DEMO_CONFIG); got != "CORP" {
+		t.Errorf("active after planning is %q, want CORP", got)
+	}
+}
+
+func TestPlanSetConfigRefusesWhatSetConfigWould(t *testing.T) {
+	configRepo := planTestRepo(t)
+	for _, test := range []struct {
+		name   string
+		scope  Scope
+		config entities.ValidatedConfig
+	}{
+		{"a config without changed_by", CorporateScope("1"), planCloudCart("", true, true)},
+		{"a type not allowed at the level", VendorScope("1", "2", "5"), planCloudCart("PLAN", true, true)},
+	} {
+		_, planErr := configRepo.PlanSetConfig(context.Background(), test.scope, test.config)
+		_, setErr := configRepo.SetConfig(context.Background(), test.scope, test.config)
+		if planErr == nil || setErr == nil || planErr.Error() != setErr.Error() {
+			t.Errorf("%s: plan failed with %v, set with %v, want the same error", test.name, planErr, setErr)
+		}
+	}
+
+	var validationErr *entities.ValidationError
+	if _, err := configRepo.PlanSetConfig(context.Background(), CorporateScope("1"), planCloudCart("", true, true)); !errors.As(err, &validationErr) {
+		t.Errorf("plan of an invalid config: got %v, want a ValidationError", err)
+	}
+}
@@ -2,52 +2,71 @@ package repo
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/mcquackers/config-demo/pkg/entities"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func makeUpsertConfigFilter(configLevel entities.ConfigLevel, corporateID, venueID, vendorID string) (bson.M, error) {
-	if configLevel == entities.CONFIG_LEVEL_UNSPECIFIED {
-		return nil, fmt.Errorf("invalid config level: %d", configLevel)
+//Matches the document owned by exactly scope: its config_level and the ID field of every level down to it.  Those are
+//also the fields an upsert creates the document with
+func makeUpsertConfigFilter(h *entities.Hierarchy, scope Scope) (bson.M, error) {
+	if !h.ValidLevel(scope.ConfigLevel) {
+		return nil, fmt.Errorf("invalid config level: %d", scope.ConfigLevel)
 	}
 	filter := bson.M{
-		"config_level": configLevel,
+		"config_level": scope.ConfigLevel,
 	}
-
-	if configLevel >= entities.CONFIG_LEVEL_CORPORATE {
-		filter["corporate_id"] = corporateID
+	for level := entities.ConfigLevel(entities.CONFIG_LEVEL_CORPORATE); level <= scope.ConfigLevel; level++ {
+		filter[h.IDField(level)] = scope.ID(level)
 	}
-	if configLevel >= entities.CONFIG_LEVEL_VENUE {
-		filter["venue_id"] = venueID
+
+	return filter, nil
+}
+
+//Matches the exact chain of scope documents from the top level down to scope, i.e. scope and its ancestors
+func makeScopeChainFilter(h *entities.Hierarchy, scope Scope) (bson.M, error) {
+	if !h.ValidLevel(scope.ConfigLevel) {
+		return nil, fmt.Errorf("invalid config level: %d", scope.ConfigLevel)
 	}
-	if configLevel >= entities.CONFIG_LEVEL_VENDOR {
-		filter["vendor_id"] = vendorID
+
+	scopes := bson.A{}
+	for level := entities.ConfigLevel(entities.CONFIG_LEVEL_CORPORATE); level <= scope.ConfigLevel; level++ {
+		filter, err := makeUpsertConfigFilter(h, scope.At(level))
+		if err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, filter)
 	}
 
-	return filter, nil
+	return bson.M{"$or": scopes}, nil
 }
-func makeConfigPipeline(corporateID, venueID, vendorID string, configType entities.ConfigType) mongo.Pipeline {
+
+func makeConfigPipeline(h *entities.Hierarchy, scope Scope, configType entities.ConfigType) (mongo.Pipeline, error) {
 	switch configType {
 	case entities.CONFIG_TYPE_UNSPECIFIED: // || !configType.Valid()
-		return mongo.Pipeline{}
+		return mongo.Pipeline{}, nil
 	case entities.CONFIG_TYPE_FULL:
-		return makeMainConfigPipeline(corporateID, venueID, vendorID)
+		return makeMainConfigPipeline(h, scope)
 	default:
-		return makeUnderlyingConfigPipeline(corporateID, venueID, vendorID, configType)
+		return makeUnderlyingConfigPipeline(h, scope, configType)
 	}
 }
-func makeMainConfigPipeline(corporateID, venueID, vendorID string) mongo.Pipeline {
+
+//Matches the document owned by exactly this scope, using the same filter SetConfig upserts with.  Matching every ID
+//regardless of level never found the upper levels' documents, since those are written without the lower IDs
+func makeMainConfigPipeline(h *entities.Hierarchy, scope Scope) (mongo.Pipeline, error) {
+	filter, err := makeUpsertConfigFilter(h, scope)
+	if err != nil {
+		return nil, err
+	}
+
 	return mongo.Pipeline{
 		{
 			{
-				Key: "$match",
-				Value: bson.M{
-					"corporate_id": corporateID,
-					"venue_id":     venueID,
-					"vendor_id":    vendorID,
-				},
+				Key:   "$match",
+				Value: filter,
 			},
 		},
 
@@ -57,10 +76,28 @@ func makeMainConfigPipeline(corporateID, venueID, vendorID string) mongo.Pipelin
 				Value: 1,
 			},
 		},
+	}, nil
+}
+//Matches the scope's document only if it holds configType: $replaceRoot rejects a missing subdocument, e.g. after
+//DeleteConfig or on a document holding only other types, so those leave the cursor empty and read as the empty config
+func makeUnderlyingConfigPipeline(h *entities.Hierarchy, scope Scope, configType entities.ConfigType) (mongo.Pipeline, error) {
+	pipeline, err := makeMainConfigPipeline(h, scope)
+	if err != nil {
+		return nil, err
 	}
+
+	return append(pipeline, makeConfigExistsStage(configType), makeReplaceRootStage(configType)), nil
 }
-func makeUnderlyingConfigPipeline(corporateID, venueID, vendorID string, configType entities.ConfigType) mongo.Pipeline {
-	return append(makeMainConfigPipeline(corporateID, venueID, vendorID), makeReplaceRootStage(configType))
+
+func makeConfigExistsStage(configType entities.ConfigType) bson.D {
+	return bson.D{
+		{
+			Key: "$match",
+			Value: bson.M{
+				configType.String(): bson.M{"$exists": true},
+			},
+		},
+	}
 }
 
 func makeReplaceRootStage(configType entities.ConfigType) bson.D {
@@ -74,53 +111,64 @@ func makeReplaceRootStage(configType entities.ConfigType) bson.D {
 	}
 }
 
-func makeGetActiveConfigPipeline(configLevel entities.ConfigLevel, corporateID, venueID, vendorID string, configType entities.ConfigType) mongo.Pipeline {
+//Ranks the scope chain's enabled candidates by level and keeps the runner-up too, so a second document at the winner's
+//level can be reported as ErrAmbiguousScope instead of silently losing
+func makeGetActiveConfigPipeline(h *entities.Hierarchy, scope Scope, configType entities.ConfigType, asOf time.Time) (mongo.Pipeline, error) {
+	match, err := makeGetActiveConfigMatch(h, scope, configType, asOf)
+	if err != nil {
+		return nil, err
+	}
+
 	return mongo.Pipeline{
-		makeGetActiveConfigMatch(configLevel, corporateID, venueID, vendorID, configType),
+		match,
 		makeGetActiveConfigSort(),
 		makeGetActiveConfigLimit(),
-		makeReplaceRootStage(configType),
-	}
+	}, nil
+}
+
+func makeGetActiveConfigMatch(h *entities.Hierarchy, scope Scope, configType entities.ConfigType, asOf time.Time) (bson.D, error) {
+	return makeActiveCandidatesMatch(h, scope, configType, true, asOf)
 }
 
-func makeGetActiveConfigMatch(configLevel entities.ConfigLevel, corporateID, venueID, vendorID string, configType entities.ConfigType) bson.D {
+//The active config match: one $or branch per level of the scope chain, each pinned to its config_level so a document
+//only matches as the scope it was written for.  Optionally without the meta.enabled and effective window conditions so
+//explain can report inactive candidates too
+func makeActiveCandidatesMatch(h *entities.Hierarchy, scope Scope, configType entities.ConfigType, requireEnabled bool, asOf time.Time) (bson.D, error) {
+	if !h.ValidLevel(scope.ConfigLevel) {
+		return nil, fmt.Errorf("invalid config level: %d", scope.ConfigLevel)
+	}
+
+	branches := bson.A{}
+	for level := entities.ConfigLevel(entities.CONFIG_LEVEL_CORPORATE); level <= scope.ConfigLevel; level++ {
+		branches = append(branches, makeScopeConfigQuery(h, scope.At(level), configType, requireEnabled, asOf))
+	}
+
 	configMatch := bson.D{
 		{
 			Key: "$match",
 			Value: bson.D{
 				{
-					"config_level",
-					bson.D{
-						{
-							"$lte",
-							configLevel,
-						},
-					},
-				},
-				{
-					"$or",
-					bson.A{
-						makeCorporateConfigQuery(corporateID, configType),
-						makeVenueConfigQuery(corporateID, venueID, configType),
-						makeVendorConfigQuery(corporateID, venueID, vendorID, configType),
-					},
+					Key:   "$or",
+					Value: branches,
 				},
 			},
 		},
 	}
 
-	return configMatch
+	return configMatch, nil
 }
 
+//Highest config_level first, i.e. the most specific scope wins.  _id only breaks ties between duplicate scope
+//documents, so explain lists them in a stable order
 func makeGetActiveConfigSort() bson.D {
 	return bson.D{{
 		Key: "$sort",
 		Value: bson.D{
 			{
-				"vendor_id", -1,
+				Key: "config_level", Value: -1,
 			},
 			{
-				"venue_id", -1,
+				Key: "_id", Value: 1,
 			},
 		},
 	},
@@ -130,29 +178,280 @@ func makeGetActiveConfigSort() bson.D {
 func makeGetActiveConfigLimit() bson.D {
 	return bson.D{{
 		Key:   "$limit",
-		Value: 1,
+		Value: 2,
 	},
 	}
 }
 
-func makeCorporateConfigQuery(corporateID string, configType entities.ConfigType) bson.D {
-	return bson.D{
-		{"corporate_id", corporateID},
-		{fmt.Sprintf("%s.meta.enabled", configType.String()), true},
+//The $or branch for scope's own document: its config_level and the ID field of every level down to it, in hierarchy
+//order so the branch can use active_lookup
+func makeScopeConfigQuery(h *entities.Hierarchy, scope Scope, configType entities.ConfigType, requireEnabled bool, asOf time.Time) bson.D {
+	query := bson.D{{Key: "config_level", Value: scope.ConfigLevel}}
+	for level := entities.ConfigLevel(entities.CONFIG_LEVEL_CORPORATE); level <= scope.ConfigLevel; level++ {
+		query = append(query, bson.E{Key: h.IDField(level), Value: scope.ID(level)})
 	}
+
+	return withEnabledCondition(query, configType, requireEnabled, asOf)
 }
-func makeVenueConfigQuery(corporateID, venueID string, configType entities.ConfigType) bson.D {
-	return bson.D{
-		{"corporate_id", corporateID},
-		{"venue_id", venueID},
-		{fmt.Sprintf("%s.meta.enabled", configType.String()), true},
+
+//Adds meta.enabled and the effective window at asOf.  $not keeps configs without effective_from or effective_until
+//matching, where a plain $lte or $gt would drop them
+func withEnabledCondition(query bson.D, configType entities.ConfigType, requireEnabled bool, asOf time.Time) bson.D {
+	if !requireEnabled {
+		return query
+	}
+
+	return append(query,
+		bson.E{Key: fmt.Sprintf("%s.meta.enabled", configType.String()), Value: true},
+		bson.E{Key: fmt.Sprintf("%s.meta.effective_from", configType.String()), Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gt", Value: asOf}}}}},
+		bson.E{Key: fmt.Sprintf("%s.meta.effective_until", configType.String()), Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$lte", Value: asOf}}}}},
+	)
+}
+
+//Every document that could hold an active config of any type for the scope; configType only feeds the enabled
+//condition, which is off.  Each type's candidates are picked out by selectActiveCandidates
+func makeActiveBundlePipeline(h *entities.Hierarchy, scope Scope) (mongo.Pipeline, error) {
+	match, err := makeActiveCandidatesMatch(h, scope, entities.CONFIG_TYPE_UNSPECIFIED, false, time.Time{})
+	if err != nil {
+		return nil, err
 	}
+
+	return mongo.Pipeline{match}, nil
 }
-func makeVendorConfigQuery(corporateID, venueID, vendorID string, configType entities.ConfigType) bson.D {
-	return bson.D{
-		{"corporate_id", corporateID},
-		{"venue_id", venueID},
-		{"vendor_id", vendorID},
-		{fmt.Sprintf("%s.meta.enabled", configType.String()), true},
+
+//Every document the active pipeline would consider, enabled or not, in the order it ranks them
+func makeExplainActiveConfigPipeline(h *entities.Hierarchy, scope Scope, configType entities.ConfigType) (mongo.Pipeline, error) {
+	match, err := makeActiveCandidatesMatch(h, scope, configType, false, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	return mongo.Pipeline{
+		match,
+		makeGetActiveConfigSort(),
+	}, nil
+}
+
+//Change events for the documents in scope's chain, the same per level ID match as makeScopeChainFilter on the changed
+//document.  Deletes carry no fullDocument to match on, so they all pass, to be picked out by ID in Watch, as do the
+//events that end a stream
+func makeWatchPipeline(h *entities.Hierarchy, scope Scope) (mongo.Pipeline, error) {
+	if !h.ValidLevel(scope.ConfigLevel) {
+		return nil, fmt.Errorf("invalid config level: %d", scope.ConfigLevel)
+	}
+
+	matches := bson.A{}
+	for level := entities.ConfigLevel(entities.CONFIG_LEVEL_CORPORATE); level <= scope.ConfigLevel; level++ {
+		filter, err := makeUpsertConfigFilter(h, scope.At(level))
+		if err != nil {
+			return nil, err
+		}
+		changed := bson.M{}
+		for field, value := range filter {
+			changed["fullDocument."+field] = value
+		}
+		matches = append(matches, changed)
+	}
+	matches = append(matches, bson.D{
+		{Key: "operationType", Value: bson.D{{Key: "$in", Value: bson.A{"delete", "invalidate", "drop", "rename", "dropDatabase"}}}},
+	})
+
+	return mongo.Pipeline{
+		{
+			{
+				Key:   "$match",
+				Value: bson.D{{Key: "$or", Value: matches}},
+			},
+		},
+	}, nil
+}
+
+//Replaces the key subdocument with config, setting meta.revision to one past the revision being replaced: the stored
+//config's, else the one its deletion left in deleted_revisions, else baseRevision.  It is a pipeline update so the
+//increment reads the document it updates; config is wrapped in $literal so none of its strings are taken for field paths
+func makeSetConfigUpdate(key string, config entities.ValidatedConfig, baseRevision int64) (mongo.Pipeline, error) {
+	marshalled, err := bson.Marshal(config)
+	if err != nil {
+		return nil, err
 	}
+	configDoc := bson.Raw(marshalled)
+	metaDoc := bson.Raw{}
+	if value, err := configDoc.LookupErr("meta"); err == nil {
+		metaDoc = value.Document()
+	}
+
+	revision := nextRevision(key, baseRevision)
+
+	return mongo.Pipeline{
+		{
+			{
+				Key: "$set",
+				Value: bson.D{
+					{
+						Key: key,
+						Value: bson.D{{Key: "$mergeObjects", Value: bson.A{
+							bson.D{{Key: "$literal", Value: configDoc}},
+							bson.D{{Key: "meta", Value: bson.D{{Key: "$mergeObjects", Value: bson.A{
+								bson.D{{Key: "$literal", Value: metaDoc}},
+								bson.D{{Key: "revision", Value: revision}},
+							}}}}},
+						}}},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+//Removes the key subdocument, leaving the revision of the deletion in deleted_revisions, so setting the config again
+//carries on numbering from it without reading the history
+func makeDeleteConfigUpdate(key string) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{{Key: deletedRevisionField(key), Value: nextRevision(key, 0)}}}},
+		{{Key: "$unset", Value: key}},
+	}
+}
+
+//nextRevision is one past the key config's stored meta.revision, else its deleted_revisions entry, else baseRevision
+func nextRevision(key string, baseRevision int64) bson.D {
+	stored := bson.D{{Key: "$ifNull", Value: bson.A{
+		fmt.Sprintf("$%s.meta.revision", key),
+		bson.D{{Key: "$ifNull", Value: bson.A{"$" + deletedRevisionField(key), baseRevision}}},
+	}}}
+
+	return bson.D{{Key: "$add", Value: bson.A{stored, int64(1)}}}
+}
+
+//Projects a scope document to what a write's history entry is made from: the key subdocument and its
+//deleted_revisions entry
+func makeWriteProjection(key string) bson.M {
+	return bson.M{key: 1, deletedRevisionField(key): 1}
+}
+
+//Narrows a scope filter to documents whose key config is at revision.  Revision 0 also matches a config that isn't set,
+//or was written before revisions were recorded
+func makeRevisionFilter(filter bson.M, key string, revision int64) bson.M {
+	narrowed := bson.M{}
+	for field, value := range filter {
+		narrowed[field] = value
+	}
+	revisionField := fmt.Sprintf("%s.meta.revision", key)
+	if revision == 0 {
+		narrowed[revisionField] = bson.M{"$in": bson.A{nil, int64(0)}}
+	} else {
+		narrowed[revisionField] = revision
+	}
+
+	return narrowed
+}
+
+//Matches the history of one scope's config.  A zero Since or Until leaves that end open; BeforeVersion pages back
+//from the last entry of the previous page
+func makeHistoryFilter(h *entities.Hierarchy, scope Scope, configType entities.ConfigType, query HistoryQuery) (bson.M, error) {
+	filter, err := makeUpsertConfigFilter(h, scope)
+	if err != nil {
+		return nil, err
+	}
+	filter["config_type"] = configType.String()
+
+	changedAt := bson.M{}
+	if !query.Since.IsZero() {
+		changedAt["$gte"] = query.Since
+	}
+	if !query.Until.IsZero() {
+		changedAt["$lt"] = query.Until
+	}
+	if len(changedAt) > 0 {
+		filter["changed_at"] = changedAt
+	}
+	if query.BeforeVersion > 0 {
+		filter["version"] = bson.M{"$lt": query.BeforeVersion}
+	}
+
+	return filter, nil
+}
+
+//Matches the entry for the same write as entry: its scope, config type and version, which scope_type_version keeps
+//unique
+func makeHistoryEntryFilter(h *entities.Hierarchy, entry historyDocument) (bson.M, error) {
+	if !h.ValidLevel(entry.ConfigLevel) {
+		return nil, fmt.Errorf("invalid config level: %d", entry.ConfigLevel)
+	}
+	filter := bson.M{
+		"config_level": entry.ConfigLevel,
+		"config_type":  entry.ConfigType,
+		"version":      entry.Version,
+	}
+	for level := entities.ConfigLevel(entities.CONFIG_LEVEL_CORPORATE); level <= entry.ConfigLevel; level++ {
+		field := h.IDField(level)
+		filter[field] = entry.IDs[field]
+	}
+
+	return filter, nil
+}
+
+//Matches the scope's own document and, with cascade, those of its descendants.  Top level documents hold the defaults
+//for everything below them, so only the scopes below the top level can be deleted
+func makeDeleteScopeFilter(h *entities.Hierarchy, scope Scope, cascade bool) (bson.M, error) {
+	if scope.ConfigLevel <= entities.CONFIG_LEVEL_CORPORATE {
+		return nil, fmt.Errorf("only scopes below the %s level can be deleted", h.LevelName(entities.CONFIG_LEVEL_CORPORATE))
+	}
+	filter, err := makeUpsertConfigFilter(h, scope)
+	if err != nil {
+		return nil, err
+	}
+	if cascade {
+		filter["config_level"] = bson.M{"$gte": scope.ConfigLevel}
+	}
+
+	return filter, nil
+}
+
+//Matches what a SetConfig at scope can change the active resolution of: the scope's chain, which supplies the current
+//active configs, and every document below it
+func makePlanSetConfigFilter(h *entities.Hierarchy, scope Scope) (bson.M, error) {
+	chain, err := makeScopeChainFilter(h, scope)
+	if err != nil {
+		return nil, err
+	}
+	descendants, err := makeUpsertConfigFilter(h, scope)
+	if err != nil {
+		return nil, err
+	}
+	descendants["config_level"] = bson.M{"$gt": scope.ConfigLevel}
+
+	return bson.M{"$or": append(chain["$or"].(bson.A), descendants)}, nil
+}
+
+//Matches every document under the top level scope topID, its own included
+func makeExportFilter(h *entities.Hierarchy, topID string) bson.M {
+	return bson.M{h.IDField(entities.CONFIG_LEVEL_CORPORATE): topID}
+}
+
+//A superset of every scope's active candidates: each distinct branch of makeActiveCandidatesMatch across the scopes'
+//chains, enabled and effective at asOf.  The candidates of each scope are picked out of the result by
+//selectActiveCandidates
+func makeBatchActiveCandidatesFilter(h *entities.Hierarchy, scopes []Scope, configType entities.ConfigType, asOf time.Time) (bson.D, error) {
+	branches := bson.A{}
+	seen := map[Scope]bool{}
+	for _, scope := range scopes {
+		if _, err := makeUpsertConfigFilter(h, scope); err != nil {
+			return nil, err
+		}
+		for level := entities.ConfigLevel(entities.CONFIG_LEVEL_CORPORATE); level <= scope.ConfigLevel; level++ {
+			branch := scope.At(level)
+			if seen[branch] {
+				continue
+			}
+			seen[branch] = true
+			branches = append(branches, makeScopeConfigQuery(h, branch, configType, true, asOf))
+		}
+	}
+	if len(branches) == 0 {
+		return nil, fmt.Errorf("no scopes given")
+	}
+
+	return bson.D{
+		{Key: "$or", Value: branches},
+	}, nil
 }
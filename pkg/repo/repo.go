@@ -3,6 +3,8 @@ package repo
 import (
 	"context"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/mcquackers/config-demo/pkg/entities"
 	"go.mongodb.org/mongo-driver/bson"
@@ -10,131 +12,578 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+//ConfigRepository is the storage contract for the config hierarchy.  MDBRepo is the production implementation;
+//MemoryRepo reproduces the same semantics without a database so consumers can be unit tested.  Both are built for one
+//entities.Hierarchy (see WithHierarchy), which every Scope passed in is read against
+type ConfigRepository interface {
+	//Hierarchy is the hierarchy the repository was built with
+	Hierarchy() *entities.Hierarchy
+	//SetConfig upserts config onto the document owned by scope and returns the stored value.  Types the hierarchy
+	//doesn't allow at scope's level fail with entities.ErrConfigTypeNotAllowedAtLevel and invalid payloads with
+	//*entities.ValidationError; nothing is written in either case
+	SetConfig(ctx context.Context, scope Scope, config entities.ValidatedConfig) (entities.ValidatedConfig, error)
+	//SetConfigIfRevision is SetConfig, but only writes while the stored config's meta.revision is expectedRevision; 0
+	//means the config must not be set yet.  Otherwise it fails with ErrRevisionConflict and writes nothing
+	SetConfigIfRevision(ctx context.Context, scope Scope, config entities.ValidatedConfig, expectedRevision int64) (entities.ValidatedConfig, error)
+	//GetSpecificConfig returns the config stored at exactly scope, or an empty config if none is set
+	GetSpecificConfig(ctx context.Context, scope Scope, configType entities.ConfigType) (entities.ValidatedConfig, error)
+	//GetActiveConfig walks the hierarchy from the top level down to scope's, and returns the config of the most specific
+	//level that is enabled and inside its effective window, now or at the time given by AsOf.  Only the scope's own
+	//chain is considered, and two documents for the winning scope fail with ErrAmbiguousScope
+	GetActiveConfig(ctx context.Context, scope Scope, configType entities.ConfigType, opts ...ActiveOption) (entities.ValidatedConfig, error)
+	//GetActiveConfigsForScopes is GetActiveConfig for a batch of scopes in one round trip, keyed by the scopes as given
+	GetActiveConfigsForScopes(ctx context.Context, configType entities.ConfigType, scopes []Scope, opts ...ActiveOption) (map[Scope]entities.ValidatedConfig, error)
+	//GetActiveBundle is GetActiveConfig for every registered type at once, in one round trip
+	GetActiveBundle(ctx context.Context, scope Scope, opts ...ActiveOption) (*ActiveBundle, error)
+	//GetResolvedConfig merges the scope's chain from the top level down field by field; see entities.ResolveConfig.
+	//Layers outside their effective window are left out
+	GetResolvedConfig(ctx context.Context, scope Scope, configType entities.ConfigType, opts ...ActiveOption) (*entities.ResolvedConfig, error)
+	//ExplainActiveConfig reports every candidate GetActiveConfig considers, which one won and why the others didn't
+	ExplainActiveConfig(ctx context.Context, scope Scope, configType entities.ConfigType, opts ...ActiveOption) (*ActiveConfigExplanation, error)
+	//GetConfigHistory returns the recorded writes of one scope's config, newest first
+	GetConfigHistory(ctx context.Context, scope Scope, configType entities.ConfigType, query HistoryQuery) ([]ConfigHistoryEntry, error)
+	//RevertConfig writes the value a config had at toVersion again, as a new version changed by changedBy
+	RevertConfig(ctx context.Context, scope Scope, configType entities.ConfigType, toVersion int64, changedBy string) (entities.ValidatedConfig, error)
+	//DeleteConfig removes configType from the scope's document, so the scope no longer overrides it at all, as opposed
+	//to disabling it.  Fails with ErrConfigNotFound if it isn't set
+	DeleteConfig(ctx context.Context, scope Scope, configType entities.ConfigType, changedBy string) error
+	//DeleteScope removes a document below the top level and, with cascade, every document below it.  It returns how many
+	//documents went, or ErrScopeNotFound if there were none
+	DeleteScope(ctx context.Context, scope Scope, cascade bool, changedBy string) (int64, error)
+	//PlanSetConfig is a dry run of SetConfig: it reports which scopes at and below scope would get a different active
+	//config, from and to what, and which are shielded by an override of their own, without writing anything.  It
+	//fails as SetConfig would for an invalid config
+	PlanSetConfig(ctx context.Context, scope Scope, config entities.ValidatedConfig, opts ...ActiveOption) (*SetConfigPlan, error)
+	//Export returns every document under the top level scope topID, e.g. a corporate with its venues and vendors, as a
+	//tree that ConfigTree.Encode writes to YAML or JSON.  Fails with ErrScopeNotFound if there are none
+	Export(ctx context.Context, topID string) (*ConfigTree, error)
+	//Import validates every config in tree, then sets those that differ from what is stored.  A non-empty changedBy
+	//stamps the imported configs' meta as changed by it now; otherwise the tree's own changed_by and changed_at are kept.
+	//Invalid trees fail with ErrInvalidConfigTree before anything is written
+	Import(ctx context.Context, tree *ConfigTree, changedBy string) (*ImportResult, error)
+}
+
+var _ ConfigRepository = (*MDBRepo)(nil)
+
+type activeOptions struct {
+	asOf time.Time
+}
+
+//ActiveOption adjusts how GetActiveConfig, GetResolvedConfig and ExplainActiveConfig evaluate the hierarchy
+type ActiveOption func(*activeOptions)
+
+//AsOf evaluates effective windows at t instead of now, e.g. to ask what will be active on Saturday
+func AsOf(t time.Time) ActiveOption {
+	return func(o *activeOptions) {
+		o.asOf = t
+	}
+}
+
+//newActiveOptions applies opts, with the evaluation time defaulting to now
+func newActiveOptions(now func() time.Time, opts []ActiveOption) activeOptions {
+	activeOpts := activeOptions{}
+	for _, opt := range opts {
+		opt(&activeOpts)
+	}
+	if activeOpts.asOf.IsZero() {
+		activeOpts.asOf = now()
+	}
+
+	return activeOpts
+}
+
+//ErrRevisionConflict is returned by SetConfigIfRevision when the stored config has moved on from Expected.  Actual is
+//its revision when the write was refused
+type ErrRevisionConflict struct {
+	ConfigType entities.ConfigType
+	Expected   int64
+	Actual     int64
+}
+
+func (e ErrRevisionConflict) Error() string {
+	return fmt.Sprintf("config type %s is at revision %d, not %d", e.ConfigType.String(), e.Actual, e.Expected)
+}
+
+//ErrAmbiguousScope is returned by active and resolved reads when more than one document holds a config for the
+//winning scope.  The scope_unique index created by EnsureIndexes keeps this from happening; data written before it
+//has to be merged by hand
+type ErrAmbiguousScope struct {
+	Scope      Scope
+	ConfigType entities.ConfigType
+}
+
+func (e ErrAmbiguousScope) Error() string {
+	return fmt.Sprintf("more than one config document at %s holds config type %s", e.Scope.String(), e.ConfigType.String())
+}
+
+const (
+	defaultDatabase          = "config-demo"
+	defaultConfigCollection  = "configs"
+	defaultHistoryCollection = "config_history"
+)
+
+type repoOptions struct {
+	hierarchy         *entities.Hierarchy
+	database          string
+	configCollection  string
+	historyCollection string
+	tenantResolver    TenantResolver
+	now               func() time.Time
+}
+
+//RepoOption configures NewMDBRepo and NewMemoryRepo
+type RepoOption func(*repoOptions)
+
+//WithHierarchy sets the levels config documents are kept at; the default is entities.DefaultHierarchy.  Documents
+//written under one hierarchy can't be read under another that names their levels' ID fields differently
+func WithHierarchy(hierarchy *entities.Hierarchy) RepoOption {
+	return func(o *repoOptions) {
+		o.hierarchy = hierarchy
+	}
+}
+
+//WithDatabase sets the MongoDB database, config-demo by default
+func WithDatabase(name string) RepoOption {
+	return func(o *repoOptions) {
+		o.database = name
+	}
+}
+
+//WithCollection sets the configs collection, configs by default
+func WithCollection(name string) RepoOption {
+	return func(o *repoOptions) {
+		o.configCollection = name
+	}
+}
+
+//WithHistoryCollection sets the history collection, config_history by default
+func WithHistoryCollection(name string) RepoOption {
+	return func(o *repoOptions) {
+		o.historyCollection = name
+	}
+}
+
+//WithTenantResolver makes the repository multi-tenant: every call is routed to the database and collections resolver
+//picks from its context, and fails if it picks none.  See TenantDatabases and TenantCollectionPrefixes.  Indexes are per
+//collection too, so EnsureIndexes has to be run with each tenant's context before the tenant writes: without
+//scope_unique, concurrent SetConfigIfRevision(..., 0) calls can both create the scope's document.  config-migrate
+//-tenant does this for a list of tenants
+func WithTenantResolver(resolver TenantResolver) RepoOption {
+	return func(o *repoOptions) {
+		o.tenantResolver = resolver
+	}
+}
+
+//WithClock sets the repository's clock, time.Now by default.  It is the time reads without AsOf evaluate effective
+//windows at, and the time deletions and reverts are recorded at, so tests can step a repository through both
+func WithClock(now func() time.Time) RepoOption {
+	return func(o *repoOptions) {
+		o.now = now
+	}
+}
+
+func newRepoOptions(opts []RepoOption) repoOptions {
+	repoOpts := repoOptions{
+		hierarchy:         entities.DefaultHierarchy(),
+		database:          defaultDatabase,
+		configCollection:  defaultConfigCollection,
+		historyCollection: defaultHistoryCollection,
+		now:               time.Now,
+	}
+	for _, opt := range opts {
+		opt(&repoOpts)
+	}
+
+	return repoOpts
+}
+
+//location is where a call's documents live: the configured database and collections, moved by the tenant resolver if
+//there is one
+func (o repoOptions) location(ctx context.Context) (database, configCollection, historyCollection string, err error) {
+	if o.tenantResolver == nil {
+		return o.database, o.configCollection, o.historyCollection, nil
+	}
+	tenant, err := o.tenantResolver(ctx)
+	if err != nil {
+		return "", "", "", err
+	}
+	database = o.database
+	if tenant.Database != "" {
+		database = tenant.Database
+	}
+
+	return database, tenant.CollectionPrefix + o.configCollection, tenant.CollectionPrefix + o.historyCollection, nil
+}
+
+//tenantKey names the location a call's documents live in, e.g. config-acme.configs, or is "" without a tenant resolver
+func (o repoOptions) tenantKey(ctx context.Context) (string, error) {
+	if o.tenantResolver == nil {
+		return "", nil
+	}
+	database, collection, _, err := o.location(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return database + "." + collection, nil
+}
+
 type MDBRepo struct {
-	client           *mongo.Client
-	configCollection *mongo.Collection
+	client    *mongo.Client
+	options   repoOptions
+	hierarchy *entities.Hierarchy
 }
 
-func NewMDBRepo(client *mongo.Client) MDBRepo {
+func NewMDBRepo(client *mongo.Client, opts ...RepoOption) MDBRepo {
+	repoOpts := newRepoOptions(opts)
+
 	return MDBRepo{
-		client:           client,
-		configCollection: client.Database("config-demo").Collection("configs"),
+		client:    client,
+		options:   repoOpts,
+		hierarchy: repoOpts.hierarchy,
 	}
 }
 
-func (r MDBRepo) SetConfig(ctx context.Context, configLevel entities.ConfigLevel, corporateID, venueID, vendorID string, config entities.ValidatedConfig) (entities.ValidatedConfig, error) {
-	//configType == MAIN is invalid for SET; seems too dangerous
-	filter, err := makeUpsertConfigFilter(configLevel, corporateID, venueID, vendorID)
+//now is the repository's clock; see WithClock
+func (r MDBRepo) now() time.Time {
+	return r.options.now()
+}
+
+//configCollection is the configs collection of the call's tenant
+func (r MDBRepo) configCollection(ctx context.Context) (*mongo.Collection, error) {
+	database, collection, _, err := r.options.location(ctx)
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println(filter)
 
-	replaceOpts := options.FindOneAndUpdate().SetUpsert(true) //.SetReturnDocument(options.After)
-	result := r.configCollection.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{config.GetConfigType().String(): config}}, replaceOpts)
-	if err := result.Err(); err != nil && err != mongo.ErrNoDocuments {
-		return nil, result.Err()
+	return r.client.Database(database).Collection(collection), nil
+}
+
+//historyCollection is the history collection of the call's tenant
+func (r MDBRepo) historyCollection(ctx context.Context) (*mongo.Collection, error) {
+	database, _, collection, err := r.options.location(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	return r.GetSpecificConfig(ctx, configLevel, corporateID, venueID, vendorID, config.GetConfigType())
+	return r.client.Database(database).Collection(collection), nil
+}
+
+func (r MDBRepo) Hierarchy() *entities.Hierarchy {
+	return r.hierarchy
 }
 
-func (r *MDBRepo) GetSpecificConfig(ctx context.Context, configLevel entities.ConfigLevel, corporateID, venueID, vendorID string, configType entities.ConfigType) (entities.ValidatedConfig, error) {
-	csr, err := r.configCollection.Aggregate(ctx, makeConfigPipeline(corporateID, venueID, vendorID, configType))
+func (r MDBRepo) tenantKey(ctx context.Context) (string, error) {
+	return r.options.tenantKey(ctx)
+}
+
+func (r MDBRepo) SetConfig(ctx context.Context, scope Scope, config entities.ValidatedConfig) (entities.ValidatedConfig, error) {
+	return r.setConfig(ctx, scope, config, nil)
+}
+
+func (r MDBRepo) SetConfigIfRevision(ctx context.Context, scope Scope, config entities.ValidatedConfig, expectedRevision int64) (entities.ValidatedConfig, error) {
+	return r.setConfig(ctx, scope, config, &expectedRevision)
+}
+
+//setConfig writes config unconditionally if expectedRevision is nil
+func (r MDBRepo) setConfig(ctx context.Context, scope Scope, config entities.ValidatedConfig, expectedRevision *int64) (entities.ValidatedConfig, error) {
+	//configType == MAIN is invalid for SET; seems too dangerous.  It has no level associations, so this rejects it too
+	if err := r.hierarchy.ValidateConfigLevel(scope.ConfigLevel, config.GetConfigType()); err != nil {
+		return nil, err
+	}
+	if err := entities.ValidateConfig(config); err != nil {
+		return nil, err
+	}
+
+	filter, err := makeUpsertConfigFilter(r.hierarchy, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	configs, err := r.configCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	//The revision being replaced is read from the document the write updates, in the same operation; see nextRevision.
+	//Only a scope document that doesn't exist yet takes it from the history, in createAndSetConfig
+	key := config.GetConfigType().String()
+	update, err := makeSetConfigUpdate(key, config, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var before bson.Raw
+	var baseRevision int64
+	if expectedRevision == nil {
+		before, err = r.findAndSetConfig(ctx, configs, filter, update, key, false)
+		if err == mongo.ErrNoDocuments {
+			before, baseRevision, err = r.createAndSetConfig(ctx, configs, scope, filter, config)
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		//A conditional write can't upsert: a scope document that exists but fails the revision condition would be
+		//duplicated.  So update the existing document, and only create one if there was none and none was expected.
+		//If another writer creates it in between, scope_unique (see EnsureIndexes) turns the upsert into a conflict
+		revisionFilter := makeRevisionFilter(filter, key, *expectedRevision)
+		before, err = r.findAndSetConfig(ctx, configs, revisionFilter, update, key, false)
+		if err == mongo.ErrNoDocuments && *expectedRevision == 0 {
+			var count int64
+			if count, err = configs.CountDocuments(ctx, filter); err == nil && count == 0 {
+				before, baseRevision, err = r.createAndSetConfig(ctx, configs, scope, revisionFilter, config)
+			} else if err == nil {
+				err = mongo.ErrNoDocuments
+			}
+		}
+		if err == mongo.ErrNoDocuments || isDuplicateKeyError(err) {
+			return nil, r.revisionConflict(ctx, scope, config.GetConfigType(), *expectedRevision)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	entry, err := newHistoryEntry(r.hierarchy, scope, config.GetConfigType(), before, deletedRevision(before, key, baseRevision), config)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.appendHistory(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return r.GetSpecificConfig(ctx, scope, config.GetConfigType())
+}
+
+//createAndSetConfig upserts config into a scope document that didn't exist, numbering it on from the config's latest
+//history version, which it also returns.  The history read isn't atomic with the write: if the document is created,
+//written and removed with DeleteScope in between, the version is reused, and appendHistory leaves the later write out of
+//the history.  If it is only created in between, the upsert updates it, and numbers on from what it holds as usual
+func (r MDBRepo) createAndSetConfig(ctx context.Context, configs *mongo.Collection, scope Scope, filter bson.M, config entities.ValidatedConfig) (bson.Raw, int64, error) {
+	baseRevision, err := r.latestHistoryVersion(ctx, scope, config.GetConfigType())
+	if err != nil {
+		return nil, 0, err
+	}
+	key := config.GetConfigType().String()
+	update, err := makeSetConfigUpdate(key, config, baseRevision)
+	if err != nil {
+		return nil, 0, err
+	}
+	before, err := r.findAndSetConfig(ctx, configs, filter, update, key, true)
+
+	return before, baseRevision, err
+}
+
+//findAndSetConfig applies a makeSetConfigUpdate update and returns the scope document as it was, projected with
+//makeWriteProjection, or nil if the update created it.  Without upsert, no match is mongo.ErrNoDocuments
+func (r MDBRepo) findAndSetConfig(ctx context.Context, configs *mongo.Collection, filter bson.M, update mongo.Pipeline, key string, upsert bool) (bson.Raw, error) {
+	//The pre-image is kept for the history entry; the update bumps meta.revision in the same operation, so the
+	//revision written is always the pre-image's plus one
+	updateOpts := options.FindOneAndUpdate().SetUpsert(upsert).SetProjection(makeWriteProjection(key))
+	result := configs.FindOneAndUpdate(ctx, filter, update, updateOpts)
+	//Two upserts racing to create the scope document: scope_unique rejects one, which can now update the other's.  A
+	//filter narrowed by revision may still not match, so that surfaces as the duplicate key error
+	if upsert && isDuplicateKeyError(result.Err()) {
+		result = configs.FindOneAndUpdate(ctx, filter, update, updateOpts)
+	}
+	if err := result.Err(); err != nil {
+		if err == mongo.ErrNoDocuments && upsert {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result.DecodeBytes()
+}
+
+//revisionConflict reads the revision that beat expectedRevision, for the error
+func (r MDBRepo) revisionConflict(ctx context.Context, scope Scope, configType entities.ConfigType, expectedRevision int64) error {
+	current, err := r.GetSpecificConfig(ctx, scope, configType)
+	if err != nil {
+		return err
+	}
+
+	return ErrRevisionConflict{ConfigType: configType, Expected: expectedRevision, Actual: revisionOfConfig(current)}
+}
+
+func (r *MDBRepo) GetSpecificConfig(ctx context.Context, scope Scope, configType entities.ConfigType) (entities.ValidatedConfig, error) {
+	pipeline, err := makeConfigPipeline(r.hierarchy, scope, configType)
+	if err != nil {
+		return nil, err
+	}
+
+	configs, err := r.configCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := configs.Aggregate(ctx, pipeline)
 	if err != nil {
 		return nil, err
 	}
 	defer csr.Close(ctx)
 
-	return getConfigFromCursor(ctx, csr, configLevel, configType)
+	return getConfigFromCursor(ctx, csr, r.hierarchy, scope.ConfigLevel, configType)
 }
 
-func (r *MDBRepo) GetActiveConfig(ctx context.Context, configLevel entities.ConfigLevel, corporateID, venueID, vendorID string, configType entities.ConfigType) (entities.ValidatedConfig, error) {
-	csr, err := r.configCollection.Aggregate(ctx, makeGetActiveConfigPipeline(configLevel, corporateID, venueID, vendorID, configType))
+func (r *MDBRepo) GetActiveConfig(ctx context.Context, scope Scope, configType entities.ConfigType, opts ...ActiveOption) (entities.ValidatedConfig, error) {
+	activeOpts := newActiveOptions(r.now, opts)
+	pipeline, err := makeGetActiveConfigPipeline(r.hierarchy, scope, configType, activeOpts.asOf)
+	if err != nil {
+		return nil, err
+	}
+	configs, err := r.configCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := configs.Aggregate(ctx, pipeline)
 	if err != nil {
 		return nil, err
 	}
 
 	defer csr.Close(ctx)
 
-	return getConfigFromCursor(ctx, csr, configLevel, configType)
+	candidates, err := memoryDocumentsFromCursor(ctx, csr, r.hierarchy)
+	if err != nil {
+		return nil, err
+	}
+	winner, err := activeWinner(candidates, configType)
+	if err != nil {
+		return nil, err
+	}
+	if winner == nil {
+		return emptyConfigForType(r.hierarchy, scope.ConfigLevel, configType), nil
+	}
+
+	return decodeActive(r.hierarchy, winner, scope, configType)
 }
 
-//Must be updated when new config type is added
-func getConfigFromCursor(ctx context.Context, cursor *mongo.Cursor, configLevel entities.ConfigLevel, configType entities.ConfigType) (entities.ValidatedConfig, error) {
-	if !cursor.Next(ctx) {
-		return emptyConfigForType(configLevel, configType), nil
-	}
-
-	var err error
-	switch configType {
-	case entities.CONFIG_TYPE_FULL:
-		switch configLevel{
-		case entities.CONFIG_LEVEL_CORPORATE:
-			config := entities.CorporateConfig{}
-			err = cursor.Decode(&config)
-			if err != nil {
-				return nil, err
-			}
-			return &config, nil
-		case entities.CONFIG_LEVEL_VENUE:
-			config := entities.VenueConfig{}
-			err = cursor.Decode(&config)
-			if err != nil {
-				return nil, err
-			}
-			return &config, nil
-		case entities.CONFIG_LEVEL_VENDOR:
-			config := entities.VendorConfig{}
-			err = cursor.Decode(&config)
-			if err != nil {
-				return nil, err
-			}
-			return &config, nil
-		default: return nil, fmt.Errorf("unspecified config level: %d", configLevel)
-		}
-	case entities.CONFIG_TYPE_DEMO_CONFIG:
-		config := entities.CloudCartConfig{}
-		err = cursor.Decode(&config)
+func (r *MDBRepo) GetResolvedConfig(ctx context.Context, scope Scope, configType entities.ConfigType, opts ...ActiveOption) (*entities.ResolvedConfig, error) {
+	activeOpts := newActiveOptions(r.now, opts)
+	if entities.NewConfig(configType) == nil {
+		return nil, fmt.Errorf("unsupported config type")
+	}
+	filter, err := makeScopeChainFilter(r.hierarchy, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	findOpts := options.Find().SetProjection(bson.M{"config_level": 1, configType.String(): 1})
+	configs, err := r.configCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := configs.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer csr.Close(ctx)
+
+	var layers []entities.ConfigLayer
+	for csr.Next(ctx) {
+		layer, ok, err := decodeConfigLayer(csr.Current, configType)
 		if err != nil {
 			return nil, err
 		}
-		return &config, nil
+		if ok {
+			layers = append(layers, layer)
+		}
+	}
+	if err := csr.Err(); err != nil {
+		return nil, err
+	}
 
-	case entities.CONFIG_TYPE_OTHER_EXAMPLE:
-		config := entities.OtherConfig{}
-		err = cursor.Decode(&config)
-		if err != nil {
-			return nil, err
+	return resolveLayers(r.hierarchy, scope.normalized(), configType, layers, activeOpts.asOf)
+}
+
+//decodeConfigLayer pulls config_level and the configType subdocument out of a scope document.  ok is false if the
+//document has no config of that type
+func decodeConfigLayer(doc bson.Raw, configType entities.ConfigType) (entities.ConfigLayer, bool, error) {
+	var level struct {
+		ConfigLevel entities.ConfigLevel `bson:"config_level"`
+	}
+	if err := bson.Unmarshal(doc, &level); err != nil {
+		return entities.ConfigLayer{}, false, err
+	}
+
+	value, err := doc.LookupErr(configType.String())
+	if err != nil {
+		return entities.ConfigLayer{}, false, nil
+	}
+	config := entities.NewConfig(configType)
+	if err := value.Unmarshal(config); err != nil {
+		return entities.ConfigLayer{}, false, err
+	}
+
+	return entities.ConfigLayer{ConfigLevel: level.ConfigLevel, Config: config}, true, nil
+}
+
+//resolveLayers drops layers outside their effective window at asOf, applies each remaining layer's rollouts for scope
+//and orders them corporate first before merging them.  Disabled layers are left to entities.ResolveConfig.  Two layers
+//at one level come from duplicate scope documents and fail with ErrAmbiguousScope
+func resolveLayers(h *entities.Hierarchy, scope Scope, configType entities.ConfigType, layers []entities.ConfigLayer, asOf time.Time) (*entities.ResolvedConfig, error) {
+	effective := layers[:0]
+	for _, layer := range layers {
+		if meta, ok := layer.Config.(entities.MetaConfig); ok && !meta.GetMeta().EffectiveAt(asOf) {
+			continue
 		}
-		return &config, nil
-	}
-
-	return nil, fmt.Errorf("unsupported config type")
-}
-
-func emptyConfigForType(configLevel entities.ConfigLevel, configType entities.ConfigType) entities.ValidatedConfig {
-	switch configType {
-	case entities.CONFIG_TYPE_FULL:
-		switch configLevel{
-		case entities.CONFIG_LEVEL_CORPORATE:
-			config := entities.CorporateConfig{}
-			return &config
-		case entities.CONFIG_LEVEL_VENUE:
-			config := entities.VenueConfig{}
-			return &config
-		case entities.CONFIG_LEVEL_VENDOR:
-			config := entities.VendorConfig{}
-			return &config
-		default: return nil
+		layer.Config, _ = entities.ApplyRollouts(layer.Config, scope.Path())
+		effective = append(effective, layer)
+	}
+	sort.SliceStable(effective, func(i, j int) bool {
+		return effective[i].ConfigLevel < effective[j].ConfigLevel
+	})
+	for i := 1; i < len(effective); i++ {
+		if effective[i].ConfigLevel == effective[i-1].ConfigLevel {
+			return nil, ErrAmbiguousScope{Scope: scope.At(effective[i].ConfigLevel), ConfigType: configType}
 		}
-	case entities.CONFIG_TYPE_DEMO_CONFIG:
-		config := entities.CloudCartConfig{}
-		return &config
+	}
+
+	return entities.ResolveConfig(h, configType, effective)
+}
+
+func getConfigFromCursor(ctx context.Context, cursor *mongo.Cursor, h *entities.Hierarchy, configLevel entities.ConfigLevel, configType entities.ConfigType) (entities.ValidatedConfig, error) {
+	if !cursor.Next(ctx) {
+		return emptyConfigForType(h, configLevel, configType), nil
+	}
+
+	config, err := newConfigForDecode(h, configLevel, configType)
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.Decode(config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
 
-	case entities.CONFIG_TYPE_OTHER_EXAMPLE:
-		config := entities.OtherConfig{}
-		return &config
+//newConfigForDecode is emptyConfigForType, with an error explaining why there isn't one
+func newConfigForDecode(h *entities.Hierarchy, configLevel entities.ConfigLevel, configType entities.ConfigType) (entities.ValidatedConfig, error) {
+	config := emptyConfigForType(h, configLevel, configType)
+	if config == nil {
+		if configType == entities.CONFIG_TYPE_FULL {
+			return nil, fmt.Errorf("unspecified config level: %d", configLevel)
+		}
+		return nil, fmt.Errorf("unsupported config type")
 	}
-	return nil
+
+	return config, nil
+}
+
+//decodeActive is the winner's configType config as it applies to scope, with its rollouts applied for scope's IDs
+func decodeActive(h *entities.Hierarchy, winner *memoryDocument, scope Scope, configType entities.ConfigType) (entities.ValidatedConfig, error) {
+	config, err := winner.decode(h, scope.ConfigLevel, configType)
+	if err != nil {
+		return nil, err
+	}
+	config, _ = entities.ApplyRollouts(config, scope.Path())
+
+	return config, nil
+}
+
+func emptyConfigForType(h *entities.Hierarchy, configLevel entities.ConfigLevel, configType entities.ConfigType) entities.ValidatedConfig {
+	if configType == entities.CONFIG_TYPE_FULL {
+		return h.NewAggregate(configLevel)
+	}
+
+	return entities.NewConfig(configType)
 }
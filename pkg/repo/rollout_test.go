@@ -0,0 +1,100 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+)
+
+func rolloutCloudCart(percent int) *entities.CloudCartConfig {
+	config := cloudCart("CORP", true)
+	config.EnableValidatePrices = true
+	config.Rollouts = []entities.FieldRollout{{Field: "enable_validate_prices", Percent: percent}}
+	return config
+}
+
+//TestActiveConfigRollouts checks that a corporate rollout reaches the vendors whose bucket falls inside its percentage
+//through every active read, and that explain shows each vendor's bucket
+func TestActiveConfigRollouts(t *testing.T) {
+	ctx := context.Background()
+	configRepo := NewMemoryRepo()
+	vendors := make([]Scope, 40)
+	for i := range vendors {
+		vendors[i] = VendorScope("1", "2", fmt.Sprint(i))
+	}
+	rollout := entities.FieldRollout{Field: "enable_validate_prices"}
+
+	for _, percent := range []int{0, 50, 100} {
+		mustSet(t, configRepo, CorporateScope("1"), rolloutCloudCart(percent))
+		batch, err := configRepo.GetActiveConfigsForScopes(ctx, entities.CONFIG_TYPE_DEMO_CONFIG, vendors)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		included := 0
+		for _, vendor := range vendors {
+			bucket := entities.RolloutBucket(entities.CONFIG_TYPE_DEMO_CONFIG, rollout, vendor.Path())
+			want := bucket < percent
+			if want {
+				included++
+			}
+
+			active, err := configRepo.GetActiveConfig(ctx, vendor, entities.CONFIG_TYPE_DEMO_CONFIG)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := active.(*entities.CloudCartConfig).EnableValidatePrices; got != want {
+				t.Errorf("%d%%: %s in bucket %d got enable_validate_prices %t", percent, vendor.String(), bucket, got)
+			}
+			if got := batch[vendor].(*entities.CloudCartConfig).EnableValidatePrices; got != want {
+				t.Errorf("%d%%: %s in bucket %d got enable_validate_prices %t from a batched read", percent, vendor.String(), bucket, got)
+			}
+
+			explanation, err := configRepo.ExplainActiveConfig(ctx, vendor, entities.CONFIG_TYPE_DEMO_CONFIG)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantAssignment := entities.RolloutAssignment{Field: rollout.Field, Percent: percent, Bucket: bucket, Included: want}
+			if len(explanation.Rollouts) != 1 || explanation.Rollouts[0] != wantAssignment {
+				t.Errorf("%d%%: %s explained as %+v, want %+v", percent, vendor.String(), explanation.Rollouts, wantAssignment)
+			}
+		}
+		if percent == 50 && (included == 0 || included == len(vendors)) {
+			t.Errorf("50%%: %d of %d vendors included", included, len(vendors))
+		}
+	}
+
+	//GetSpecificConfig returns the config as stored
+	stored, err := configRepo.GetSpecificConfig(ctx, CorporateScope("1"), entities.CONFIG_TYPE_DEMO_CONFIG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.(*entities.CloudCartConfig).EnableValidatePrices {
+		t.Error("the stored config had its rollout applied")
+	}
+}
+
+func TestSetConfigRefusesInvalidRollouts(t *testing.T) {
+	invalid := rolloutCloudCart(101)
+	invalid.Rollouts = append(invalid.Rollouts,
+		entities.FieldRollout{Field: "meta", Percent: 10},
+		entities.FieldRollout{Field: "enable_validate_prices", Percent: 10},
+	)
+
+	var validationErr *entities.ValidationError
+	_, err := NewMemoryRepo().SetConfig(context.Background(), CorporateScope("1"), invalid)
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("got %v, want a ValidationError", err)
+	}
+	var paths []string
+	for _, fieldErr := range validationErr.Errors {
+		paths = append(paths, fieldErr.Path)
+	}
+	want := []string{"cloud_cart.meta.rollouts.0.percent", "cloud_cart.meta.rollouts.1.field", "cloud_cart.meta.rollouts.2.field"}
+	if fmt.Sprint(paths) != fmt.Sprint(want) {
+		t.Errorf("rejected %v, want %v", paths, want)
+	}
+}
@@ -0,0 +1,141 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+)
+
+//Scope addresses one document in the hierarchy: a level and the IDs of it and every level above it, top level first.
+//IDs past ConfigLevel are ignored by the repositories.  Scopes are comparable, so they can key maps
+type Scope struct {
+	ConfigLevel entities.ConfigLevel
+	IDs         [entities.MaxHierarchyDepth]string
+}
+
+//NewScope returns the scope at configLevel with the given IDs, top level first.  IDs past configLevel are dropped
+func NewScope(configLevel entities.ConfigLevel, ids ...string) Scope {
+	scope := Scope{ConfigLevel: configLevel}
+	copy(scope.IDs[:], ids)
+
+	return scope.normalized()
+}
+
+//CorporateScope addresses a corporate document in the default hierarchy
+func CorporateScope(corporateID string) Scope {
+	return NewScope(entities.CONFIG_LEVEL_CORPORATE, corporateID)
+}
+
+//VenueScope addresses a venue document in the default hierarchy
+func VenueScope(corporateID, venueID string) Scope {
+	return NewScope(entities.CONFIG_LEVEL_VENUE, corporateID, venueID)
+}
+
+//VendorScope addresses a vendor document in the default hierarchy
+func VendorScope(corporateID, venueID, vendorID string) Scope {
+	return NewScope(entities.CONFIG_LEVEL_VENDOR, corporateID, venueID, vendorID)
+}
+
+//ID is the ID of configLevel in the scope's chain, or "" if configLevel is outside it
+func (s Scope) ID(configLevel entities.ConfigLevel) string {
+	if configLevel <= entities.CONFIG_LEVEL_UNSPECIFIED || configLevel > s.ConfigLevel || int(configLevel) > entities.MaxHierarchyDepth {
+		return ""
+	}
+
+	return s.IDs[configLevel-1]
+}
+
+//At returns the scope's ancestor at configLevel, or s itself if configLevel is s's level or below it
+func (s Scope) At(configLevel entities.ConfigLevel) Scope {
+	if configLevel > s.ConfigLevel {
+		configLevel = s.ConfigLevel
+	}
+
+	return Scope{ConfigLevel: configLevel, IDs: s.IDs}.normalized()
+}
+
+//Path returns the IDs from the top level down to the scope's own
+func (s Scope) Path() []string {
+	depth := int(s.ConfigLevel)
+	if depth < 0 {
+		depth = 0
+	}
+	if depth > entities.MaxHierarchyDepth {
+		depth = entities.MaxHierarchyDepth
+	}
+
+	return append([]string(nil), s.IDs[:depth]...)
+}
+
+//String is the scope's path, e.g. scope "1"/"2" for a venue.  Level names depend on the hierarchy, which a Scope doesn't
+//know, so they are left out
+func (s Scope) String() string {
+	path := s.Path()
+	quoted := make([]string, len(path))
+	for i, id := range path {
+		quoted[i] = fmt.Sprintf("%q", id)
+	}
+
+	return "scope " + strings.Join(quoted, "/")
+}
+
+//scopeJSON is a Scope's JSON form.  ConfigLevel is the level's name, which only says what the path's length already
+//does, so reading a scope back takes its level from the path and accepts any hierarchy's names
+type scopeJSON struct {
+	ConfigLevel string   `json:"config_level"`
+	Path        []string `json:"path"`
+}
+
+//newScopeJSON is s's JSON form with its level named in h
+func newScopeJSON(h *entities.Hierarchy, s Scope) *scopeJSON {
+	return &scopeJSON{ConfigLevel: h.LevelName(s.ConfigLevel), Path: s.Path()}
+}
+
+//optionalScopeJSON is newScopeJSON for a scope that may be nil
+func optionalScopeJSON(h *entities.Hierarchy, s *Scope) *scopeJSON {
+	if s == nil {
+		return nil
+	}
+
+	return newScopeJSON(h, *s)
+}
+
+//MarshalJSON writes the scope as its level and path, e.g. {"config_level":"venue","path":["1","2"]}.  A Scope doesn't
+//know its hierarchy, so its level is named as in the default one; results that carry a hierarchy, e.g. SetConfigPlan,
+//name their scopes' levels through it
+func (s Scope) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newScopeJSON(nil, s))
+}
+
+func (s *Scope) UnmarshalJSON(data []byte) error {
+	var decoded scopeJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	if len(decoded.Path) == 0 || len(decoded.Path) > entities.MaxHierarchyDepth {
+		return fmt.Errorf("a scope needs between 1 and %d IDs, not %d", entities.MaxHierarchyDepth, len(decoded.Path))
+	}
+	*s = NewScope(entities.ConfigLevel(len(decoded.Path)), decoded.Path...)
+
+	return nil
+}
+
+//normalized blanks the IDs past ConfigLevel, which the repositories ignore, so they don't split map keys
+func (s Scope) normalized() Scope {
+	for i := len(s.Path()); i < entities.MaxHierarchyDepth; i++ {
+		s.IDs[i] = ""
+	}
+
+	return s
+}
+
+//contains reports whether other is s or one of its descendants
+func (s Scope) contains(other Scope) bool {
+	if other.ConfigLevel < s.ConfigLevel {
+		return false
+	}
+
+	return other.At(s.ConfigLevel) == s.normalized()
+}
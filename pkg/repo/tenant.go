@@ -0,0 +1,108 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+)
+
+//TenantLocation is where one tenant's documents live.  Empty fields fall back to the repository's own database and
+//unprefixed collection names
+type TenantLocation struct {
+	Database string
+	//CollectionPrefix is prepended to the configs and history collection names
+	CollectionPrefix string
+}
+
+//TenantResolver maps a call's context to the location of its tenant's documents.  It is consulted on every repository
+//call, so a call can only ever see the documents of the tenant its context names
+type TenantResolver func(ctx context.Context) (TenantLocation, error)
+
+//ErrTenantRequired is returned by a repository with a TenantResolver when the context names no tenant
+type ErrTenantRequired struct{}
+
+func (e ErrTenantRequired) Error() string {
+	return "no tenant in context"
+}
+
+//ErrInvalidTenant is returned for a tenant ID that can't be part of a database or collection name
+type ErrInvalidTenant struct {
+	TenantID string
+}
+
+func (e ErrInvalidTenant) Error() string {
+	return fmt.Sprintf("invalid tenant ID %q: use letters, digits, - and _", e.TenantID)
+}
+
+//tenantLocator is implemented by the repositories, so a CachedResolver wrapped around one keeps each tenant's entries
+//apart: tenantKey names the location a call's documents live in, "" if the repository isn't multi-tenant
+type tenantLocator interface {
+	tenantKey(ctx context.Context) (string, error)
+}
+
+type tenantContextKey struct{}
+
+//WithTenant returns a context whose repository calls act for tenantID
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantID)
+}
+
+//TenantFromContext is the tenant set by WithTenant
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantContextKey{}).(string)
+	return tenantID, ok && tenantID != ""
+}
+
+//TenantDatabases routes each tenant to its own database, named prefix followed by the tenant ID
+func TenantDatabases(prefix string) TenantResolver {
+	return func(ctx context.Context) (TenantLocation, error) {
+		tenantID, err := contextTenant(ctx)
+		if err != nil {
+			return TenantLocation{}, err
+		}
+
+		return TenantLocation{Database: prefix + tenantID}, nil
+	}
+}
+
+//TenantCollectionPrefixes keeps every tenant in the repository's database, in collections named after the tenant ID
+//and an underscore, e.g. acme_configs
+func TenantCollectionPrefixes() TenantResolver {
+	return func(ctx context.Context) (TenantLocation, error) {
+		tenantID, err := contextTenant(ctx)
+		if err != nil {
+			return TenantLocation{}, err
+		}
+
+		return TenantLocation{CollectionPrefix: tenantID + "_"}, nil
+	}
+}
+
+//TenantResolverForMode maps a tenancy mode, as the commands' -tenants flag takes it, onto a TenantResolver: "database"
+//for TenantDatabases("config-"), "prefix" for TenantCollectionPrefixes, and "" for nil, a single tenant
+func TenantResolverForMode(mode string) (TenantResolver, error) {
+	switch mode {
+	case "":
+		return nil, nil
+	case "database":
+		return TenantDatabases("config-"), nil
+	case "prefix":
+		return TenantCollectionPrefixes(), nil
+	default:
+		return nil, fmt.Errorf("unknown -tenants mode %q: use database or prefix", mode)
+	}
+}
+
+//contextTenant is the context's tenant, checked to be safe in database and collection names
+func contextTenant(ctx context.Context) (string, error) {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return "", ErrTenantRequired{}
+	}
+	for _, c := range tenantID {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return "", ErrInvalidTenant{TenantID: tenantID}
+		}
+	}
+
+	return tenantID, nil
+}
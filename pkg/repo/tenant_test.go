@@ -0,0 +1,214 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+)
+
+func TestTenantResolvers(t *testing.T) {
+	acme := WithTenant(context.Background(), "acme")
+	for _, test := range []struct {
+		name     string
+		resolver TenantResolver
+		want     TenantLocation
+	}{
+		{"databases", TenantDatabases("config-"), TenantLocation{Database: "config-acme"}},
+		{"collection prefixes", TenantCollectionPrefixes(), TenantLocation{CollectionPrefix: "acme_"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.resolver(acme)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("acme is at %+v, want %+v", got, test.want)
+			}
+
+			if _, err := test.resolver(context.Background()); !errors.As(err, &ErrTenantRequired{}) {
+				t.Errorf("no tenant: got %v, want ErrTenantRequired", err)
+			}
+			for _, tenantID := range []string{"acme/../globex", "acme.configs", "acme globex", "ácme"} {
+				if _, err := test.resolver(WithTenant(context.Background(), tenantID)); !errors.As(err, &ErrInvalidTenant{}) {
+					t.Errorf("tenant %q: got %v, want ErrInvalidTenant", tenantID, err)
+				}
+			}
+		})
+	}
+}
+
+func TestTenantResolverForMode(t *testing.T) {
+	acme := WithTenant(context.Background(), "acme")
+	for _, test := range []struct {
+		mode    string
+		want    *TenantLocation
+		wantErr bool
+	}{
+		{mode: ""},
+		{mode: "database", want: &TenantLocation{Database: "config-acme"}},
+		{mode: "prefix", want: &TenantLocation{CollectionPrefix: "acme_"}},
+		{mode: "schema", wantErr: true},
+	} {
+		resolver, err := TenantResolverForMode(test.mode)
+		if (err != nil) != test.wantErr {
+			t.Errorf("mode %q: got error %v", test.mode, err)
+			continue
+		}
+		if test.want == nil {
+			if resolver != nil {
+				t.Errorf("mode %q has a resolver, want none", test.mode)
+			}
+			continue
+		}
+		if got, err := resolver(acme); err != nil || got != *test.want {
+			t.Errorf("mode %q puts acme at %+v, %v, want %+v", test.mode, got, err, *test.want)
+		}
+	}
+}
+
+func TestTenantKey(t *testing.T) {
+	acme := WithTenant(context.Background(), "acme")
+	globex := WithTenant(context.Background(), "globex")
+	for _, test := range []struct {
+		name     string
+		resolver TenantResolver
+		wantAcme string
+	}{
+		{"single tenant", nil, ""},
+		{"databases", TenantDatabases("config-"), "config-acme.configs"},
+		{"collection prefixes", TenantCollectionPrefixes(), "config-demo.acme_configs"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			opts := newRepoOptions([]RepoOption{WithTenantResolver(test.resolver)})
+			acmeKey, err := opts.tenantKey(acme)
+			if err != nil {
+				t.Fatal(err)
+			}
+			globexKey, err := opts.tenantKey(globex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if acmeKey != test.wantAcme {
+				t.Errorf("acme's key is %q, want %q", acmeKey, test.wantAcme)
+			}
+			if test.resolver != nil && acmeKey == globexKey {
+				t.Errorf("acme and globex share the key %q", acmeKey)
+			}
+		})
+	}
+}
+
+//TestTenantIsolation checks that a repository built WithTenantResolver keeps tenants apart: a config one tenant sets
+//is invisible to another, and a call naming no tenant, or one that can't be routed, fails rather than falling back to
+//a shared location
+func TestTenantIsolation(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		resolver TenantResolver
+	}{
+		{"databases", TenantDatabases("config-")},
+		{"collection prefixes", TenantCollectionPrefixes()},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			configRepo := NewMemoryRepo(WithTenantResolver(test.resolver))
+			acme := WithTenant(ctx, "acme")
+			globex := WithTenant(ctx, "globex")
+			scope := CorporateScope("1")
+			if _, err := configRepo.SetConfig(acme, scope, cloudCart("ACME", true)); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := mustActiveIn(t, acme, configRepo, scope); got != "ACME" {
+				t.Errorf("acme reads config from %q, want its own", got)
+			}
+			if got := mustActiveIn(t, globex, configRepo, scope); got != "" {
+				t.Errorf("globex reads config from %q, want the empty config", got)
+			}
+			history, err := configRepo.GetConfigHistory(globex, scope, entities.CONFIG_TYPE_DEMO_CONFIG, HistoryQuery{})
+			if err != nil || len(history) != 0 {
+				t.Errorf("globex reads %d history entries, %v, want none", len(history), err)
+			}
+
+			if _, err := configRepo.GetActiveConfig(ctx, scope, entities.CONFIG_TYPE_DEMO_CONFIG); !errors.As(err, &ErrTenantRequired{}) {
+				t.Errorf("read without a tenant: got %v, want ErrTenantRequired", err)
+			}
+			if _, err := configRepo.SetConfig(ctx, scope, cloudCart("NOBODY", true)); !errors.As(err, &ErrTenantRequired{}) {
+				t.Errorf("write without a tenant: got %v, want ErrTenantRequired", err)
+			}
+			invalid := WithTenant(ctx, "acme/../globex")
+			if _, err := configRepo.GetActiveConfig(invalid, scope, entities.CONFIG_TYPE_DEMO_CONFIG); !errors.As(err, &ErrInvalidTenant{}) {
+				t.Errorf("read for an invalid tenant: got %v, want ErrInvalidTenant", err)
+			}
+		})
+	}
+}
+
+//TestCachedResolverKeepsTenantsApart checks a cache around a multi-tenant repository never serves one tenant's entry to
+//another, and that a tenant's writes only drop its own entries
+func TestCachedResolverKeepsTenantsApart(t *testing.T) {
+	ctx := context.Background()
+	cache := NewCachedResolver(NewMemoryRepo(WithTenantResolver(TenantCollectionPrefixes())))
+	acme := WithTenant(ctx, "acme")
+	globex := WithTenant(ctx, "globex")
+	scope := VendorScope("1", "2", "3")
+
+	if _, err := cache.SetConfig(acme, CorporateScope("1"), cloudCart("ACME", true)); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustActiveIn(t, acme, cache, scope); got != "ACME" {
+		t.Fatalf("acme reads config from %q, want its own", got)
+	}
+	if got := mustActiveIn(t, globex, cache, scope); got != "" {
+		t.Fatalf("globex reads config from %q, want the empty config acme's entry doesn't hold", got)
+	}
+	if entries := cache.Stats().Entries; entries != 2 {
+		t.Fatalf("cache holds %d entries, want one per tenant", entries)
+	}
+
+	//globex's write drops globex's entry and leaves acme's
+	if _, err := cache.SetConfig(globex, CorporateScope("1"), cloudCart("GLOBEX", true)); err != nil {
+		t.Fatal(err)
+	}
+	before := cache.Stats()
+	if got := mustActiveIn(t, acme, cache, scope); got != "ACME" {
+		t.Errorf("acme reads config from %q after globex's write, want its own", got)
+	}
+	if got := mustActiveIn(t, globex, cache, scope); got != "GLOBEX" {
+		t.Errorf("globex reads config from %q after its write, want its own", got)
+	}
+	after := cache.Stats()
+	if after.Hits-before.Hits != 1 || after.Misses-before.Misses != 1 {
+		t.Errorf("reads after globex's write hit %d and missed %d times, want acme's hit and globex's miss", after.Hits-before.Hits, after.Misses-before.Misses)
+	}
+
+	//Invalidate has no tenant to go by, so it drops the scope for every tenant
+	cache.Invalidate(CorporateScope("1"), entities.CONFIG_TYPE_DEMO_CONFIG)
+	if entries := cache.Stats().Entries; entries != 0 {
+		t.Errorf("cache holds %d entries after Invalidate, want none", entries)
+	}
+
+	//A call the repository can't route is passed through, not cached under an empty tenant
+	if _, err := cache.GetActiveConfig(ctx, scope, entities.CONFIG_TYPE_DEMO_CONFIG); !errors.As(err, &ErrTenantRequired{}) {
+		t.Errorf("read without a tenant: got %v, want ErrTenantRequired", err)
+	}
+	if _, err := cache.GetResolvedConfig(ctx, scope, entities.CONFIG_TYPE_DEMO_CONFIG); !errors.As(err, &ErrTenantRequired{}) {
+		t.Errorf("resolved read without a tenant: got %v, want ErrTenantRequired", err)
+	}
+	if entries := cache.Stats().Entries; entries != 0 {
+		t.Errorf("cache holds %d entries after reads without a tenant, want none", entries)
+	}
+}
+
+//mustActiveIn names the cloud_cart write active at scope for ctx's tenant
+func mustActiveIn(t *testing.T, ctx context.Context, configRepo ConfigRepository, scope Scope) string {
+	t.Helper()
+	active, err := configRepo.GetActiveConfig(ctx, scope, entities.CONFIG_TYPE_DEMO_CONFIG)
+	if err != nil {
+		t.Fatalf("active at %s: %v", scope.String(), err)
+	}
+
+	return changedBy(active)
+}
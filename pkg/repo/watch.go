@@ -0,0 +1,278 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//ConfigChange is emitted by Watch when a document that can affect the watched scope's active config changes.  Active
+//is the active config re-resolved after the change.  Persist ResumeToken to pick up where a watcher left off.  A
+//change with Err set is the last one before the channel closes
+type ConfigChange struct {
+	Scope         Scope
+	ConfigType    entities.ConfigType
+	OperationType string
+	//ChangedLevel is the level of the document that changed, or CONFIG_LEVEL_UNSPECIFIED for deletes
+	ChangedLevel entities.ConfigLevel
+	Active       entities.ValidatedConfig
+	ResumeToken  bson.Raw
+	ClusterTime  time.Time
+	Err          error
+}
+
+type watchOptions struct {
+	resumeToken bson.Raw
+	bufferSize  int
+}
+
+type WatchOption func(*watchOptions)
+
+//WithResumeToken starts the watch after the change the token was taken from, rather than from now
+func WithResumeToken(token bson.Raw) WatchOption {
+	return func(o *watchOptions) {
+		o.resumeToken = token
+	}
+}
+
+//WithWatchBuffer sets the size of the returned channel's buffer
+func WithWatchBuffer(size int) WatchOption {
+	return func(o *watchOptions) {
+		o.bufferSize = size
+	}
+}
+
+//watchRetryDelay is how long Watch waits before reopening a change stream that failed
+const watchRetryDelay = time.Second
+
+type changeEvent struct {
+	OperationType string   `bson:"operationType"`
+	FullDocument  bson.Raw `bson:"fullDocument"`
+	DocumentKey   struct {
+		ID bson.RawValue `bson:"_id"`
+	} `bson:"documentKey"`
+	ClusterTime       primitive.Timestamp `bson:"clusterTime"`
+	UpdateDescription struct {
+		UpdatedFields bson.Raw `bson:"updatedFields"`
+		RemovedFields []string `bson:"removedFields"`
+	} `bson:"updateDescription"`
+}
+
+//Watch streams changes to the active configType config for scope until ctx is done.  It needs a replica set.  If the
+//change stream fails it is reopened from the last resume token; an invalidate event ends the watch.  Deletes are told
+//apart by document ID, from the chain's documents when the watch starts and those inserted into it since, so a
+//document deleted between a WithResumeToken token and the start of the watch isn't reported
+func (r *MDBRepo) Watch(ctx context.Context, scope Scope, configType entities.ConfigType, opts ...WatchOption) (<-chan ConfigChange, error) {
+	if _, ok := entities.LookupConfigType(configType); !ok {
+		return nil, fmt.Errorf("unsupported config type")
+	}
+	if _, err := makeUpsertConfigFilter(r.hierarchy, scope); err != nil {
+		return nil, err
+	}
+
+	watchOpts := watchOptions{}
+	for _, opt := range opts {
+		opt(&watchOpts)
+	}
+
+	chain, err := r.chainDocumentIDs(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := r.openChangeStream(ctx, scope, watchOpts.resumeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(chan ConfigChange, watchOpts.bufferSize)
+	go r.watchLoop(ctx, stream, scope, configType, watchOpts.resumeToken, chain, changes)
+
+	return changes, nil
+}
+
+func (r *MDBRepo) openChangeStream(ctx context.Context, scope Scope, resumeToken bson.Raw) (*mongo.ChangeStream, error) {
+	streamOpts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if resumeToken != nil {
+		streamOpts.SetResumeAfter(resumeToken)
+	}
+
+	configs, err := r.configCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline, err := makeWatchPipeline(r.hierarchy, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return configs.Watch(ctx, pipeline, streamOpts)
+}
+
+//chainDocumentIDs are the IDs of the documents in scope's chain, keyed by documentIDKey
+func (r *MDBRepo) chainDocumentIDs(ctx context.Context, scope Scope) (map[string]bool, error) {
+	filter, err := makeScopeChainFilter(r.hierarchy, scope)
+	if err != nil {
+		return nil, err
+	}
+	configs, err := r.configCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := configs.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer csr.Close(ctx)
+
+	ids := map[string]bool{}
+	for csr.Next(ctx) {
+		ids[documentIDKey(csr.Current.Lookup("_id"))] = true
+	}
+
+	return ids, csr.Err()
+}
+
+//documentIDKey is a comparable form of a document's _id
+func documentIDKey(id bson.RawValue) string {
+	return fmt.Sprintf("%d:%x", id.Type, id.Value)
+}
+
+func (r *MDBRepo) watchLoop(ctx context.Context, stream *mongo.ChangeStream, scope Scope, configType entities.ConfigType, resumeToken bson.Raw, chain map[string]bool, changes chan<- ConfigChange) {
+	defer close(changes)
+
+	for {
+		for stream.Next(ctx) {
+			resumeToken = append(bson.Raw(nil), stream.ResumeToken()...)
+
+			var event changeEvent
+			if err := stream.Decode(&event); err != nil {
+				stream.Close(context.Background())
+				sendChange(ctx, changes, ConfigChange{Scope: scope, ConfigType: configType, ResumeToken: resumeToken, Err: err})
+				return
+			}
+			if event.OperationType == "invalidate" {
+				stream.Close(context.Background())
+				sendChange(ctx, changes, ConfigChange{Scope: scope, ConfigType: configType, OperationType: event.OperationType, ResumeToken: resumeToken, Err: fmt.Errorf("change stream invalidated")})
+				return
+			}
+			//Only chain documents get past the pipeline with a fullDocument; a delete is only ours if it was one of them
+			id := documentIDKey(event.DocumentKey.ID)
+			if event.FullDocument != nil {
+				chain[id] = true
+			}
+			if event.OperationType == "delete" {
+				if !chain[id] {
+					continue
+				}
+				delete(chain, id)
+			}
+			if !event.touches(configType) {
+				continue
+			}
+
+			change, err := r.makeConfigChange(ctx, scope, configType, event, resumeToken)
+			if err != nil {
+				change.Err = err
+			}
+			if !sendChange(ctx, changes, change) || err != nil {
+				stream.Close(context.Background())
+				return
+			}
+		}
+
+		streamErr := stream.Err()
+		stream.Close(context.Background())
+		if ctx.Err() != nil {
+			return
+		}
+		if streamErr == nil {
+			streamErr = fmt.Errorf("change stream closed")
+		}
+
+		//Reopen from the last change we saw, or the caller's resume point if we haven't seen one yet
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchRetryDelay):
+		}
+		var err error
+		stream, err = r.openChangeStream(ctx, scope, resumeToken)
+		if err != nil {
+			sendChange(ctx, changes, ConfigChange{Scope: scope, ConfigType: configType, ResumeToken: resumeToken, Err: fmt.Errorf("reopening change stream after %q: %w", streamErr.Error(), err)})
+			return
+		}
+	}
+}
+
+func (r *MDBRepo) makeConfigChange(ctx context.Context, scope Scope, configType entities.ConfigType, event changeEvent, resumeToken bson.Raw) (ConfigChange, error) {
+	change := ConfigChange{
+		Scope:         scope,
+		ConfigType:    configType,
+		OperationType: event.OperationType,
+		ResumeToken:   resumeToken,
+		ClusterTime:   time.Unix(int64(event.ClusterTime.T), 0),
+	}
+	if event.FullDocument != nil {
+		var changed struct {
+			ConfigLevel entities.ConfigLevel `bson:"config_level"`
+		}
+		if err := bson.Unmarshal(event.FullDocument, &changed); err == nil {
+			change.ChangedLevel = changed.ConfigLevel
+		}
+	}
+
+	active, err := r.GetActiveConfig(ctx, scope, configType)
+	if err != nil {
+		return change, err
+	}
+	change.Active = active
+
+	return change, nil
+}
+
+//touches reports whether the event could have changed the configType subdocument.  Deletes always could
+func (e changeEvent) touches(configType entities.ConfigType) bool {
+	key := configType.String()
+	switch e.OperationType {
+	case "update":
+		if elems, err := e.UpdateDescription.UpdatedFields.Elements(); err == nil {
+			for _, elem := range elems {
+				if fieldTouches(elem.Key(), key) {
+					return true
+				}
+			}
+		}
+		for _, field := range e.UpdateDescription.RemovedFields {
+			if fieldTouches(field, key) {
+				return true
+			}
+		}
+		return false
+	case "insert", "replace":
+		_, err := e.FullDocument.LookupErr(key)
+		return err == nil
+	default:
+		return true
+	}
+}
+
+func fieldTouches(field, key string) bool {
+	return field == key || strings.HasPrefix(field, key+".")
+}
+
+func sendChange(ctx context.Context, changes chan<- ConfigChange, change ConfigChange) bool {
+	select {
+	case changes <- change:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
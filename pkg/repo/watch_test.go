@@ -0,0 +1,107 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mcquackers/config-demo/pkg/entities"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestWatchPipelineMatchesTheScopeChain(t *testing.T) {
+	pipeline, err := makeWatchPipeline(entities.DefaultHierarchy(), VendorScope("1", "2", "3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches := pipeline[0][0].Value.(bson.D)[0].Value.(bson.A)
+	want := []bson.M{
+		{"fullDocument.config_level": entities.ConfigLevel(entities.CONFIG_LEVEL_CORPORATE), "fullDocument.corporate_id": "1"},
+		{"fullDocument.config_level": entities.ConfigLevel(entities.CONFIG_LEVEL_VENUE), "fullDocument.corporate_id": "1", "fullDocument.venue_id": "2"},
+		{"fullDocument.config_level": entities.ConfigLevel(entities.CONFIG_LEVEL_VENDOR), "fullDocument.corporate_id": "1", "fullDocument.venue_id": "2", "fullDocument.vendor_id": "3"},
+	}
+	if len(matches) != len(want)+1 {
+		t.Fatalf("got %d matches, want one per level and one for the operations without a document", len(matches))
+	}
+	for i, match := range want {
+		if got := matches[i].(bson.M); !sameMatch(got, match) {
+			t.Errorf("level %d matches %v, want %v", i+1, got, match)
+		}
+	}
+
+	if _, err := makeWatchPipeline(entities.DefaultHierarchy(), NewScope(4, "1", "2", "3", "4")); err == nil {
+		t.Error("watching a level the hierarchy doesn't have succeeded")
+	}
+}
+
+func sameMatch(a, b bson.M) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for field, value := range a {
+		if b[field] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+//TestMDBRepoWatch checks a watcher hears about the writes and deletes in its scope's chain and nothing else
+func TestMDBRepoWatch(t *testing.T) {
+	client := newTestMongoClient(t)
+	configRepo := newTestMDBRepo(t, client)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	corporate, venue, vendor := CorporateScope("1"), VenueScope("1", "2"), VendorScope("1", "2", "3")
+	mustSet(t, configRepo, corporate, cloudCart("CORPORATE", true))
+	mustSet(t, configRepo, venue, cloudCart("VENUE", true))
+	mustSet(t, configRepo, VenueScope("9", "2"), cloudCart("OTHER CORPORATE", true))
+
+	changes, err := configRepo.Watch(ctx, vendor, entities.CONFIG_TYPE_DEMO_CONFIG, WithWatchBuffer(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := func(what string) ConfigChange {
+		t.Helper()
+		select {
+		case change := <-changes:
+			if change.Err != nil {
+				t.Fatalf("%s: %v", what, change.Err)
+			}
+			return change
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%s: no change", what)
+			return ConfigChange{}
+		}
+	}
+
+	//None of these are in the vendor's chain, so the first change is the one after them
+	mustSet(t, configRepo, VenueScope("1", "5"), cloudCart("SIBLING VENUE", true))
+	mustSet(t, configRepo, VendorScope("1", "2", "4"), otherExample("SIBLING VENDOR", true))
+	mustSet(t, configRepo, VenueScope("9", "2"), cloudCart("OTHER CORPORATE", false))
+	if _, err := configRepo.DeleteScope(ctx, VenueScope("9", "2"), false, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	mustSet(t, configRepo, vendor, otherExample("VENDOR", true))
+	mustSet(t, configRepo, venue, cloudCart("VENUE AGAIN", true))
+
+	change := next("venue write")
+	if change.OperationType != "update" || change.ChangedLevel != entities.CONFIG_LEVEL_VENUE || changedBy(change.Active) != "VENUE AGAIN" {
+		t.Errorf("venue write: got a %s at level %d with %q active, want an update at the venue with VENUE AGAIN", change.OperationType, change.ChangedLevel, changedBy(change.Active))
+	}
+
+	if _, err := configRepo.DeleteScope(ctx, venue, false, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	change = next("venue delete")
+	if change.OperationType != "delete" || changedBy(change.Active) != "CORPORATE" {
+		t.Errorf("venue delete: got a %s with %q active, want a delete with CORPORATE", change.OperationType, changedBy(change.Active))
+	}
+
+	select {
+	case change := <-changes:
+		t.Errorf("got an extra %s change", change.OperationType)
+	case <-time.After(time.Second):
+	}
+}